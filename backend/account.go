@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"os"
+	"strings"
+
+	"tabletoppers/mailer"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supabase-community/gotrue-go"
+	"github.com/supabase-community/gotrue-go/types"
+)
+
+// Me is the signed-in user as returned by GET /me. The role comes from the
+// verified access token, so clients can rely on it for route guards.
+type Me struct {
+	UserID      string   `json:"user_id"`
+	Email       string   `json:"email"`
+	Role        string   `json:"role"`
+	Profile     *Profile `json:"profile"`
+	Memberships []Member `json:"memberships"`
+}
+
+// Refresh Session Handler
+func refreshHandler(auth gotrue.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			RefreshToken string `json:"refresh_token" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		session, err := auth.RefreshToken(request.RefreshToken)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Session refreshed", "session": session})
+	}
+}
+
+// Logout Handler. Revokes the refresh tokens of the caller's session.
+func logoutHandler(auth gotrue.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := currentPrincipal(c)
+
+		if err := auth.WithToken(principal.Token).Logout(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Logout successful"})
+	}
+}
+
+// Current User Handler. Users registered before profiles existed have a null profile.
+func meHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := currentPrincipal(c)
+
+		profile, err := store.GetProfile(c.Request.Context(), principal.UserID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch profile"})
+			return
+		}
+
+		memberships, err := store.ListMemberships(c.Request.Context(), principal.UserID)
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch memberships"})
+			return
+		}
+
+		c.JSON(http.StatusOK, Me{
+			UserID:      principal.UserID,
+			Email:       principal.Email,
+			Role:        principal.Role,
+			Profile:     profile,
+			Memberships: memberships,
+		})
+	}
+}
+
+// accountMailer delivers password reset and verification emails. With both a
+// mailer and the service role key the server generates the links itself and
+// sends them through the mailer; otherwise Supabase sends its own templates.
+type accountMailer struct {
+	auth       gotrue.Client
+	admin      gotrue.Client // nil without a service role key
+	mailer     mailer.Mailer // nil when Supabase sends the mail
+	redirectTo string
+	authURL    string // GoTrue base URL, for the endpoints gotrue-go lacks
+	apiKey     string
+}
+
+// initAccountMailer reads MAILER, MAILER_DIR, SUPABASE_URL, SUPABASE_ANON_KEY,
+// SUPABASE_SERVICE_ROLE_KEY and AUTH_REDIRECT_URL.
+func initAccountMailer(auth gotrue.Client) (*accountMailer, error) {
+	m, err := mailer.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	accounts := &accountMailer{
+		auth:       auth,
+		mailer:     m,
+		redirectTo: os.Getenv("AUTH_REDIRECT_URL"),
+		authURL:    strings.TrimRight(os.Getenv("SUPABASE_URL"), "/") + "/auth/v1",
+		apiKey:     os.Getenv("SUPABASE_ANON_KEY"),
+	}
+	if serviceKey := os.Getenv("SUPABASE_SERVICE_ROLE_KEY"); serviceKey != "" {
+		accounts.admin = auth.WithToken(serviceKey)
+	}
+	if accounts.mailer != nil && accounts.admin == nil {
+		log.Printf("MAILER is set without SUPABASE_SERVICE_ROLE_KEY; Supabase will send account emails")
+	}
+	return accounts, nil
+}
+
+func (a *accountMailer) sendsOwnMail() bool {
+	return a.mailer != nil && a.admin != nil
+}
+
+// sendRecovery emails a password reset code and link to email.
+func (a *accountMailer) sendRecovery(ctx context.Context, email string) error {
+	if !a.sendsOwnMail() {
+		return a.auth.Recover(types.RecoverRequest{Email: email})
+	}
+	link, err := a.admin.AdminGenerateLink(types.AdminGenerateLinkRequest{Type: types.LinkTypeRecovery, Email: email, RedirectTo: a.redirectTo})
+	if err != nil {
+		return err
+	}
+	return a.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Reset your TableToppers password",
+		Body: fmt.Sprintf("Use the code %s to choose a new password, or open this link:\n\n%s\n\nIf you did not ask for a reset you can ignore this email.",
+			link.EmailOTP, link.ActionLink),
+	})
+}
+
+// sendVerification emails a new signup confirmation link to email. Only
+// unconfirmed accounts get one: GoTrue refuses confirmed emails, and unknown
+// ones since no password is given, so no account is created and no sign-in
+// link goes out.
+func (a *accountMailer) sendVerification(ctx context.Context, email string) error {
+	if !a.sendsOwnMail() {
+		return a.resendSignup(ctx, email)
+	}
+	link, err := a.admin.AdminGenerateLink(types.AdminGenerateLinkRequest{Type: types.LinkTypeSignup, Email: email, RedirectTo: a.redirectTo})
+	if err != nil {
+		return err
+	}
+	return a.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Confirm your TableToppers email",
+		Body:    fmt.Sprintf("Open this link to confirm your email address:\n\n%s", link.ActionLink),
+	})
+}
+
+// resendSignup asks GoTrue to send its own signup confirmation mail again.
+// gotrue-go has no call for /resend.
+func (a *accountMailer) resendSignup(ctx context.Context, email string) error {
+	body, err := json.Marshal(map[string]string{"type": "signup", "email": email})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.authURL+"/resend", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("apikey", a.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("resend answered %d: %s", resp.StatusCode, message)
+	}
+	return nil
+}
+
+// bindEmail reads {"email": ...} and answers 400 with a field error when it is invalid.
+func bindEmail(c *gin.Context) (string, bool) {
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return "", false
+	}
+	email := strings.ToLower(strings.TrimSpace(request.Email))
+	if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+		validationFailed(c, map[string]string{"email": "must be a valid email address"})
+		return "", false
+	}
+	return email, true
+}
+
+// Request Password Reset Handler. The answer is the same whether or not the
+// account exists, so it cannot be used to discover registered emails.
+func passwordResetHandler(accounts *accountMailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email, ok := bindEmail(c)
+		if !ok {
+			return
+		}
+
+		if err := accounts.sendRecovery(c.Request.Context(), email); err != nil {
+			log.Printf("Password reset for %s failed: %v", email, err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "If the account exists, a password reset email has been sent"})
+	}
+}
+
+// Complete Password Reset Handler. Exchanges the emailed code for a session and
+// sets the new password with it.
+func passwordUpdateHandler(auth gotrue.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Email    string `json:"email"`
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+
+		fields := map[string]string{}
+		if request.Email == "" {
+			fields["email"] = "is required"
+		}
+		if request.Token == "" {
+			fields["token"] = "is required"
+		}
+		if problem := passwordProblem(request.Password); problem != "" {
+			fields["password"] = problem
+		}
+		if len(fields) > 0 {
+			validationFailed(c, fields)
+			return
+		}
+
+		verified, err := auth.VerifyForUser(types.VerifyForUserRequest{
+			Type:  types.VerificationTypeRecovery,
+			Email: strings.ToLower(strings.TrimSpace(request.Email)),
+			Token: request.Token,
+		})
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired reset token"})
+			return
+		}
+
+		if _, err := auth.WithToken(verified.AccessToken).UpdateUser(types.UpdateUserRequest{Password: &request.Password}); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully", "session": verified.Session})
+	}
+}
+
+// Resend Verification Handler. Like the reset request it never reveals whether
+// the account exists.
+func resendVerificationHandler(accounts *accountMailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email, ok := bindEmail(c)
+		if !ok {
+			return
+		}
+
+		if err := accounts.sendVerification(c.Request.Context(), email); err != nil {
+			log.Printf("Resending verification to %s failed: %v", email, err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "If the account exists and is unconfirmed, a verification email has been sent"})
+	}
+}
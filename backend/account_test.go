@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tabletoppers/mailer"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase-community/gotrue-go/types"
+)
+
+func accountRouter(auth *mockGoTrue, store Store) *gin.Engine {
+	router := setupRouter()
+	router.Use(authenticate(testVerifier()))
+	router.POST("/auth/refresh", refreshHandler(auth))
+	router.POST("/auth/logout", requireAuth(), logoutHandler(auth))
+	router.GET("/me", requireAuth(), meHandler(store))
+	return router
+}
+
+func TestRefreshHandler(t *testing.T) {
+	auth := new(mockGoTrue)
+	auth.On("RefreshToken", "good").Return(&types.TokenResponse{Session: types.Session{AccessToken: "new-access"}}, nil)
+	auth.On("RefreshToken", "revoked").Return(nil, errors.New("invalid refresh token"))
+	router := accountRouter(auth, new(mockStore))
+
+	rr := serve(router, "", http.MethodPost, "/auth/refresh", gin.H{"refresh_token": "good"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var body struct {
+		Session types.TokenResponse `json:"session"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, "new-access", body.Session.AccessToken)
+
+	assert.Equal(t, http.StatusUnauthorized, serve(router, "", http.MethodPost, "/auth/refresh", gin.H{"refresh_token": "revoked"}).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(router, "", http.MethodPost, "/auth/refresh", gin.H{}).Code)
+}
+
+func TestLogoutHandler_RevokesCallerSession(t *testing.T) {
+	auth := new(mockGoTrue)
+	token := testToken(t, "user-1", "")
+	auth.On("WithToken", token).Return()
+	auth.On("Logout").Return(nil)
+	router := accountRouter(auth, new(mockStore))
+
+	assert.Equal(t, http.StatusUnauthorized, serve(router, "", http.MethodPost, "/auth/logout", nil).Code)
+	assert.Equal(t, http.StatusOK, serve(router, token, http.MethodPost, "/auth/logout", nil).Code)
+	auth.AssertExpectations(t)
+}
+
+func TestMeHandler(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", OwnerID: "user-1"})
+	store.CreateProfile(ctx, Profile{UserID: "user-1", Username: "ann", FirstName: "Ann", LastName: "Lee"})
+	router := accountRouter(new(mockGoTrue), store)
+
+	rr := serve(router, testToken(t, "user-1", "manager"), http.MethodGet, "/me", nil)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var me Me
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &me))
+	assert.Equal(t, "user-1", me.UserID)
+	assert.Equal(t, "manager", me.Role)
+	require.NotNil(t, me.Profile)
+	assert.Equal(t, "ann", me.Profile.Username)
+	require.Len(t, me.Memberships, 1)
+	assert.Equal(t, restaurant.ID, me.Memberships[0].RestaurantID)
+
+	// Accounts without a profile still resolve
+	rr = serve(router, testToken(t, "user-2", ""), http.MethodGet, "/me", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"user_id":"user-2","email":"user-2@example.com","role":"customer","profile":null,"memberships":[]}`, rr.Body.String())
+}
+
+// recordingMailer keeps sent messages in memory.
+type recordingMailer struct {
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func recoveryRouter(accounts *accountMailer) *gin.Engine {
+	router := setupRouter()
+	router.POST("/auth/password/reset", passwordResetHandler(accounts))
+	router.POST("/auth/password/update", passwordUpdateHandler(accounts.auth))
+	router.POST("/auth/verify/resend", resendVerificationHandler(accounts))
+	return router
+}
+
+func TestPasswordReset_SupabaseSendsMail(t *testing.T) {
+	auth := new(mockGoTrue)
+	auth.On("Recover", types.RecoverRequest{Email: "ann@example.com"}).Return(nil)
+	auth.On("Recover", types.RecoverRequest{Email: "nobody@example.com"}).Return(errors.New("user not found"))
+	router := recoveryRouter(&accountMailer{auth: auth})
+
+	known := serve(router, "", http.MethodPost, "/auth/password/reset", gin.H{"email": " Ann@Example.com "})
+	unknown := serve(router, "", http.MethodPost, "/auth/password/reset", gin.H{"email": "nobody@example.com"})
+
+	assert.Equal(t, http.StatusOK, known.Code)
+	assert.Equal(t, known.Body.String(), unknown.Body.String(), "responses must not reveal which accounts exist")
+	assert.Equal(t, http.StatusBadRequest, serve(router, "", http.MethodPost, "/auth/password/reset", gin.H{"email": "nope"}).Code)
+	auth.AssertExpectations(t)
+}
+
+func TestPasswordReset_ServerSendsMail(t *testing.T) {
+	admin := new(mockGoTrue)
+	admin.On("AdminGenerateLink", types.AdminGenerateLinkRequest{Type: types.LinkTypeRecovery, Email: "ann@example.com", RedirectTo: "http://localhost:4200/reset"}).
+		Return(&types.AdminGenerateLinkResponse{ActionLink: "https://auth.example.com/verify?token=abc", EmailOTP: "123456"}, nil)
+	outbox := &recordingMailer{}
+	router := recoveryRouter(&accountMailer{auth: new(mockGoTrue), admin: admin, mailer: outbox, redirectTo: "http://localhost:4200/reset"})
+
+	rr := serve(router, "", http.MethodPost, "/auth/password/reset", gin.H{"email": "ann@example.com"})
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	require.Len(t, outbox.sent, 1)
+	assert.Equal(t, "ann@example.com", outbox.sent[0].To)
+	assert.Contains(t, outbox.sent[0].Body, "123456")
+	assert.Contains(t, outbox.sent[0].Body, "https://auth.example.com/verify?token=abc")
+}
+
+func TestPasswordUpdate(t *testing.T) {
+	auth := new(mockGoTrue)
+	auth.On("VerifyForUser", types.VerifyForUserRequest{Type: types.VerificationTypeRecovery, Email: "ann@example.com", Token: "123456"}).
+		Return(&types.VerifyForUserResponse{Session: types.Session{AccessToken: "recovery-session"}}, nil)
+	auth.On("VerifyForUser", mock.Anything).Return(nil, errors.New("token has expired"))
+	auth.On("WithToken", "recovery-session").Return()
+	auth.On("UpdateUser", mock.MatchedBy(func(req types.UpdateUserRequest) bool {
+		return req.Password != nil && *req.Password == "NewSecret123"
+	})).Return(&types.UpdateUserResponse{}, nil)
+	router := recoveryRouter(&accountMailer{auth: auth})
+
+	rr := serve(router, "", http.MethodPost, "/auth/password/update", gin.H{"email": "ann@example.com", "token": "123456", "password": "NewSecret123"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = serve(router, "", http.MethodPost, "/auth/password/update", gin.H{"email": "ann@example.com", "token": "000000", "password": "NewSecret123"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = serve(router, "", http.MethodPost, "/auth/password/update", gin.H{"email": "ann@example.com", "password": "weak"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"error":"Validation failed","fields":{"token":"is required","password":"must be at least 8 characters"}}`, rr.Body.String())
+	auth.AssertNumberOfCalls(t, "UpdateUser", 1)
+}
+
+func TestResendVerification(t *testing.T) {
+	var resent []map[string]string
+	gotrue := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		resent = append(resent, body)
+		assert.Equal(t, "/auth/v1/resend", r.URL.Path)
+		assert.Equal(t, "anon", r.Header.Get("apikey"))
+		if body["email"] != "ann@example.com" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+	}))
+	defer gotrue.Close()
+	router := recoveryRouter(&accountMailer{auth: new(mockGoTrue), authURL: gotrue.URL + "/auth/v1", apiKey: "anon"})
+
+	unconfirmed := serve(router, "", http.MethodPost, "/auth/verify/resend", gin.H{"email": "ann@example.com"})
+	unknown := serve(router, "", http.MethodPost, "/auth/verify/resend", gin.H{"email": "nobody@example.com"})
+
+	assert.Equal(t, http.StatusOK, unconfirmed.Code)
+	assert.Equal(t, unconfirmed.Body.String(), unknown.Body.String(), "responses must not reveal which accounts exist")
+	require.Len(t, resent, 2)
+	assert.Equal(t, map[string]string{"type": "signup", "email": "ann@example.com"}, resent[0])
+}
+
+func TestResendVerification_ServerSendsMail(t *testing.T) {
+	admin := new(mockGoTrue)
+	admin.On("AdminGenerateLink", types.AdminGenerateLinkRequest{Type: types.LinkTypeSignup, Email: "ann@example.com"}).
+		Return(&types.AdminGenerateLinkResponse{ActionLink: "https://auth.example.com/verify?token=xyz"}, nil)
+	admin.On("AdminGenerateLink", types.AdminGenerateLinkRequest{Type: types.LinkTypeSignup, Email: "bob@example.com"}).
+		Return(nil, errors.New("a user with this email address has already been registered"))
+	outbox := &recordingMailer{}
+	router := recoveryRouter(&accountMailer{auth: new(mockGoTrue), admin: admin, mailer: outbox})
+
+	unconfirmed := serve(router, "", http.MethodPost, "/auth/verify/resend", gin.H{"email": "ann@example.com"})
+	confirmed := serve(router, "", http.MethodPost, "/auth/verify/resend", gin.H{"email": "bob@example.com"})
+
+	assert.Equal(t, http.StatusOK, unconfirmed.Code)
+	assert.Equal(t, unconfirmed.Body.String(), confirmed.Body.String(), "responses must not reveal which accounts exist")
+	require.Len(t, outbox.sent, 1, "confirmed accounts get no link")
+	assert.Contains(t, outbox.sent[0].Body, "https://auth.example.com/verify?token=xyz")
+	admin.AssertExpectations(t)
+}
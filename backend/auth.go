@@ -0,0 +1,261 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Principal is the authenticated caller extracted from a Supabase access token.
+type Principal struct {
+	UserID string
+	Email  string
+	Role   string
+	Token  string
+}
+
+// authClaims mirrors the claims Supabase puts in its access tokens. The
+// application role lives in app_metadata, which only the service role can edit.
+type authClaims struct {
+	jwt.RegisteredClaims
+	Email       string                 `json:"email"`
+	AppMetadata map[string]interface{} `json:"app_metadata"`
+}
+
+const principalKey = "principal"
+
+// tokenVerifier validates bearer tokens signed either with the project's HS256
+// JWT secret or with a key published in its JWKS document.
+type tokenVerifier struct {
+	secret   []byte
+	jwks     *jwksCache
+	audience string
+}
+
+// initVerifier builds a tokenVerifier from SUPABASE_JWT_SECRET and/or
+// SUPABASE_JWKS_URL. SUPABASE_JWT_AUDIENCE defaults to "authenticated".
+func initVerifier() (*tokenVerifier, error) {
+	secret := os.Getenv("SUPABASE_JWT_SECRET")
+	jwksURL := os.Getenv("SUPABASE_JWKS_URL")
+	if secret == "" && jwksURL == "" {
+		return nil, errors.New("SUPABASE_JWT_SECRET or SUPABASE_JWKS_URL must be set")
+	}
+
+	audience := os.Getenv("SUPABASE_JWT_AUDIENCE")
+	if audience == "" {
+		audience = "authenticated"
+	}
+
+	verifier := &tokenVerifier{audience: audience}
+	if secret != "" {
+		verifier.secret = []byte(secret)
+	}
+	if jwksURL != "" {
+		verifier.jwks = newJWKSCache(jwksURL)
+	}
+	return verifier, nil
+}
+
+func (v *tokenVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if v.secret == nil {
+			return nil, errors.New("HMAC tokens are not accepted")
+		}
+		return v.secret, nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		if v.jwks == nil {
+			return nil, errors.New("asymmetric tokens are not accepted")
+		}
+		kid, _ := token.Header["kid"].(string)
+		return v.jwks.key(kid)
+	default:
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+}
+
+// Verify parses and validates a raw access token.
+func (v *tokenVerifier) Verify(raw string) (*Principal, error) {
+	claims := &authClaims{}
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"HS256", "RS256", "ES256"}),
+		jwt.WithAudience(v.audience),
+		jwt.WithExpirationRequired(),
+	)
+	if _, err := parser.ParseWithClaims(raw, claims, v.keyFunc); err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	role, _ := claims.AppMetadata["role"].(string)
+	if role == "" {
+		role = roleCustomer
+	}
+	return &Principal{UserID: claims.Subject, Email: claims.Email, Role: role, Token: raw}, nil
+}
+
+// authenticate reads an optional bearer token. Requests without one continue
+// anonymously; requests with an invalid one are rejected.
+func authenticate(verifier *tokenVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		raw, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || raw == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header"})
+			return
+		}
+
+		principal, err := verifier.Verify(raw)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token: " + err.Error()})
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Request = c.Request.WithContext(withAccessToken(c.Request.Context(), raw))
+		c.Next()
+	}
+}
+
+// requireAuth rejects requests that did not present a valid token.
+func requireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := currentPrincipal(c); !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// currentPrincipal returns the caller set by authenticate, if any.
+func currentPrincipal(c *gin.Context) (*Principal, bool) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := value.(*Principal)
+	return principal, ok
+}
+
+// jwksCache fetches and caches the public keys of a JWKS endpoint, refreshing
+// when an unknown key id shows up or every hour, at most once a minute. A failed
+// refresh keeps the cached keys, so tokens signed with a known key still verify
+// while the endpoint is down.
+type jwksCache struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+	triedAt   time.Time // last refresh, successful or not
+}
+
+func newJWKSCache(url string) *jwksCache {
+	return &jwksCache{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (j *jwksCache) key(kid string) (interface{}, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	key, ok := j.keys[kid]
+	stale := time.Since(j.fetchedAt) > time.Hour
+	if (!ok || stale) && time.Since(j.triedAt) > time.Minute {
+		j.triedAt = time.Now()
+		if err := j.refresh(); err != nil {
+			if !ok {
+				return nil, err
+			}
+			log.Printf("Refreshing JWKS failed, using cached key %q: %v", kid, err)
+		} else {
+			key, ok = j.keys[kid]
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// refresh must be called with mu held.
+func (j *jwksCache) refresh() error {
+	resp, err := j.client.Get(j.url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS, status code: %d", resp.StatusCode)
+	}
+
+	var document struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, k := range document.Keys {
+		switch {
+		case k.Kty == "RSA":
+			n, errN := decodeBigInt(k.N)
+			e, errE := decodeBigInt(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case k.Kty == "EC" && k.Crv == "P-256":
+			x, errX := decodeBigInt(k.X)
+			y, errY := decodeBigInt(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		}
+	}
+
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	return nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testJWTSecret = "test-jwt-secret"
+
+func testVerifier() *tokenVerifier {
+	return &tokenVerifier{secret: []byte(testJWTSecret), audience: "authenticated"}
+}
+
+// testClaims builds Supabase-shaped claims for userID with the given app role.
+func testClaims(userID, role string) authClaims {
+	claims := authClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{"authenticated"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Email: userID + "@example.com",
+	}
+	if role != "" {
+		claims.AppMetadata = map[string]interface{}{"role": role}
+	}
+	return claims
+}
+
+// testToken signs an HS256 access token accepted by testVerifier.
+func testToken(t *testing.T, userID, role string) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims(userID, role)).SignedString([]byte(testJWTSecret))
+	require.NoError(t, err)
+	return token
+}
+
+func TestVerify_HS256(t *testing.T) {
+	principal, err := testVerifier().Verify(testToken(t, "user-1", "manager"))
+
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", principal.UserID)
+	assert.Equal(t, "manager", principal.Role)
+	assert.Equal(t, "user-1@example.com", principal.Email)
+}
+
+func TestVerify_DefaultsToCustomerRole(t *testing.T) {
+	principal, err := testVerifier().Verify(testToken(t, "user-1", ""))
+
+	require.NoError(t, err)
+	assert.Equal(t, "customer", principal.Role)
+}
+
+func TestVerify_RejectsBadTokens(t *testing.T) {
+	expired := testClaims("user-1", "")
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	wrongAudience := testClaims("user-1", "")
+	wrongAudience.Audience = jwt.ClaimStrings{"anon"}
+
+	sign := func(claims authClaims, secret string) string {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		return token
+	}
+
+	cases := map[string]string{
+		"expired":        sign(expired, testJWTSecret),
+		"wrong audience": sign(wrongAudience, testJWTSecret),
+		"wrong secret":   sign(testClaims("user-1", ""), "other-secret"),
+		"garbage":        "not-a-jwt",
+	}
+	for name, raw := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := testVerifier().Verify(raw)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestVerify_JWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	b64 := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"keys":[` +
+			`{"kid":"rsa-1","kty":"RSA","n":"` + b64(rsaKey.N) + `","e":"` + b64(big.NewInt(int64(rsaKey.E))) + `"},` +
+			`{"kid":"ec-1","kty":"EC","crv":"P-256","x":"` + b64(ecKey.X) + `","y":"` + b64(ecKey.Y) + `"}]}`))
+	}))
+	defer server.Close()
+	verifier := &tokenVerifier{jwks: newJWKSCache(server.URL), audience: "authenticated"}
+
+	rsaToken := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims("rsa-user", "staff"))
+	rsaToken.Header["kid"] = "rsa-1"
+	rawRSA, err := rsaToken.SignedString(rsaKey)
+	require.NoError(t, err)
+	principal, err := verifier.Verify(rawRSA)
+	require.NoError(t, err)
+	assert.Equal(t, "rsa-user", principal.UserID)
+
+	ecToken := jwt.NewWithClaims(jwt.SigningMethodES256, testClaims("ec-user", "staff"))
+	ecToken.Header["kid"] = "ec-1"
+	rawEC, err := ecToken.SignedString(ecKey)
+	require.NoError(t, err)
+	principal, err = verifier.Verify(rawEC)
+	require.NoError(t, err)
+	assert.Equal(t, "ec-user", principal.UserID)
+
+	// HS256 tokens are refused when no secret is configured
+	_, err = verifier.Verify(testToken(t, "user-1", ""))
+	assert.Error(t, err)
+}
+
+func TestVerify_JWKSOutageKeepsCachedKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	b64 := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	down, fetches := false, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if down {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"keys":[{"kid":"rsa-1","kty":"RSA","n":"` + b64(rsaKey.N) + `","e":"` + b64(big.NewInt(int64(rsaKey.E))) + `"}]}`))
+	}))
+	defer server.Close()
+	cache := newJWKSCache(server.URL)
+	verifier := &tokenVerifier{jwks: cache, audience: "authenticated"}
+	sign := func(kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims("rsa-user", "staff"))
+		token.Header["kid"] = kid
+		raw, err := token.SignedString(rsaKey)
+		require.NoError(t, err)
+		return raw
+	}
+	_, err = verifier.Verify(sign("rsa-1"))
+	require.NoError(t, err)
+
+	// An hour on the endpoint fails, yet the cached key still verifies
+	down = true
+	cache.fetchedAt = cache.fetchedAt.Add(-2 * time.Hour)
+	cache.triedAt = cache.triedAt.Add(-2 * time.Hour)
+	_, err = verifier.Verify(sign("rsa-1"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, fetches)
+
+	// Failed refreshes are not retried on every request, and unknown keys stay unknown
+	_, err = verifier.Verify(sign("rsa-2"))
+	assert.Error(t, err)
+	assert.Equal(t, 2, fetches)
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	router := setupRouter()
+	router.Use(authenticate(testVerifier()))
+	router.GET("/open", func(c *gin.Context) {
+		principal, ok := currentPrincipal(c)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{"user": ""})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"user": principal.UserID, "role": principal.Role})
+	})
+	router.POST("/closed", requireAuth(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "ok"})
+	})
+
+	rr := serve(router, "", http.MethodGet, "/open", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"user":""}`, rr.Body.String())
+
+	rr = serve(router, testToken(t, "user-1", "staff"), http.MethodGet, "/open", nil)
+	assert.JSONEq(t, `{"user":"user-1","role":"staff"}`, rr.Body.String())
+
+	assert.Equal(t, http.StatusUnauthorized, serve(router, "", http.MethodPost, "/closed", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(router, "forged", http.MethodPost, "/closed", nil).Code)
+	assert.Equal(t, http.StatusOK, serve(router, testToken(t, "user-1", ""), http.MethodPost, "/closed", nil).Code)
+}
+
+func TestAuthMiddleware_ForwardsTokenToStore(t *testing.T) {
+	store, last := fakePostgREST(t, http.StatusOK, `[]`)
+	store.authMode = supabaseAuthForward
+	router := setupRouter()
+	router.Use(authenticate(testVerifier()))
+	router.GET("/restaurants/:id/tables", getTables(store))
+	token := testToken(t, "user-1", "")
+
+	serve(router, token, http.MethodGet, "/restaurants/res-1/tables", nil)
+
+	assert.Equal(t, "Bearer "+token, last.Header.Get("Authorization"))
+}
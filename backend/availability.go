@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	dateLayout = "2006-01-02"
+	timeLayout = "15:04"
+)
+
+// TableAvailability answers an availability search for a single start time.
+type TableAvailability struct {
+	Date            string       `json:"date"`
+	Time            string       `json:"time"`
+	StartsAt        time.Time    `json:"starts_at"`
+	Timezone        string       `json:"timezone,omitempty"`
+	PartySize       int          `json:"party_size"`
+	DurationMinutes int          `json:"duration_minutes"`
+	Available       bool         `json:"available"`
+	Tables          []Table      `json:"tables"`
+	Groups          []TableGroup `json:"groups"` // combined tables for parties no single table seats
+}
+
+// DayAvailability answers an availability search without a time: every start
+// time of the day that still has a table for the party, with the day's opening
+// hours and the reason for any special availability.
+type DayAvailability struct {
+	Date            string            `json:"date"`
+	Timezone        string            `json:"timezone,omitempty"`
+	PartySize       int               `json:"party_size"`
+	DurationMinutes int               `json:"duration_minutes"`
+	Hours           []OpeningInterval `json:"hours,omitempty"`
+	Reason          string            `json:"reason,omitempty"`
+	Slots           []Slot            `json:"slots"`
+}
+
+// Slot is a bookable start time and how many tables and table groups are free at it.
+type Slot struct {
+	Time       string    `json:"time"`
+	StartsAt   time.Time `json:"starts_at"`
+	FreeTables int       `json:"free_tables"`
+	FreeGroups int       `json:"free_groups"`
+}
+
+// parseSlot parses a local date and time. PostgREST renders time columns with
+// seconds, so both HH:MM and HH:MM:SS are accepted.
+func parseSlot(date, clock string) (time.Time, error) {
+	if len(clock) == len("15:04:05") {
+		clock = clock[:len(timeLayout)]
+	}
+	return time.Parse(dateLayout+" "+timeLayout, date+" "+clock)
+}
+
+// occupiesTable reports whether r still needs its table.
+func occupiesTable(r Reservation) bool {
+	switch r.Status {
+	case "cancelled", "completed", "no_show":
+		return false
+	}
+	return true
+}
+
+// seatingWindow returns when r starts and ends. Reservations saved without an
+// instant are read on UTC, and ones made before durations were recorded on them
+// last for fallback.
+func seatingWindow(r Reservation, fallback time.Duration) (start, end time.Time, err error) {
+	start = r.StartsAt
+	if start.IsZero() {
+		if start, err = parseSlot(r.Date, r.Time); err != nil {
+			return start, start, err
+		}
+	}
+	duration := fallback
+	if r.DurationMinutes > 0 {
+		duration = time.Duration(r.DurationMinutes) * time.Minute
+	}
+	return start, start.Add(duration), nil
+}
+
+// heldTables returns the ids of the tables r occupies: every table of its group
+// when it is booked on one, else its own table. A reservation whose group has
+// since been deleted keeps the group's lead table.
+func heldTables(r Reservation, groups []TableGroup) []string {
+	if r.GroupID != "" {
+		for _, g := range groups {
+			if g.ID == r.GroupID {
+				return g.TableIDs
+			}
+		}
+	}
+	if r.TableID == "" {
+		return nil
+	}
+	return []string{r.TableID}
+}
+
+// seatingConflict returns the reservation in existing that holds one of
+// candidate's tables during an overlapping seating, or nil. Stores call it while
+// holding the tables' locks so the check and the write are atomic.
+func seatingConflict(candidate Reservation, existing []Reservation, groups []TableGroup) *Reservation {
+	held := heldTables(candidate, groups)
+	if len(held) == 0 || !occupiesTable(candidate) {
+		return nil
+	}
+	start, end, err := seatingWindow(candidate, 0)
+	if err != nil {
+		return nil
+	}
+	for i, r := range existing {
+		if r.ID == candidate.ID || !occupiesTable(r) {
+			continue
+		}
+		rStart, rEnd, err := seatingWindow(r, end.Sub(start))
+		if err != nil || !rStart.Before(end) || !start.Before(rEnd) {
+			continue
+		}
+		for _, id := range heldTables(r, groups) {
+			if slices.Contains(held, id) {
+				return &existing[i]
+			}
+		}
+	}
+	return nil
+}
+
+// floor is a restaurant's seating: its tables and the groups they combine into.
+type floor struct {
+	Tables []Table
+	Groups []TableGroup
+}
+
+// loadFloor fetches the tables and table groups of a restaurant.
+func loadFloor(ctx context.Context, store Store, restaurantID string) (floor, error) {
+	tables, err := store.ListTables(ctx, restaurantID)
+	if err != nil {
+		return floor{}, err
+	}
+	groups, err := store.ListTableGroups(ctx, restaurantID)
+	if err != nil {
+		return floor{}, err
+	}
+	return floor{Tables: tables, Groups: groups}, nil
+}
+
+// fits reports whether t seats a party of size within its capacity bounds.
+func fits(t Table, size int) bool {
+	return t.MinCapacity <= size && size <= t.MaxCapacity
+}
+
+// bestFit returns the free table with the smallest capacity that seats size, or
+// nil. Ties go to the lower table number so results are stable.
+func bestFit(tables []Table, taken map[string]bool, size int) *Table {
+	var best *Table
+	for i := range tables {
+		t := &tables[i]
+		if taken[t.ID] || !fits(*t, size) {
+			continue
+		}
+		if best == nil || t.MaxCapacity < best.MaxCapacity || (t.MaxCapacity == best.MaxCapacity && t.Number < best.Number) {
+			best = t
+		}
+	}
+	return best
+}
+
+// takenTables returns the tables held during [start, start+duration) by the
+// reservations overlapping it. Reservations without a table, such as ones made
+// before tables were assigned, are placed on their best-fitting free table,
+// largest parties first.
+func takenTables(f floor, reservations []Reservation, start time.Time, duration time.Duration) map[string]bool {
+	end := start.Add(duration)
+	var overlapping []Reservation
+	for _, r := range reservations {
+		if !occupiesTable(r) {
+			continue
+		}
+		rStart, rEnd, err := seatingWindow(r, duration)
+		if err != nil {
+			continue
+		}
+		if rStart.Before(end) && start.Before(rEnd) {
+			overlapping = append(overlapping, r)
+		}
+	}
+	sort.SliceStable(overlapping, func(i, j int) bool { return overlapping[i].Guests > overlapping[j].Guests })
+
+	taken := map[string]bool{}
+	var unassigned []Reservation
+	for _, r := range overlapping {
+		held := heldTables(r, f.Groups)
+		if len(held) == 0 {
+			unassigned = append(unassigned, r)
+		}
+		for _, id := range held {
+			taken[id] = true
+		}
+	}
+	for _, r := range unassigned {
+		if t := bestFit(f.Tables, taken, r.Guests); t != nil {
+			taken[t.ID] = true
+		}
+	}
+	return taken
+}
+
+// freeSeating returns the tables and the table groups that can seat partySize
+// for duration from start, each best fit first.
+func freeSeating(f floor, reservations []Reservation, start time.Time, duration time.Duration, partySize int) ([]Table, []TableGroup) {
+	taken := takenTables(f, reservations, start, duration)
+
+	tables := []Table{}
+	for _, t := range f.Tables {
+		if !taken[t.ID] && fits(t, partySize) {
+			tables = append(tables, t)
+		}
+	}
+	sort.SliceStable(tables, func(i, j int) bool {
+		if tables[i].MaxCapacity != tables[j].MaxCapacity {
+			return tables[i].MaxCapacity < tables[j].MaxCapacity
+		}
+		return tables[i].Number < tables[j].Number
+	})
+
+	groups := []TableGroup{}
+	for _, g := range f.Groups {
+		if partySize < g.MinCapacity || partySize > g.MaxCapacity {
+			continue
+		}
+		free := true
+		for _, id := range g.TableIDs {
+			free = free && !taken[id]
+		}
+		if free {
+			groups = append(groups, g)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].MaxCapacity != groups[j].MaxCapacity {
+			return groups[i].MaxCapacity < groups[j].MaxCapacity
+		}
+		return len(groups[i].TableIDs) < len(groups[j].TableIDs)
+	})
+	return tables, groups
+}
+
+// reservationsAround lists the reservations of day and of the days either side,
+// so seatings that run past midnight are seen from both dates.
+func reservationsAround(ctx context.Context, store Store, restaurantID string, day time.Time) ([]Reservation, error) {
+	var reservations []Reservation
+	for offset := -1; offset <= 1; offset++ {
+		date := day.AddDate(0, 0, offset).Format(dateLayout)
+		booked, err := store.ListReservations(ctx, restaurantID, ReservationFilter{Date: date})
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, booked...)
+	}
+	return reservations, nil
+}
+
+// daySlots lists the start times on day, every interval on the restaurant's
+// clock, at which restaurant takes a seating and partySize can still be seated
+// for the full duration. Days without opening hours offer the seatings that end
+// by midnight; times skipped by a daylight saving change are left out.
+func daySlots(restaurant *Restaurant, f floor, reservations []Reservation, day time.Time, cfg settings, partySize int) []Slot {
+	slots := []Slot{}
+	loc := restaurant.location()
+	midnight := day.AddDate(0, 0, 1)
+	open := func(wall time.Time) bool { return !wall.Add(cfg.DiningDuration).After(midnight) }
+	if _, _, scheduled := restaurant.hoursOn(day); scheduled {
+		open = func(wall time.Time) bool { return restaurant.seatingOpen(wall, cfg.DiningDuration) }
+	}
+	for wall := day; wall.Before(midnight); wall = wall.Add(cfg.SlotInterval) {
+		if !open(wall) {
+			continue
+		}
+		start, err := localSlot(wall.Format(dateLayout), wall.Format(timeLayout), loc)
+		if err != nil {
+			continue
+		}
+		tables, groups := freeSeating(f, reservations, start, cfg.DiningDuration, partySize)
+		if len(tables)+len(groups) > 0 {
+			slots = append(slots, Slot{Time: wall.Format(timeLayout), StartsAt: start, FreeTables: len(tables), FreeGroups: len(groups)})
+		}
+	}
+	return slots
+}
+
+// Get Availability Handler. With a time it lists the free tables for that slot;
+// without one it lists every slot of the day that still has a table. Dates and
+// times are on the restaurant's clock.
+func getAvailability(store Store, cfg settings) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID := c.Param("id")
+		date := c.Query("date")
+		clock := c.Query("time")
+
+		fields := map[string]string{}
+		day, err := time.Parse(dateLayout, date)
+		if err != nil {
+			fields["date"] = "must be a date in YYYY-MM-DD format"
+		}
+		if clock != "" && err == nil {
+			if _, err = parseSlot(date, clock); err != nil {
+				fields["time"] = "must be a time in HH:MM format"
+			}
+		}
+		partySize, err := strconv.Atoi(c.Query("party_size"))
+		if err != nil || partySize < 1 {
+			fields["party_size"] = "must be a positive number"
+		}
+		if len(fields) > 0 {
+			validationFailed(c, fields)
+			return
+		}
+
+		ctx := c.Request.Context()
+		restaurant, err := store.GetRestaurant(ctx, restaurantID)
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Restaurant not found"})
+			return
+		} else if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch availability"})
+			return
+		}
+		loc := restaurant.location()
+		var start time.Time
+		if clock != "" {
+			if start, err = localSlot(date, clock, loc); errors.Is(err, errSkippedTime) {
+				validationFailed(c, map[string]string{"time": "does not exist on this date in the restaurant's time zone"})
+				return
+			}
+		}
+
+		// Callers may see only their own bookings, or none; free seats depend on all
+		f, err := loadFloor(withServiceRole(ctx), store, restaurantID)
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch availability"})
+			return
+		}
+		reservations, err := reservationsAround(withServiceRole(ctx), store, restaurantID, day)
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch availability"})
+			return
+		}
+
+		if clock == "" {
+			hours, reason, _ := restaurant.hoursOn(day)
+			c.JSON(http.StatusOK, DayAvailability{
+				Date:            date,
+				Timezone:        restaurant.Timezone,
+				PartySize:       partySize,
+				DurationMinutes: int(cfg.DiningDuration / time.Minute),
+				Hours:           hours,
+				Reason:          reason,
+				Slots:           daySlots(restaurant, f, reservations, day, cfg, partySize),
+			})
+			return
+		}
+
+		tables, groups := []Table{}, []TableGroup{}
+		if restaurant.seatingOpen(wallClock(start), cfg.DiningDuration) {
+			tables, groups = freeSeating(f, reservations, start, cfg.DiningDuration, partySize)
+		}
+		c.JSON(http.StatusOK, TableAvailability{
+			Date:            date,
+			Time:            start.Format(timeLayout),
+			StartsAt:        start,
+			Timezone:        restaurant.Timezone,
+			PartySize:       partySize,
+			DurationMinutes: int(cfg.DiningDuration / time.Minute),
+			Available:       len(tables)+len(groups) > 0,
+			Tables:          tables,
+			Groups:          groups,
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustSlot(t *testing.T, date, clock string) time.Time {
+	t.Helper()
+	start, err := parseSlot(date, clock)
+	require.NoError(t, err)
+	return start
+}
+
+func TestParseSlot_AcceptsSeconds(t *testing.T) {
+	a, err := parseSlot("2025-05-01", "19:30")
+	require.NoError(t, err)
+	b, err := parseSlot("2025-05-01", "19:30:00")
+	require.NoError(t, err)
+	assert.Equal(t, a, b)
+
+	_, err = parseSlot("2025-05-01", "7pm")
+	assert.Error(t, err)
+}
+
+func TestFreeSeating(t *testing.T) {
+	tables := []Table{
+		{ID: "t4", Number: 4, MinCapacity: 4, MaxCapacity: 8},
+		{ID: "t1", Number: 1, MinCapacity: 1, MaxCapacity: 2},
+		{ID: "t2", Number: 2, MinCapacity: 2, MaxCapacity: 4},
+		{ID: "t3", Number: 3, MinCapacity: 2, MaxCapacity: 4},
+	}
+	duration := 90 * time.Minute
+	freeTables := func(tables []Table, reservations []Reservation, start time.Time, duration time.Duration, partySize int) []Table {
+		free, _ := freeSeating(floor{Tables: tables}, reservations, start, duration, partySize)
+		return free
+	}
+
+	t.Run("capacity bounds and ordering", func(t *testing.T) {
+		free := freeTables(tables, nil, mustSlot(t, "2025-05-01", "19:00"), duration, 3)
+		require.Len(t, free, 2)
+		assert.Equal(t, "t2", free[0].ID)
+		assert.Equal(t, "t3", free[1].ID)
+
+		assert.Empty(t, freeTables(tables, nil, mustSlot(t, "2025-05-01", "19:00"), duration, 9))
+	})
+
+	t.Run("overlapping reservations take the best fitting table", func(t *testing.T) {
+		reservations := []Reservation{
+			{Date: "2025-05-01", Time: "18:00", Guests: 3, Status: "confirmed"},
+			{Date: "2025-05-01", Time: "20:00", Guests: 4, Status: "pending"},
+		}
+		free := freeTables(tables, reservations, mustSlot(t, "2025-05-01", "19:00"), duration, 2)
+		ids := []string{}
+		for _, table := range free {
+			ids = append(ids, table.ID)
+		}
+		assert.Equal(t, []string{"t1"}, ids)
+	})
+
+	t.Run("reservations outside the window or released do not count", func(t *testing.T) {
+		reservations := []Reservation{
+			{Date: "2025-05-01", Time: "17:30", Guests: 3, Status: "confirmed"},
+			{Date: "2025-05-01", Time: "20:30", Guests: 3, Status: "confirmed"},
+			{Date: "2025-05-01", Time: "19:00", Guests: 3, Status: "cancelled"},
+		}
+		free := freeTables(tables, reservations, mustSlot(t, "2025-05-01", "19:00"), duration, 3)
+		assert.Len(t, free, 2)
+	})
+
+	t.Run("late seatings from the day before run past midnight", func(t *testing.T) {
+		reservations := []Reservation{{Date: "2025-04-30", Time: "23:30", Guests: 5, Status: "confirmed"}}
+		assert.Empty(t, freeTables(tables, reservations, mustSlot(t, "2025-05-01", "00:30"), duration, 5))
+	})
+
+	t.Run("groups seat parties too large for one table while all their tables are free", func(t *testing.T) {
+		f := floor{Tables: tables, Groups: []TableGroup{
+			{ID: "g-wide", TableIDs: []string{"t4", "t2", "t3"}, MinCapacity: 9, MaxCapacity: 16},
+			{ID: "g-pair", TableIDs: []string{"t2", "t3"}, MinCapacity: 5, MaxCapacity: 8},
+			{ID: "g-big", TableIDs: []string{"t4", "t2"}, MinCapacity: 9, MaxCapacity: 12},
+		}}
+		start := mustSlot(t, "2025-05-01", "19:00")
+
+		free, groups := freeSeating(f, nil, start, duration, 10)
+		assert.Empty(t, free)
+		require.Len(t, groups, 2)
+		assert.Equal(t, "g-big", groups[0].ID)
+		assert.Equal(t, "g-wide", groups[1].ID)
+
+		booked := []Reservation{{Date: "2025-05-01", Time: "18:30", Guests: 2, Status: "confirmed", TableID: "t3"}}
+		_, groups = freeSeating(f, booked, start, duration, 10)
+		require.Len(t, groups, 1)
+		assert.Equal(t, "g-big", groups[0].ID)
+
+		onGroup := []Reservation{{Date: "2025-05-01", Time: "19:00", Guests: 6, Status: "confirmed", TableID: "t2", GroupID: "g-pair"}}
+		free, groups = freeSeating(f, onGroup, start, duration, 3)
+		assert.Empty(t, groups)
+		assert.Empty(t, free)
+	})
+}
+
+func TestDaySlots(t *testing.T) {
+	tables := []Table{{ID: "t1", Number: 1, MinCapacity: 1, MaxCapacity: 4}}
+	reservations := []Reservation{{Date: "2025-05-01", Time: "19:00", Guests: 2, Status: "confirmed"}}
+	cfg := settings{DiningDuration: 2 * time.Hour, SlotInterval: time.Hour}
+	day := mustSlot(t, "2025-05-01", "00:00")
+
+	slots := daySlots(&Restaurant{}, floor{Tables: tables}, reservations, day, cfg, 2)
+
+	times := []string{}
+	for _, slot := range slots {
+		times = append(times, slot.Time)
+		assert.Equal(t, 1, slot.FreeTables)
+	}
+	assert.NotContains(t, times, "18:00")
+	assert.NotContains(t, times, "19:00")
+	assert.NotContains(t, times, "20:00")
+	assert.Contains(t, times, "17:00")
+	assert.Contains(t, times, "21:00")
+	assert.Contains(t, times, "22:00")
+	assert.NotContains(t, times, "23:00")
+}
+
+func TestRouter_Availability(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	restaurant, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Bistro", Location: "Paris"})
+	require.NoError(t, err)
+	for i, capacity := range []int{2, 4} {
+		_, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: i + 1, MinCapacity: 1, MaxCapacity: capacity, Status: "available"})
+		require.NoError(t, err)
+	}
+	_, err = store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, UserID: "user-1", Date: "2025-05-01", Time: "19:00", Guests: 4, Status: "confirmed"})
+	require.NoError(t, err)
+	router := newRouter(store, defaultSettings(), nil, nil, testVerifier())
+	base := "/restaurants/" + restaurant.ID + "/availability"
+
+	rr := serve(router, "", http.MethodGet, base+"?date=2025-05-01&time=19:30&party_size=3", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var slot TableAvailability
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &slot))
+	assert.False(t, slot.Available)
+	assert.Empty(t, slot.Tables)
+	assert.Equal(t, 90, slot.DurationMinutes)
+
+	rr = serve(router, "", http.MethodGet, base+"?date=2025-05-01&time=19:30&party_size=2", nil)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &slot))
+	assert.True(t, slot.Available)
+	require.Len(t, slot.Tables, 1)
+	assert.Equal(t, 2, slot.Tables[0].MaxCapacity)
+
+	rr = serve(router, "", http.MethodGet, base+"?date=2025-05-01&party_size=3", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var day DayAvailability
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &day))
+	times := []string{}
+	for _, s := range day.Slots {
+		times = append(times, s.Time)
+	}
+	assert.Contains(t, times, "17:30")
+	assert.NotContains(t, times, "18:00")
+	assert.Contains(t, times, "20:30")
+
+	rr = serve(router, "", http.MethodGet, base+"?date=May+1st&time=25:00&party_size=0", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	var failed struct {
+		Fields map[string]string `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &failed))
+	assert.Contains(t, failed.Fields, "date")
+	assert.Contains(t, failed.Fields, "party_size")
+
+	rr = serve(router, "", http.MethodGet, "/restaurants/missing/availability?date=2025-05-01&party_size=2", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMailer_WritesMessages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Send(context.Background(), Message{To: "ann@example.com", Subject: "Hello", Body: "Line one"}))
+	require.NoError(t, m.Send(context.Background(), Message{To: "bob/../x@example.com", Subject: "Again", Body: "Line two"}))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	content, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "To: ann@example.com\r\nSubject: Hello\r\n")
+	assert.Contains(t, string(content), "Line one")
+	assert.NotContains(t, files[1].Name(), "/")
+}
+
+func TestLogMailer(t *testing.T) {
+	var out bytes.Buffer
+	m := &LogMailer{Logger: log.New(&out, "", 0)}
+
+	require.NoError(t, m.Send(context.Background(), Message{To: "ann@example.com", Subject: "Hello", Body: "Body"}))
+
+	assert.Equal(t, "mail to ann@example.com: Hello\nBody\n", out.String())
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("MAILER", "")
+	m, err := FromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, m)
+
+	t.Setenv("MAILER", "file")
+	t.Setenv("MAILER_DIR", t.TempDir())
+	m, err = FromEnv()
+	require.NoError(t, err)
+	assert.IsType(t, &FileMailer{}, m)
+
+	t.Setenv("MAILER", "carrier-pigeon")
+	_, err = FromEnv()
+	assert.Error(t, err)
+}
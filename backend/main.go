@@ -1,50 +1,68 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata" // restaurant time zones resolve without the host's zoneinfo
+
+	"tabletoppers/notifications"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
+	"github.com/supabase-community/gotrue-go"
 	"github.com/supabase-community/gotrue-go/types"
 	"github.com/supabase-community/supabase-go"
 )
 
 // Restaurant struct for database operations
 type Restaurant struct {
-	ID           string `json:"id"`
-	Name         string `json:"name"`
-	Location     string `json:"location"`
-	Description  string `json:"description"`
-	Phone        string `json:"phone"`
-	OpeningHours string `json:"opening_hours"`
-	Img          string `json:"img"`
-	CreatedAt    string `json:"created_at"`
+	ID                  string                `json:"id"`
+	Name                string                `json:"name"`
+	Location            string                `json:"location"`
+	Description         string                `json:"description"`
+	Phone               string                `json:"phone"`
+	Timezone            string                `json:"timezone"` // IANA zone reservations and opening hours are kept in, UTC when empty
+	OpeningHours        OpeningHours          `json:"opening_hours"`
+	SpecialAvailability []SpecialAvailability `json:"special_availability"`
+	Img                 string                `json:"img"`
+	OwnerID             string                `json:"owner_id,omitempty"`
+	CreatedAt           time.Time             `json:"created_at"`
 }
 
 // RestaurantCreate struct for creation requests
 type RestaurantCreate struct {
-	Name         string `json:"name" binding:"required"`
-	Location     string `json:"location" binding:"required"`
-	Description  string `json:"description"`
-	Phone        string `json:"phone"`
-	OpeningHours string `json:"opening_hours"`
-	Img          string `json:"img"`
+	Name                string                `json:"name" binding:"required"`
+	Location            string                `json:"location" binding:"required"`
+	Description         string                `json:"description"`
+	Phone               string                `json:"phone"`
+	Timezone            string                `json:"timezone"`
+	OpeningHours        OpeningHours          `json:"opening_hours,omitempty"`
+	SpecialAvailability []SpecialAvailability `json:"special_availability,omitempty"`
+	Img                 string                `json:"img"`
+	OwnerID             string                `json:"owner_id,omitempty"` // Set from the caller, never from the request body
 }
 
-// RestaurantUpdate struct for update requests
+// RestaurantUpdate struct for update requests. Empty fields are left untouched;
+// empty opening hours or special availability replace the current ones.
 type RestaurantUpdate struct {
-	Name         string `json:"name"`
-	Location     string `json:"location"`
-	Description  string `json:"description"`
-	Phone        string `json:"phone"`
-	OpeningHours string `json:"opening_hours"`
-	Img          string `json:"img"`
+	Name                string                 `json:"name,omitempty"`
+	Location            string                 `json:"location,omitempty"`
+	Description         string                 `json:"description,omitempty"`
+	Phone               string                 `json:"phone,omitempty"`
+	Timezone            string                 `json:"timezone,omitempty"`
+	OpeningHours        *OpeningHours          `json:"opening_hours,omitempty"`
+	SpecialAvailability *[]SpecialAvailability `json:"special_availability,omitempty"`
+	Img                 string                 `json:"img,omitempty"`
 }
 
 // Table struct for database operations
@@ -70,14 +88,14 @@ type TableCreate struct {
 	Y            int    `json:"y"`
 }
 
-// TableUpdate struct for update requests
+// TableUpdate struct for update requests. Empty fields are left untouched.
 type TableUpdate struct {
-	Number      int    `json:"number"`
-	MinCapacity int    `json:"min_capacity"`
-	MaxCapacity int    `json:"max_capacity"`
-	Status      string `json:"status"`
-	X           int    `json:"x"`
-	Y           int    `json:"y"`
+	Number      int    `json:"number,omitempty"`
+	MinCapacity int    `json:"min_capacity,omitempty"`
+	MaxCapacity int    `json:"max_capacity,omitempty"`
+	Status      string `json:"status,omitempty"`
+	X           int    `json:"x,omitempty"`
+	Y           int    `json:"y,omitempty"`
 }
 
 // Load environment variables
@@ -99,36 +117,101 @@ func initSupabase() (*supabase.Client, error) {
 	return client, nil
 }
 
-// Register Handler
-func registerHandler(client *supabase.Client) gin.HandlerFunc {
+// Register Handler. The caller has no session yet, so the profile is read and
+// written with the service role. A profile that cannot be written deletes the
+// new auth user again through admin so the email can register once more. Without
+// admin, or when the delete fails, the answer says the account needs removing by
+// hand.
+func registerHandler(auth, admin gotrue.Client, store Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var request struct {
-			Email    string `json:"email"`
-			Password string `json:"password"`
-		}
+		var request RegisterRequest
 
 		if err := c.ShouldBindJSON(&request); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 			return
 		}
 
+		request.normalize()
+		if fields := request.validate(); fields != nil {
+			validationFailed(c, fields)
+			return
+		}
+
+		ctx := withServiceRole(c.Request.Context())
+		_, err := store.GetProfileByUsername(ctx, request.Username)
+		if err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Validation failed", "fields": gin.H{"username": "is already taken"}})
+			return
+		}
+		if !errors.Is(err, ErrNotFound) {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to check username"})
+			return
+		}
+
 		signupReq := types.SignupRequest{
 			Email:    request.Email,
 			Password: request.Password,
+			Data: map[string]interface{}{
+				"username":     request.Username,
+				"phone_number": request.PhoneNumber,
+				"first_name":   request.FirstName,
+				"last_name":    request.LastName,
+			},
 		}
 
-		_, err := client.Auth.Signup(signupReq)
+		signup, err := auth.Signup(signupReq)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "User registered successfully"})
+		// Without auto-confirm the user comes back on its own, otherwise inside the session
+		userID := signup.User.ID
+		if userID == uuid.Nil {
+			userID = signup.Session.User.ID
+		}
+
+		profile, err := store.CreateProfile(ctx, Profile{
+			UserID:      userID.String(),
+			Username:    request.Username,
+			Email:       request.Email,
+			PhoneNumber: request.PhoneNumber,
+			FirstName:   request.FirstName,
+			LastName:    request.LastName,
+		})
+		if err != nil {
+			removed := false
+			if admin != nil {
+				if err := admin.AdminDeleteUser(types.AdminDeleteUserRequest{UserID: userID}); err != nil {
+					log.Printf("Failed to remove user %s after their profile was rejected: %v", userID, err)
+				} else {
+					removed = true
+				}
+			}
+			if !removed {
+				log.Printf("User %s has no profile and must be removed by hand before %s can register again", userID, request.Email)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "The account was created without a profile and must be removed by an administrator before this email can register again",
+					"user_id": userID,
+				})
+				return
+			}
+		}
+		if errors.Is(err, ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Validation failed", "fields": gin.H{"username": "is already taken"}})
+			return
+		}
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to create profile"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "User registered successfully", "profile": profile})
 	}
 }
 
 // Login Handler
-func loginHandler(client *supabase.Client) gin.HandlerFunc {
+func loginHandler(auth gotrue.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var request struct {
 			Email    string `json:"email"`
@@ -140,7 +223,7 @@ func loginHandler(client *supabase.Client) gin.HandlerFunc {
 			return
 		}
 
-		session, err := client.Auth.SignInWithEmailPassword(request.Email, request.Password)
+		session, err := auth.SignInWithEmailPassword(request.Email, request.Password)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
@@ -158,53 +241,53 @@ func homeHandler() gin.HandlerFunc {
 }
 
 // Get Restaurants or Single Restaurant by ID Handler
-func getRestaurants() gin.HandlerFunc {
+func getRestaurants(store Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
-		city := c.Query("city")
-		name := c.Query("name")
 
-		url := fmt.Sprintf("%s/rest/v1/restaurants", os.Getenv("SUPABASE_URL"))
 		if id != "" {
-			url = fmt.Sprintf("%s/rest/v1/restaurants?id=eq.%s", os.Getenv("SUPABASE_URL"), id)
-		}
-
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
+			restaurant, err := store.GetRestaurant(c.Request.Context(), id)
+			if errors.Is(err, ErrNotFound) {
+				c.JSON(http.StatusOK, []Restaurant{})
+				return
+			}
+			if err != nil {
+				c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch restaurants"})
+				return
+			}
+			c.JSON(http.StatusOK, []Restaurant{*restaurant})
 			return
 		}
 
-		req.Header.Set("apikey", os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_ANON_KEY"))
+		filter := RestaurantFilter{City: c.Query("city"), Name: c.Query("name")}
 
-		if id == "" {
-			query := req.URL.Query()
-			if city != "" {
-				query.Add("location", "eq."+city)
+		// mine=true narrows the list to the restaurants the caller manages
+		if c.Query("mine") == "true" {
+			principal, ok := currentPrincipal(c)
+			if !ok {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+				return
 			}
-			if name != "" {
-				query.Add("name", "ilike.*"+name+"*")
+			memberships, err := store.ListMemberships(c.Request.Context(), principal.UserID)
+			if err != nil {
+				c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch restaurants"})
+				return
+			}
+			filter.IDs = []string{}
+			for _, m := range memberships {
+				if m.Role == roleManager {
+					filter.IDs = append(filter.IDs, m.RestaurantID)
+				}
+			}
+			if len(filter.IDs) == 0 {
+				c.JSON(http.StatusOK, []Restaurant{})
+				return
 			}
-			req.URL.RawQuery = query.Encode()
 		}
 
-		clientHTTP := &http.Client{}
-		resp, err := clientHTTP.Do(req)
+		restaurants, err := store.ListRestaurants(c.Request.Context(), filter)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch restaurants"})
-			return
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			c.JSON(resp.StatusCode, gin.H{"error": "Failed to fetch restaurants"})
-			return
-		}
-
-		var restaurants []Restaurant
-		if err := json.NewDecoder(resp.Body).Decode(&restaurants); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse response"})
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch restaurants"})
 			return
 		}
 
@@ -213,7 +296,7 @@ func getRestaurants() gin.HandlerFunc {
 }
 
 // Create Restaurant Handler
-func createRestaurant() gin.HandlerFunc {
+func createRestaurant(store Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var newRestaurant RestaurantCreate
 
@@ -222,35 +305,19 @@ func createRestaurant() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
 			return
 		}
-
-		url := fmt.Sprintf("%s/rest/v1/restaurants", os.Getenv("SUPABASE_URL"))
-		requestBody, err := json.Marshal(newRestaurant)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request body"})
-			return
-		}
-
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
+		if fields := validateSchedule(newRestaurant.Timezone, newRestaurant.OpeningHours, newRestaurant.SpecialAvailability); len(fields) > 0 {
+			validationFailed(c, fields)
 			return
 		}
 
-		req.Header.Set("apikey", os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Prefer", "return=representation")
-
-		clientHTTP := &http.Client{}
-		resp, err := clientHTTP.Do(req)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create restaurant"})
-			return
+		// The creator owns the restaurant and becomes its first manager
+		newRestaurant.OwnerID = ""
+		if principal, ok := currentPrincipal(c); ok {
+			newRestaurant.OwnerID = principal.UserID
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusCreated {
-			c.JSON(resp.StatusCode, gin.H{"error": "Failed to create restaurant"})
+		if _, err := store.CreateRestaurant(c.Request.Context(), newRestaurant); err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to create restaurant"})
 			return
 		}
 
@@ -259,7 +326,7 @@ func createRestaurant() gin.HandlerFunc {
 }
 
 // Update Restaurant Handler
-func updateRestaurant() gin.HandlerFunc {
+func updateRestaurant(store Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		var updatedRestaurant RestaurantUpdate
@@ -268,38 +335,21 @@ func updateRestaurant() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
 			return
 		}
-
-		url := fmt.Sprintf("%s/rest/v1/restaurants?id=eq.%s", os.Getenv("SUPABASE_URL"), id)
-		requestBody, err := json.Marshal(updatedRestaurant)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request body: " + err.Error()})
-			return
+		var hours OpeningHours
+		if updatedRestaurant.OpeningHours != nil {
+			hours = *updatedRestaurant.OpeningHours
 		}
-
-		req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(requestBody))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request: " + err.Error()})
-			return
+		var special []SpecialAvailability
+		if updatedRestaurant.SpecialAvailability != nil {
+			special = *updatedRestaurant.SpecialAvailability
 		}
-
-		req.Header.Set("apikey", os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Prefer", "return=representation")
-
-		log.Printf("Request Body: %s", string(requestBody))
-		log.Printf("URL: %s", url)
-
-		clientHTTP := &http.Client{}
-		resp, err := clientHTTP.Do(req)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send request: " + err.Error()})
+		if fields := validateSchedule(updatedRestaurant.Timezone, hours, special); len(fields) > 0 {
+			validationFailed(c, fields)
 			return
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			c.JSON(resp.StatusCode, gin.H{"error": "Failed to update restaurant, status code: " + fmt.Sprint(resp.StatusCode)})
+		if _, err := store.UpdateRestaurant(c.Request.Context(), id, updatedRestaurant); err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to update restaurant: " + err.Error()})
 			return
 		}
 
@@ -308,32 +358,12 @@ func updateRestaurant() gin.HandlerFunc {
 }
 
 // Delete Restaurant Handler
-func deleteRestaurant() gin.HandlerFunc {
+func deleteRestaurant(store Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 
-		url := fmt.Sprintf("%s/rest/v1/restaurants?id=eq.%s", os.Getenv("SUPABASE_URL"), id)
-
-		req, err := http.NewRequest("DELETE", url, nil)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request: " + err.Error()})
-			return
-		}
-
-		req.Header.Set("apikey", os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Content-Type", "application/json")
-
-		clientHTTP := &http.Client{}
-		resp, err := clientHTTP.Do(req)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send request: " + err.Error()})
-			return
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusNoContent {
-			c.JSON(resp.StatusCode, gin.H{"error": "Failed to delete restaurant, status code: " + fmt.Sprint(resp.StatusCode)})
+		if err := store.DeleteRestaurant(c.Request.Context(), id); err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to delete restaurant: " + err.Error()})
 			return
 		}
 
@@ -342,7 +372,7 @@ func deleteRestaurant() gin.HandlerFunc {
 }
 
 // Get Tables for a Specific Restaurant Handler
-func getTables() gin.HandlerFunc {
+func getTables(store Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		restaurantID := c.Param("id")
 
@@ -351,38 +381,24 @@ func getTables() gin.HandlerFunc {
 			return
 		}
 
-		url := fmt.Sprintf("%s/rest/v1/tables?restaurant_id=eq.%s", os.Getenv("SUPABASE_URL"), restaurantID)
-
-		// If table_id is provided, add it to the query
+		// If table_id is provided, only return that table
 		if tableID := c.Param("table_id"); tableID != "" {
-			url = fmt.Sprintf("%s&id=eq.%s", url, tableID)
-		}
-
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
+			table, err := store.GetTable(c.Request.Context(), restaurantID, tableID)
+			if errors.Is(err, ErrNotFound) {
+				c.JSON(http.StatusOK, []Table{})
+				return
+			}
+			if err != nil {
+				c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch tables"})
+				return
+			}
+			c.JSON(http.StatusOK, []Table{*table})
 			return
 		}
 
-		req.Header.Set("apikey", os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_ANON_KEY"))
-
-		clientHTTP := &http.Client{}
-		resp, err := clientHTTP.Do(req)
+		tables, err := store.ListTables(c.Request.Context(), restaurantID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tables"})
-			return
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			c.JSON(resp.StatusCode, gin.H{"error": "Failed to fetch tables"})
-			return
-		}
-
-		var tables []Table
-		if err := json.NewDecoder(resp.Body).Decode(&tables); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse response"})
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch tables"})
 			return
 		}
 
@@ -391,7 +407,7 @@ func getTables() gin.HandlerFunc {
 }
 
 // Create Table Handler
-func createTable() gin.HandlerFunc {
+func createTable(store Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		restaurantID := c.Param("id")
 
@@ -407,29 +423,8 @@ func createTable() gin.HandlerFunc {
 		}
 		newTable.RestaurantID = restaurantID
 
-		url := fmt.Sprintf("%s/rest/v1/tables", os.Getenv("SUPABASE_URL"))
-		requestBody, _ := json.Marshal(newTable)
-
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Request creation failed"})
-			return
-		}
-		req.Header.Set("apikey", os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Prefer", "return=representation")
-
-		clientHTTP := &http.Client{}
-		resp, err := clientHTTP.Do(req)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create table"})
-			return
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusCreated {
-			c.JSON(resp.StatusCode, gin.H{"error": "Failed to create table"})
+		if _, err := store.CreateTable(c.Request.Context(), newTable); err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to create table"})
 			return
 		}
 
@@ -437,9 +432,8 @@ func createTable() gin.HandlerFunc {
 	}
 }
 
-
 // Update Table Handler
-func updateTable() gin.HandlerFunc {
+func updateTable(store Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		restaurantID := c.Param("id")
 		tableID := c.Param("table_id")
@@ -450,34 +444,8 @@ func updateTable() gin.HandlerFunc {
 			return
 		}
 
-		url := fmt.Sprintf("%s/rest/v1/tables?id=eq.%s&restaurant_id=eq.%s", os.Getenv("SUPABASE_URL"), tableID, restaurantID)
-		requestBody, err := json.Marshal(updatedTable)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request body"})
-			return
-		}
-
-		req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(requestBody))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
-			return
-		}
-
-		req.Header.Set("apikey", os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Prefer", "return=representation")
-
-		clientHTTP := &http.Client{}
-		resp, err := clientHTTP.Do(req)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send request"})
-			return
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			c.JSON(resp.StatusCode, gin.H{"error": "Failed to update table"})
+		if _, err := store.UpdateTable(c.Request.Context(), restaurantID, tableID, updatedTable); err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to update table"})
 			return
 		}
 
@@ -486,7 +454,7 @@ func updateTable() gin.HandlerFunc {
 }
 
 // Delete Table Handler
-func deleteTable() gin.HandlerFunc {
+func deleteTable(store Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Extract restaurant_id and table_id from the URL parameters
 		restaurantID := c.Param("id")
@@ -498,29 +466,8 @@ func deleteTable() gin.HandlerFunc {
 			return
 		}
 
-		// Build URL to delete the table for the specific restaurant
-		url := fmt.Sprintf("%s/rest/v1/tables?restaurant_id=eq.%s&id=eq.%s", os.Getenv("SUPABASE_URL"), restaurantID, tableID)
-
-		// Create the DELETE request
-		req, err := http.NewRequest("DELETE", url, nil)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
-			return
-		}
-
-		req.Header.Set("apikey", os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_ANON_KEY"))
-
-		clientHTTP := &http.Client{}
-		resp, err := clientHTTP.Do(req)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete table"})
-			return
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusNoContent {
-			c.JSON(resp.StatusCode, gin.H{"error": "Failed to delete table"})
+		if err := store.DeleteTable(c.Request.Context(), restaurantID, tableID); err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to delete table"})
 			return
 		}
 
@@ -528,76 +475,38 @@ func deleteTable() gin.HandlerFunc {
 	}
 }
 
-/*
-	func updateTable() gin.HandlerFunc {
-		return func(c *gin.Context) {
-			restaurantID := c.Param("restaurantId")
-			tableID := c.Param("tableId")
-			var updatedTable TableUpdate
-
-			if err := c.ShouldBindJSON(&updatedTable); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
-				return
-			}
-
-			url := fmt.Sprintf("%s/rest/v1/tables?id=eq.%s&restaurantId=eq.%s", os.Getenv("SUPABASE_URL"), tableID, restaurantID)
-			requestBody, err := json.Marshal(updatedTable)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request body"})
-				return
-			}
-
-			req, err := http.NewRequest("PUT", url, bytes.NewBuffer(requestBody))
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
-				return
-			}
-
-			req.Header.Set("apikey", os.Getenv("SUPABASE_ANON_KEY"))
-			req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_ANON_KEY"))
-			req.Header.Set("Content-Type", "application/json")
-
-			clientHTTP := &http.Client{}
-			resp, err := clientHTTP.Do(req)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send request"})
-				return
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode != http.StatusOK {
-				c.JSON(resp.StatusCode, gin.H{"error": "Failed to update table"})
-				return
-			}
-
-			c.JSON(http.StatusOK, gin.H{"message": "Table updated successfully"})
-		}
-	}
-*/
-
+// WaitlistEntry is a party on the waitlist. PartyAhead and EstimatedWaitTime are
+// not stored: the server works them out for the queue whenever the waitlist is
+// read. A seated entry records the table and the reservation it was seated with.
 type WaitlistEntry struct {
-	ID                string `json:"id"`
-	RestaurantID      string `json:"restaurant_id"`
-	Name              string `json:"name"`
-	PhoneNumber       string `json:"phone_number"`
-	PartySize         int    `json:"party_size"`
-	PartyAhead        int    `json:"party_ahead"`
-	EstimatedWaitTime int    `json:"estimated_wait_time"`
-	CreatedAt         string `json:"created_at"`
+	ID                string    `json:"id"`
+	RestaurantID      string    `json:"restaurant_id"`
+	UserID            string    `json:"user_id,omitempty"` // the guest who joined; empty for walk-ins added by staff
+	Name              string    `json:"name"`
+	PhoneNumber       string    `json:"phone_number"`
+	PartySize         int       `json:"party_size"`
+	Status            string    `json:"status"`
+	TableID           string    `json:"table_id,omitempty"`
+	ReservationID     string    `json:"reservation_id,omitempty"`
+	PartyAhead        int       `json:"party_ahead"`         // parties ahead in the queue
+	EstimatedWaitTime int       `json:"estimated_wait_time"` // minutes, waitUnknown when no table will seat the party
+	CreatedAt         time.Time `json:"created_at"`
 }
 
 // WaitlistEntryCreate
 type WaitlistEntryCreate struct {
-	RestaurantID      string `json:"restaurant_id"`       // The restaurant the waitlist entry belongs to
-	Name              string `json:"name"`                // Name of the person on the waitlist
-	PhoneNumber       string `json:"phone_number"`        // Contact number of the person
-	PartySize         int    `json:"party_size"`          // The size of the party
-	PartyAhead        int    `json:"party_ahead"`         // Number of parties ahead in the waitlist
-	EstimatedWaitTime int    `json:"estimated_wait_time"` // Estimated wait time in minutes
+	RestaurantID string `json:"restaurant_id"`     // The restaurant the waitlist entry belongs to
+	UserID       string `json:"user_id,omitempty"` // Set by the server to the guest joining
+	Name         string `json:"name"`              // Name of the person on the waitlist
+	PhoneNumber  string `json:"phone_number"`      // Contact number of the person
+	PartySize    int    `json:"party_size"`        // The size of the party
 }
 
-// Get waitlist entries for a specific restaurant Handler
-func getWaitlist() gin.HandlerFunc {
+// Get waitlist entries for a specific restaurant Handler. Lists the parties still
+// in the queue, in queue order, with their place and wait worked out from the
+// floor as it is now. Staff see the whole queue; customers only their own
+// entries, placed among everyone's, so the queue is read with the service role.
+func getWaitlist(store Store, cfg settings) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		restaurantID := c.Param("id")
 
@@ -606,42 +515,51 @@ func getWaitlist() gin.HandlerFunc {
 			return
 		}
 
-		url := fmt.Sprintf("%s/rest/v1/waitlist?restaurant_id=eq.%s", os.Getenv("SUPABASE_URL"), restaurantID)
-
-		req, err := http.NewRequest("GET", url, nil)
+		restaurant, ok := loadRestaurant(c, store, restaurantID)
+		if !ok {
+			return
+		}
+		principal, _ := currentPrincipal(c)
+		staff, err := isRestaurantStaff(c.Request.Context(), store, principal, restaurantID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to check permissions"})
 			return
 		}
-
-		req.Header.Set("apikey", os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_ANON_KEY"))
-
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		ctx := c.Request.Context()
+		if !staff {
+			ctx = withServiceRole(ctx)
+		}
+		entries, err := store.ListWaitlist(ctx, restaurantID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch waitlist entries"})
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch waitlist entries"})
 			return
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			c.JSON(resp.StatusCode, gin.H{"error": "Failed to fetch waitlist entries"})
+		loc := restaurant.location()
+		now := time.Now()
+		f, err := loadFloor(ctx, store, restaurantID)
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch waitlist entries"})
 			return
 		}
-
-		var entries []WaitlistEntry
-		if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse response: " + err.Error()})
+		reservations, err := reservationsAround(ctx, store, restaurantID, now.In(loc))
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch waitlist entries"})
 			return
 		}
 
+		entries = estimateWaits(restaurant, f, reservations, inQueue(entries), now, cfg)
+		if !staff {
+			entries = without(entries, func(e WaitlistEntry) bool { return e.UserID != principal.UserID })
+		}
+		for i := range entries {
+			entries[i].CreatedAt = entries[i].CreatedAt.In(loc)
+		}
 		c.JSON(http.StatusOK, entries)
 	}
 }
 
 // Create waitlist entry for a specific restaurant handler
-func createWaitlistEntry() gin.HandlerFunc {
+func createWaitlistEntry(store Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		restaurantID := c.Param("id")
 
@@ -657,35 +575,20 @@ func createWaitlistEntry() gin.HandlerFunc {
 		}
 
 		newEntry.RestaurantID = restaurantID
-
-		url := fmt.Sprintf("%s/rest/v1/waitlist", os.Getenv("SUPABASE_URL"))
-
-		requestBody, err := json.Marshal(newEntry)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request body"})
-			return
-		}
-
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
-			return
-		}
-
-		req.Header.Set("apikey", os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Content-Type", "application/json")
-
-		clientHTTP := &http.Client{}
-		resp, err := clientHTTP.Do(req)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create waitlist entry"})
-			return
+		newEntry.UserID = ""
+		if principal, ok := currentPrincipal(c); ok {
+			staff, err := isRestaurantStaff(c.Request.Context(), store, principal, restaurantID)
+			if err != nil {
+				c.JSON(storeStatus(err), gin.H{"error": "Failed to check permissions"})
+				return
+			}
+			if !staff {
+				newEntry.UserID = principal.UserID
+			}
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusCreated {
-			c.JSON(resp.StatusCode, gin.H{"error": "Failed to create waitlist entry"})
+		if _, err := store.CreateWaitlistEntry(c.Request.Context(), newEntry); err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to create waitlist entry"})
 			return
 		}
 
@@ -694,7 +597,7 @@ func createWaitlistEntry() gin.HandlerFunc {
 }
 
 // Delete waitlist entry for a specific restaurant Handler
-func deleteWaitlistEntry() gin.HandlerFunc {
+func deleteWaitlistEntry(store Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		restaurantID := c.Param("id")
 		entryID := c.Param("entry_id")
@@ -704,27 +607,8 @@ func deleteWaitlistEntry() gin.HandlerFunc {
 			return
 		}
 
-		url := fmt.Sprintf("%s/rest/v1/waitlist?restaurant_id=eq.%s&id=eq.%s", os.Getenv("SUPABASE_URL"), restaurantID, entryID)
-
-		req, err := http.NewRequest("DELETE", url, nil)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
-			return
-		}
-
-		req.Header.Set("apikey", os.Getenv("SUPABASE_ANON_KEY"))
-		req.Header.Set("Authorization", "Bearer "+os.Getenv("SUPABASE_ANON_KEY"))
-
-		clientHTTP := &http.Client{}
-		resp, err := clientHTTP.Do(req)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete waitlist entry"})
-			return
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusNoContent {
-			c.JSON(resp.StatusCode, gin.H{"error": "Failed to delete waitlist entry"})
+		if err := store.DeleteWaitlistEntry(c.Request.Context(), restaurantID, entryID); err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to delete waitlist entry"})
 			return
 		}
 
@@ -732,46 +616,195 @@ func deleteWaitlistEntry() gin.HandlerFunc {
 	}
 }
 
-func main() {
-	// Load environment variables
-	loadEnv()
-
-	// Initialize Supabase client
-	client, err := initSupabase()
-	if err != nil {
-		log.Fatalf("Error initializing Supabase client: %v", err)
+// Initialize the storage backend selected by STORAGE_DRIVER
+// ("supabase", "memory", "sqlite" or "postgres"). SQL drivers connect to DATABASE_URL.
+func initStore() (Store, error) {
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "supabase":
+		store := newSupabaseStore(os.Getenv("SUPABASE_URL"), os.Getenv("SUPABASE_ANON_KEY"))
+		store.serviceKey = os.Getenv("SUPABASE_SERVICE_ROLE_KEY")
+		switch mode := os.Getenv("SUPABASE_AUTH_MODE"); mode {
+		case "", supabaseAuthAnon:
+		case supabaseAuthForward:
+			store.authMode = mode
+		case supabaseAuthService:
+			if store.serviceKey == "" {
+				return nil, errors.New("SUPABASE_AUTH_MODE=service requires SUPABASE_SERVICE_ROLE_KEY")
+			}
+			store.authMode = mode
+		default:
+			return nil, fmt.Errorf("unknown SUPABASE_AUTH_MODE %q", mode)
+		}
+		return store, nil
+	case "memory":
+		return newMemoryStore(), nil
+	case "sqlite", "postgres":
+		dsn := os.Getenv("DATABASE_URL")
+		if dsn == "" && driver == "sqlite" {
+			dsn = "file:tabletoppers.db"
+		}
+		return openSQLStore(context.Background(), driver, dsn)
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q", driver)
 	}
+}
 
-	// Initialize Gin router
+// newRouter registers every route on a fresh Gin engine. The auth routes need a
+// Supabase client; when client is nil they are left out so the API can run offline.
+func newRouter(store Store, cfg settings, client *supabase.Client, accounts *accountMailer, verifier *tokenVerifier) *gin.Engine {
 	router := gin.Default()
 	router.Use(cors.Default())
+	router.Use(authenticate(verifier))
 
 	// User routes
-	router.POST("/register", registerHandler(client))
-	router.POST("/login", loginHandler(client))
+	if client != nil {
+		router.POST("/register", registerHandler(client.Auth, accounts.admin, store))
+		router.POST("/login", loginHandler(client.Auth))
+		router.POST("/auth/refresh", refreshHandler(client.Auth))
+		router.POST("/auth/logout", requireAuth(), logoutHandler(client.Auth))
+		router.POST("/auth/password/reset", passwordResetHandler(accounts))
+		router.POST("/auth/password/update", passwordUpdateHandler(client.Auth))
+		router.POST("/auth/verify/resend", resendVerificationHandler(accounts))
+	}
+	router.GET("/me", requireAuth(), meHandler(store))
 	router.GET("/home", homeHandler())
 
 	// Restaurant routes
-	router.GET("/restaurants", getRestaurants())
-	router.GET("/restaurants/:id", getRestaurants())
-	router.POST("/restaurants", createRestaurant())
+	router.GET("/restaurants", getRestaurants(store))
+	router.GET("/restaurants/:id", getRestaurants(store))
+	router.POST("/restaurants", requireAuth(), allowRoles(roleManager, roleAdmin), createRestaurant(store))
 	// Changed PUT to PATCH for semantic correctness with partial updates
-	router.PATCH("/restaurants/:id", updateRestaurant())
-	router.DELETE("/restaurants/:id", deleteRestaurant())
+	router.PATCH("/restaurants/:id", requireAuth(), allowRestaurantRoles(store, roleManager), updateRestaurant(store))
+	router.DELETE("/restaurants/:id", requireAuth(), allowRestaurantRoles(store, roleManager), deleteRestaurant(store))
 
 	// Table routes
-	router.GET("/restaurants/:id/tables", getTables())
-	router.GET("/restaurants/:id/tables/:table_id", getTables())
-	router.POST("/restaurants/:id/tables", createTable())
-	router.PUT("/restaurants/:id/tables/:table_id", updateTable())
-	router.DELETE("/restaurants/:id/tables/:table_id", deleteTable())
+	router.GET("/restaurants/:id/tables", getTables(store))
+	router.GET("/restaurants/:id/tables/:table_id", getTables(store))
+	router.POST("/restaurants/:id/tables", requireAuth(), allowRestaurantRoles(store, roleStaff, roleManager), createTable(store))
+	router.PUT("/restaurants/:id/tables/:table_id", requireAuth(), allowRestaurantRoles(store, roleStaff, roleManager), updateTable(store))
+	router.DELETE("/restaurants/:id/tables/:table_id", requireAuth(), allowRestaurantRoles(store, roleStaff, roleManager), deleteTable(store))
+	router.GET("/restaurants/:id/table-groups", getTableGroups(store))
+	router.POST("/restaurants/:id/table-groups", requireAuth(), allowRestaurantRoles(store, roleStaff, roleManager), createTableGroup(store))
+	router.DELETE("/restaurants/:id/table-groups/:group_id", requireAuth(), allowRestaurantRoles(store, roleStaff, roleManager), deleteTableGroup(store))
+
+	// Availability routes
+	router.GET("/restaurants/:id/availability", getAvailability(store, cfg))
+
+	// Member routes
+	router.GET("/restaurants/:id/members", requireAuth(), allowRestaurantRoles(store, roleStaff, roleManager), getMembers(store))
+	router.POST("/restaurants/:id/members", requireAuth(), allowRestaurantRoles(store, roleManager), addMember(store))
+	router.DELETE("/restaurants/:id/members/:user_id", requireAuth(), allowRestaurantRoles(store, roleManager), removeMember(store))
+
+	// Waitlist routes. Any signed-in customer may join and follow their place; staff
+	// manage the queue.
+	router.GET("/restaurants/:id/waitlist", requireAuth(), getWaitlist(store, cfg))
+	router.POST("/restaurants/:id/waitlist", requireAuth(), createWaitlistEntry(store))
+	router.PUT("/restaurants/:id/waitlist/:entry_id", requireAuth(), allowRestaurantRoles(store, roleStaff, roleManager), updateWaitlistEntry(store, cfg))
+	router.POST("/restaurants/:id/waitlist/:entry_id/seat", requireAuth(), allowRestaurantRoles(store, roleStaff, roleManager), seatWaitlistEntry(store, cfg))
+	router.DELETE("/restaurants/:id/waitlist/:entry_id", requireAuth(), allowRestaurantRoles(store, roleStaff, roleManager), deleteWaitlistEntry(store))
+	router.GET("/restaurants/:id/waitlist/offers", requireAuth(), allowRestaurantRoles(store, roleStaff, roleManager), getWaitlistOffers(store))
+	router.POST("/restaurants/:id/waitlist/offers/:offer_id/accept", requireAuth(), acceptWaitlistOffer(store, cfg))
+	router.POST("/restaurants/:id/waitlist/offers/:offer_id/decline", requireAuth(), declineWaitlistOffer(store, cfg))
+
+	// Reservation routes. Ownership is checked per reservation in the handlers.
+	router.GET("/restaurants/:id/reservations", requireAuth(), getReservations(store))
+	router.GET("/restaurants/:id/reservations/:reservation_id", requireAuth(), getReservations(store))
+	router.POST("/restaurants/:id/reservations", requireAuth(), createReservation(store, cfg))
+	router.PUT("/restaurants/:id/reservations/:reservation_id", requireAuth(), updateReservation(store, cfg))
+	router.DELETE("/restaurants/:id/reservations/:reservation_id", requireAuth(), cancelReservation(store, cfg))
+	router.GET("/restaurants/:id/reservations/:reservation_id/events", requireAuth(), getReservationEvents(store))
+	router.GET("/restaurants/:id/notifications", requireAuth(), allowRestaurantRoles(store, roleStaff, roleManager), getNotifications(store))
+	router.POST("/restaurants/:id/reservations/:reservation_id/cancel", requireAuth(), cancelReservation(store, cfg))
+	router.POST("/restaurants/:id/reservations/:reservation_id/confirm", requireAuth(), allowRestaurantRoles(store, roleStaff, roleManager), transitionReservation(store, cfg, statusConfirmed, "Reservation confirmed"))
+	router.POST("/restaurants/:id/reservations/:reservation_id/seat", requireAuth(), allowRestaurantRoles(store, roleStaff, roleManager), transitionReservation(store, cfg, statusSeated, "Reservation seated"))
+	router.POST("/restaurants/:id/reservations/:reservation_id/complete", requireAuth(), allowRestaurantRoles(store, roleStaff, roleManager), transitionReservation(store, cfg, statusCompleted, "Reservation completed"))
+	router.POST("/restaurants/:id/reservations/:reservation_id/no-show", requireAuth(), allowRestaurantRoles(store, roleStaff, roleManager), transitionReservation(store, cfg, statusNoShow, "Reservation marked as no-show"))
+
+	return router
+}
+
+func main() {
+	// Load environment variables
+	loadEnv()
+
+	// Initialize storage
+	store, err := initStore()
+	if err != nil {
+		log.Fatalf("Error initializing storage: %v", err)
+	}
+
+	// Initialize Supabase client. Only the Supabase storage driver requires one.
+	client, err := initSupabase()
+	if err != nil {
+		if driver := os.Getenv("STORAGE_DRIVER"); driver == "" || driver == "supabase" {
+			log.Fatalf("Error initializing Supabase client: %v", err)
+		}
+		log.Printf("Supabase client unavailable, auth routes disabled: %v", err)
+		client = nil
+	}
+
+	// Initialize access token verification
+	verifier, err := initVerifier()
+	if err != nil {
+		log.Fatalf("Error initializing token verification: %v", err)
+	}
 
-	// Waitlist routes
-	router.GET("/restaurants/:id/waitlist", getWaitlist())
-	router.POST("/restaurants/:id/waitlist", createWaitlistEntry())
-	router.DELETE("/restaurants/:id/waitlist/:entry_id", deleteWaitlistEntry())
+	// Initialize account emails
+	var accounts *accountMailer
+	if client != nil {
+		accounts, err = initAccountMailer(client.Auth)
+		if err != nil {
+			log.Fatalf("Error initializing mailer: %v", err)
+		}
+	}
 
+	cfg, err := initSettings()
+	if err != nil {
+		log.Fatalf("Error reading settings: %v", err)
+	}
+
+	// Tell guests about their bookings
+	notifier, err := notifications.FromEnv()
+	if err != nil {
+		log.Fatalf("Error initializing notifications: %v", err)
+	}
+	// Reminders are claimed in job_claims, which only the service role may write
+	if sb, ok := store.(*supabaseStore); ok && notifier != nil && sb.serviceKey == "" {
+		log.Fatalf("Guest notifications on Supabase require SUPABASE_SERVICE_ROLE_KEY")
+	}
+	store = withNotifications(store, notifier)
+
+	// Background jobs run until the server is told to stop
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	jobs := withServiceRole(ctx)
+
+	// Keep table statuses in step with upcoming and overrunning seatings
+	go sweepTableStatus(jobs, store, cfg, time.Minute)
+	// Offer tables that come free to waiting parties and pass on lapsed offers
+	go sweepWaitlistOffers(jobs, store, cfg, time.Minute)
+	// Remind guests of their bookings and release the tables of parties who never came
+	go sweepReservations(jobs, store, cfg, notifier, time.Minute)
+
+	router := newRouter(store, cfg, client, accounts, verifier)
+	server := &http.Server{Addr: ":8080", Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
 	fmt.Println("Server running on port 8080")
-	router.Run(":8080")
-}
 
+	// Finish the requests in flight, then send the notifications they queued
+	<-ctx.Done()
+	shutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdown); err != nil {
+		log.Printf("Shutting down the server failed: %v", err)
+	}
+	if closer, ok := store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("Closing storage failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Member links a user to a restaurant they work at. Managers run the restaurant
+// and its staff list; staff operate tables, the waitlist and reservations.
+type Member struct {
+	RestaurantID string    `json:"restaurant_id"`
+	UserID       string    `json:"user_id"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// MemberCreate struct for invite requests
+type MemberCreate struct {
+	RestaurantID string `json:"restaurant_id"`
+	UserID       string `json:"user_id" binding:"required"`
+	Role         string `json:"role" binding:"required,oneof=manager staff"`
+}
+
+// Get Members of a Restaurant Handler
+func getMembers(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		members, err := store.ListMembers(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch members"})
+			return
+		}
+
+		c.JSON(http.StatusOK, members)
+	}
+}
+
+// Add Member Handler. Inviting a user who already belongs to the restaurant is a conflict.
+func addMember(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var newMember MemberCreate
+
+		if err := c.ShouldBindJSON(&newMember); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+			return
+		}
+		newMember.RestaurantID = c.Param("id")
+
+		if _, err := store.AddMember(c.Request.Context(), newMember); err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to add member"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Member added successfully"})
+	}
+}
+
+// Remove Member Handler. The owner always stays a manager of their restaurant.
+func removeMember(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID := c.Param("id")
+		userID := c.Param("user_id")
+
+		restaurant, err := store.GetRestaurant(c.Request.Context(), restaurantID)
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to remove member"})
+			return
+		}
+		if restaurant.OwnerID == userID {
+			c.JSON(http.StatusConflict, gin.H{"error": "The restaurant owner cannot be removed"})
+			return
+		}
+
+		err = store.RemoveMember(c.Request.Context(), restaurantID, userID)
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to remove member"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migration is one versioned step of the SQL schema. Statements must run on both
+// SQLite and Postgres, so they stick to TEXT/INTEGER columns and ids generated in Go.
+type migration struct {
+	Version    int
+	Name       string
+	Statements []string
+}
+
+// migrations is applied in order by migrate. Never edit a released entry; append
+// a new version instead.
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "create restaurants, tables, waitlist and reservations",
+		Statements: []string{
+			`CREATE TABLE restaurants (
+				id            TEXT PRIMARY KEY,
+				name          TEXT NOT NULL,
+				location      TEXT NOT NULL,
+				description   TEXT NOT NULL DEFAULT '',
+				phone         TEXT NOT NULL DEFAULT '',
+				opening_hours TEXT NOT NULL DEFAULT '',
+				img           TEXT NOT NULL DEFAULT '',
+				created_at    TEXT NOT NULL
+			)`,
+			`CREATE TABLE tables (
+				id            TEXT PRIMARY KEY,
+				restaurant_id TEXT NOT NULL REFERENCES restaurants(id) ON DELETE CASCADE,
+				number        INTEGER NOT NULL,
+				min_capacity  INTEGER NOT NULL,
+				max_capacity  INTEGER NOT NULL,
+				status        TEXT NOT NULL,
+				x             INTEGER NOT NULL DEFAULT 0,
+				y             INTEGER NOT NULL DEFAULT 0,
+				UNIQUE (restaurant_id, number)
+			)`,
+			`CREATE TABLE waitlist (
+				id                  TEXT PRIMARY KEY,
+				restaurant_id       TEXT NOT NULL REFERENCES restaurants(id) ON DELETE CASCADE,
+				name                TEXT NOT NULL,
+				phone_number        TEXT NOT NULL DEFAULT '',
+				party_size          INTEGER NOT NULL,
+				party_ahead         INTEGER NOT NULL DEFAULT 0,
+				estimated_wait_time INTEGER NOT NULL DEFAULT 0,
+				created_at          TEXT NOT NULL
+			)`,
+			`CREATE TABLE reservations (
+				id            TEXT PRIMARY KEY,
+				restaurant_id TEXT NOT NULL REFERENCES restaurants(id) ON DELETE CASCADE,
+				user_id       TEXT,
+				date          TEXT NOT NULL,
+				time          TEXT NOT NULL,
+				guests        INTEGER NOT NULL,
+				status        TEXT NOT NULL DEFAULT 'pending'
+			)`,
+			`CREATE INDEX reservations_restaurant_date ON reservations (restaurant_id, date)`,
+		},
+	},
+	{
+		Version: 2,
+		Name:    "add restaurant owners and members",
+		Statements: []string{
+			`ALTER TABLE restaurants ADD COLUMN owner_id TEXT`,
+			`CREATE TABLE restaurant_members (
+				restaurant_id TEXT NOT NULL REFERENCES restaurants(id) ON DELETE CASCADE,
+				user_id       TEXT NOT NULL,
+				role          TEXT NOT NULL CHECK (role IN ('manager', 'staff')),
+				created_at    TEXT NOT NULL,
+				PRIMARY KEY (restaurant_id, user_id)
+			)`,
+			`CREATE INDEX restaurant_members_user ON restaurant_members (user_id)`,
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create profiles",
+		Statements: []string{
+			`CREATE TABLE profiles (
+				user_id      TEXT PRIMARY KEY,
+				username     TEXT NOT NULL UNIQUE,
+				email        TEXT NOT NULL,
+				phone_number TEXT NOT NULL,
+				first_name   TEXT NOT NULL,
+				last_name    TEXT NOT NULL,
+				created_at   TEXT NOT NULL
+			)`,
+		},
+	},
+	{
+		Version: 4,
+		Name:    "assign reservations to tables",
+		Statements: []string{
+			`ALTER TABLE reservations ADD COLUMN table_id TEXT REFERENCES tables(id) ON DELETE SET NULL`,
+		},
+	},
+	{
+		Version: 5,
+		Name:    "record seating durations",
+		Statements: []string{
+			`ALTER TABLE reservations ADD COLUMN duration_minutes INTEGER NOT NULL DEFAULT 0`,
+			`CREATE INDEX reservations_table_date ON reservations (table_id, date)`,
+		},
+	},
+	{
+		Version: 6,
+		Name:    "create table groups",
+		Statements: []string{
+			`CREATE TABLE table_groups (
+				id            TEXT PRIMARY KEY,
+				restaurant_id TEXT NOT NULL REFERENCES restaurants(id) ON DELETE CASCADE,
+				name          TEXT NOT NULL DEFAULT '',
+				min_capacity  INTEGER NOT NULL,
+				max_capacity  INTEGER NOT NULL
+			)`,
+			`CREATE TABLE table_group_members (
+				group_id TEXT NOT NULL REFERENCES table_groups(id) ON DELETE CASCADE,
+				table_id TEXT NOT NULL REFERENCES tables(id) ON DELETE CASCADE,
+				position INTEGER NOT NULL,
+				PRIMARY KEY (group_id, table_id)
+			)`,
+			`CREATE INDEX table_group_members_table ON table_group_members (table_id)`,
+			`ALTER TABLE reservations ADD COLUMN group_id TEXT REFERENCES table_groups(id) ON DELETE SET NULL`,
+		},
+	},
+	{
+		Version: 7,
+		Name:    "record reservation status changes",
+		Statements: []string{
+			`CREATE TABLE reservation_events (
+				id             TEXT PRIMARY KEY,
+				reservation_id TEXT NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+				restaurant_id  TEXT NOT NULL REFERENCES restaurants(id) ON DELETE CASCADE,
+				from_status    TEXT NOT NULL,
+				to_status      TEXT NOT NULL,
+				actor_id       TEXT NOT NULL DEFAULT '',
+				created_at     TEXT NOT NULL
+			)`,
+			`CREATE INDEX reservation_events_reservation ON reservation_events (reservation_id, created_at)`,
+		},
+	},
+	{
+		Version: 8,
+		Name:    "structure opening hours",
+		Statements: []string{
+			// The free-text hours are kept for reference; opening_hours and
+			// special_availability now hold JSON.
+			`ALTER TABLE restaurants RENAME COLUMN opening_hours TO opening_hours_note`,
+			`ALTER TABLE restaurants ADD COLUMN opening_hours TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE restaurants ADD COLUMN special_availability TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE restaurants ADD COLUMN timezone TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version: 9,
+		Name:    "anchor reservations to instants",
+		Statements: []string{
+			// Restaurants had no timezone until now, so existing bookings were on UTC
+			`ALTER TABLE reservations ADD COLUMN starts_at TEXT`,
+			`UPDATE reservations SET starts_at = date || 'T' || substr(time, 1, 5) || ':00.000000Z'`,
+		},
+	},
+	{
+		Version: 10,
+		Name:    "compute waitlist estimates on read",
+		Statements: []string{
+			`ALTER TABLE waitlist DROP COLUMN party_ahead`,
+			`ALTER TABLE waitlist DROP COLUMN estimated_wait_time`,
+		},
+	},
+	{
+		Version: 11,
+		Name:    "offer freed tables to the waitlist",
+		Statements: []string{
+			`ALTER TABLE waitlist ADD COLUMN user_id TEXT`,
+			`CREATE TABLE waitlist_offers (
+				id             TEXT PRIMARY KEY,
+				restaurant_id  TEXT NOT NULL REFERENCES restaurants(id) ON DELETE CASCADE,
+				entry_id       TEXT NOT NULL REFERENCES waitlist(id) ON DELETE CASCADE,
+				user_id        TEXT,
+				reservation_id TEXT NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+				status         TEXT NOT NULL,
+				expires_at     TEXT NOT NULL,
+				created_at     TEXT NOT NULL
+			)`,
+			`CREATE INDEX waitlist_offers_restaurant ON waitlist_offers (restaurant_id, created_at)`,
+		},
+	},
+	{
+		Version: 12,
+		Name:    "track waitlist entry status",
+		Statements: []string{
+			`ALTER TABLE waitlist ADD COLUMN status TEXT NOT NULL DEFAULT 'waiting'`,
+			`ALTER TABLE waitlist ADD COLUMN table_id TEXT REFERENCES tables(id) ON DELETE SET NULL`,
+			`ALTER TABLE waitlist ADD COLUMN reservation_id TEXT REFERENCES reservations(id) ON DELETE SET NULL`,
+		},
+	},
+	{
+		Version: 13,
+		Name:    "log guest notifications",
+		Statements: []string{
+			`ALTER TABLE reservations ADD COLUMN phone_number TEXT`,
+			`CREATE TABLE notifications (
+				id             TEXT PRIMARY KEY,
+				restaurant_id  TEXT NOT NULL REFERENCES restaurants(id) ON DELETE CASCADE,
+				reservation_id TEXT NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+				kind           TEXT NOT NULL,
+				channel        TEXT NOT NULL,
+				recipient      TEXT NOT NULL DEFAULT '',
+				status         TEXT NOT NULL,
+				error          TEXT NOT NULL DEFAULT '',
+				created_at     TEXT NOT NULL
+			)`,
+			`CREATE INDEX notifications_restaurant ON notifications (restaurant_id, created_at)`,
+		},
+	},
+	{
+		Version: 14,
+		Name:    "claim background jobs",
+		Statements: []string{
+			`CREATE TABLE job_claims (
+				key           TEXT PRIMARY KEY,
+				restaurant_id TEXT NOT NULL REFERENCES restaurants(id) ON DELETE CASCADE,
+				claimed_at    TEXT NOT NULL
+			)`,
+		},
+	},
+	{
+		Version: 15,
+		Name:    "find offers by held reservation",
+		Statements: []string{
+			`CREATE INDEX waitlist_offers_reservation ON waitlist_offers (reservation_id)`,
+		},
+	},
+}
+
+// migrate brings the database up to the latest migration, recording each applied
+// version in schema_migrations. It is safe to run on every start.
+func migrate(ctx context.Context, db *sql.DB, rebind func(string) string) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("error creating schema_migrations: %w", err)
+	}
+
+	applied := map[int]bool{}
+	rows, err := db.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("error reading schema_migrations: %w", err)
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, statement := range m.Statements {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`), m.Version, m.Name, timestampText(now())); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error recording migration %d: %w", m.Version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("error committing migration %d: %w", m.Version, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+// Package notifications sends guests templated messages by SMS, email and
+// webhook. Each channel is served by a pluggable Provider; the file provider
+// stands in for all of them during development and tests so no gateway is
+// needed.
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"tabletoppers/mailer"
+)
+
+// Channel is a way of reaching a guest.
+type Channel string
+
+const (
+	SMS     Channel = "sms"
+	Email   Channel = "email"
+	Webhook Channel = "webhook"
+)
+
+// Kind names the template a message is rendered from.
+type Kind string
+
+const (
+	Confirmation Kind = "confirmation"
+	Reminder     Kind = "reminder"
+	TableReady   Kind = "table_ready"
+	Cancellation Kind = "cancellation"
+)
+
+// Delivery statuses.
+const (
+	Sent   = "sent"
+	Failed = "failed"
+)
+
+// Recipient is where a guest can be reached. Empty addresses are skipped.
+type Recipient struct {
+	Phone string
+	Email string
+}
+
+// Data fills the templates. Date, Time and HoldUntil are on the restaurant's clock.
+type Data struct {
+	Name       string `json:"name,omitempty"`
+	Restaurant string `json:"restaurant"`
+	Date       string `json:"date"`
+	Time       string `json:"time"`
+	Guests     int    `json:"guests"`
+	HoldUntil  string `json:"hold_until,omitempty"` // when a table offered to the guest goes to the next party
+}
+
+// Message is one rendered notification on one channel. To is empty for
+// webhooks, whose receiver routes the message itself.
+type Message struct {
+	Kind    Kind    `json:"kind"`
+	Channel Channel `json:"channel"`
+	To      string  `json:"to,omitempty"`
+	Subject string  `json:"subject,omitempty"`
+	Body    string  `json:"body"`
+	Data    Data    `json:"data"`
+}
+
+// Provider delivers messages on one channel.
+type Provider interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Delivery is the outcome of sending one message.
+type Delivery struct {
+	Message
+	Status string
+	Error  string
+}
+
+type messageTemplate struct {
+	subject, body *template.Template
+}
+
+func newTemplate(kind Kind, subject, body string) messageTemplate {
+	return messageTemplate{
+		subject: template.Must(template.New(string(kind) + " subject").Parse(subject)),
+		body:    template.Must(template.New(string(kind)).Parse(body)),
+	}
+}
+
+// Bodies stay short enough for a single SMS.
+var templates = map[Kind]messageTemplate{
+	Confirmation: newTemplate(Confirmation,
+		"Your table at {{.Restaurant}} is confirmed",
+		"{{if .Name}}Hi {{.Name}}, y{{else}}Y{{end}}our table for {{.Guests}} at {{.Restaurant}} on {{.Date}} at {{.Time}} is confirmed."),
+	Reminder: newTemplate(Reminder,
+		"Reminder: your table at {{.Restaurant}}",
+		"{{if .Name}}Hi {{.Name}}, a{{else}}A{{end}} reminder of your table for {{.Guests}} at {{.Restaurant}} on {{.Date}} at {{.Time}}."),
+	TableReady: newTemplate(TableReady,
+		"Your table at {{.Restaurant}} is ready",
+		"{{if .Name}}Hi {{.Name}}, a{{else}}A{{end}} table for {{.Guests}} is ready for you at {{.Restaurant}}. Accept it by {{.HoldUntil}} or it goes to the next party."),
+	Cancellation: newTemplate(Cancellation,
+		"Your reservation at {{.Restaurant}} is cancelled",
+		"{{if .Name}}Hi {{.Name}}, y{{else}}Y{{end}}our reservation for {{.Guests}} at {{.Restaurant}} on {{.Date}} at {{.Time}} has been cancelled."),
+}
+
+// Render fills the templates of kind for channel. Only email has a subject.
+func Render(kind Kind, channel Channel, data Data) (Message, error) {
+	t, ok := templates[kind]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown notification kind %q", kind)
+	}
+	msg := Message{Kind: kind, Channel: channel, Data: data}
+	var body strings.Builder
+	if err := t.body.Execute(&body, data); err != nil {
+		return Message{}, err
+	}
+	msg.Body = body.String()
+	if channel != SMS {
+		var subject strings.Builder
+		if err := t.subject.Execute(&subject, data); err != nil {
+			return Message{}, err
+		}
+		msg.Subject = subject.String()
+	}
+	return msg, nil
+}
+
+// Notifier sends each notification on every channel it has a provider for and
+// the recipient has an address on. Webhooks get every notification.
+type Notifier struct {
+	providers map[Channel]Provider
+}
+
+// New returns a Notifier sending through providers.
+func New(providers map[Channel]Provider) *Notifier {
+	return &Notifier{providers: providers}
+}
+
+// channels are tried in this order.
+var channels = []Channel{SMS, Email, Webhook}
+
+// Send renders kind and sends it to to, reporting one Delivery per message. A
+// failed message does not stop the others; the error is only for a kind that
+// cannot be rendered.
+func (n *Notifier) Send(ctx context.Context, kind Kind, to Recipient, data Data) ([]Delivery, error) {
+	var deliveries []Delivery
+	for _, channel := range channels {
+		provider, ok := n.providers[channel]
+		if !ok {
+			continue
+		}
+		address := ""
+		switch channel {
+		case SMS:
+			address = to.Phone
+		case Email:
+			address = to.Email
+		}
+		if address == "" && channel != Webhook {
+			continue
+		}
+
+		msg, err := Render(kind, channel, data)
+		if err != nil {
+			return deliveries, err
+		}
+		msg.To = address
+		delivery := Delivery{Message: msg, Status: Sent}
+		if err := provider.Send(ctx, msg); err != nil {
+			delivery.Status, delivery.Error = Failed, err.Error()
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// FromEnv builds the notifier selected by NOTIFICATIONS. "file" writes every
+// message into NOTIFICATIONS_DIR (default "outbox"). Otherwise email goes
+// through the mailer selected by MAILER, SMS through Twilio when
+// TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM are set, and webhooks to
+// NOTIFICATIONS_WEBHOOK_URL. Without any provider it returns nil, meaning
+// guests are not notified.
+func FromEnv() (*Notifier, error) {
+	providers := map[Channel]Provider{}
+	switch kind := os.Getenv("NOTIFICATIONS"); kind {
+	case "file":
+		dir := os.Getenv("NOTIFICATIONS_DIR")
+		if dir == "" {
+			dir = "outbox"
+		}
+		files, err := NewFileProvider(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, channel := range channels {
+			providers[channel] = files
+		}
+	case "":
+		m, err := mailer.FromEnv()
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			providers[Email] = &MailProvider{Mailer: m}
+		}
+		sid, token, from := os.Getenv("TWILIO_ACCOUNT_SID"), os.Getenv("TWILIO_AUTH_TOKEN"), os.Getenv("TWILIO_FROM")
+		if sid != "" && token != "" && from != "" {
+			providers[SMS] = &TwilioProvider{AccountSID: sid, AuthToken: token, From: from}
+		}
+		if url := os.Getenv("NOTIFICATIONS_WEBHOOK_URL"); url != "" {
+			providers[Webhook] = &WebhookProvider{URL: url}
+		}
+	default:
+		return nil, fmt.Errorf("unknown NOTIFICATIONS %q", kind)
+	}
+
+	if len(providers) == 0 {
+		return nil, nil
+	}
+	return New(providers), nil
+}
+
+// requestTimeout bounds calls to SMS gateways and webhooks.
+const requestTimeout = 10 * time.Second
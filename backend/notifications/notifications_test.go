@@ -0,0 +1,170 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingProvider struct {
+	sent []Message
+	err  error
+}
+
+func (p *recordingProvider) Send(ctx context.Context, msg Message) error {
+	p.sent = append(p.sent, msg)
+	return p.err
+}
+
+var dinner = Data{Name: "Ann", Restaurant: "Luigi's", Date: "2025-05-01", Time: "19:00", Guests: 2}
+
+func TestRender(t *testing.T) {
+	msg, err := Render(Confirmation, Email, dinner)
+	require.NoError(t, err)
+	assert.Equal(t, "Your table at Luigi's is confirmed", msg.Subject)
+	assert.Equal(t, "Hi Ann, your table for 2 at Luigi's on 2025-05-01 at 19:00 is confirmed.", msg.Body)
+
+	ready := dinner
+	ready.Name, ready.HoldUntil = "", "18:40"
+	msg, err = Render(TableReady, SMS, ready)
+	require.NoError(t, err)
+	assert.Empty(t, msg.Subject, "texts have no subject")
+	assert.Equal(t, "A table for 2 is ready for you at Luigi's. Accept it by 18:40 or it goes to the next party.", msg.Body)
+
+	for _, kind := range []Kind{Reminder, Cancellation} {
+		msg, err := Render(kind, Webhook, dinner)
+		require.NoError(t, err)
+		assert.NotEmpty(t, msg.Subject)
+		assert.Contains(t, msg.Body, "Luigi's")
+	}
+
+	_, err = Render("birthday", Email, dinner)
+	assert.Error(t, err)
+}
+
+func TestNotifier_Send(t *testing.T) {
+	sms := &recordingProvider{err: errors.New("gateway down")}
+	email := &recordingProvider{}
+	webhook := &recordingProvider{}
+	n := New(map[Channel]Provider{SMS: sms, Email: email, Webhook: webhook})
+
+	deliveries, err := n.Send(context.Background(), Cancellation, Recipient{Phone: "+15550100", Email: "ann@example.com"}, dinner)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 3)
+	assert.Equal(t, SMS, deliveries[0].Channel)
+	assert.Equal(t, "+15550100", deliveries[0].To)
+	assert.Equal(t, Failed, deliveries[0].Status)
+	assert.Equal(t, "gateway down", deliveries[0].Error)
+	assert.Equal(t, Sent, deliveries[1].Status, "a failed text does not stop the email")
+	assert.Equal(t, "ann@example.com", email.sent[0].To)
+	assert.Empty(t, webhook.sent[0].To)
+
+	deliveries, err = n.Send(context.Background(), Reminder, Recipient{}, dinner)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1, "guests without an address are only reported to the webhook")
+	assert.Equal(t, Webhook, deliveries[0].Channel)
+}
+
+func TestFileProvider_WritesMessages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	p, err := NewFileProvider(dir)
+	require.NoError(t, err)
+	msg, err := Render(Confirmation, SMS, dinner)
+	require.NoError(t, err)
+	msg.To = "+15550100"
+
+	require.NoError(t, p.Send(context.Background(), msg))
+	require.NoError(t, p.Send(context.Background(), msg))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	content, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	var written Message
+	require.NoError(t, json.Unmarshal(content, &written))
+	assert.Equal(t, msg, written)
+}
+
+func TestTwilioProvider(t *testing.T) {
+	var form url.Values
+	var user, pass string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/2010-04-01/Accounts/AC1/Messages.json", r.URL.Path)
+		user, pass, _ = r.BasicAuth()
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+	p := &TwilioProvider{AccountSID: "AC1", AuthToken: "secret", From: "+15550000", BaseURL: server.URL}
+
+	require.NoError(t, p.Send(context.Background(), Message{To: "+15550100", Body: "Hello"}))
+
+	assert.Equal(t, "AC1", user)
+	assert.Equal(t, "secret", pass)
+	assert.Equal(t, "+15550100", form.Get("To"))
+	assert.Equal(t, "+15550000", form.Get("From"))
+	assert.Equal(t, "Hello", form.Get("Body"))
+}
+
+func TestWebhookProvider(t *testing.T) {
+	var got Message
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &got))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	p := &WebhookProvider{URL: server.URL}
+	msg, err := Render(TableReady, Webhook, dinner)
+	require.NoError(t, err)
+
+	require.NoError(t, p.Send(context.Background(), msg))
+	assert.Equal(t, msg, got)
+
+	status = http.StatusInternalServerError
+	assert.Error(t, p.Send(context.Background(), msg))
+}
+
+func TestFromEnv(t *testing.T) {
+	for _, key := range []string{"NOTIFICATIONS", "MAILER", "TWILIO_ACCOUNT_SID", "TWILIO_AUTH_TOKEN", "TWILIO_FROM", "NOTIFICATIONS_WEBHOOK_URL"} {
+		t.Setenv(key, "")
+	}
+	n, err := FromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, n)
+
+	t.Setenv("NOTIFICATIONS", "file")
+	t.Setenv("NOTIFICATIONS_DIR", t.TempDir())
+	n, err = FromEnv()
+	require.NoError(t, err)
+	require.NotNil(t, n)
+	assert.Len(t, n.providers, 3)
+
+	t.Setenv("NOTIFICATIONS", "")
+	t.Setenv("TWILIO_ACCOUNT_SID", "AC1")
+	t.Setenv("TWILIO_AUTH_TOKEN", "secret")
+	t.Setenv("TWILIO_FROM", "+15550000")
+	t.Setenv("NOTIFICATIONS_WEBHOOK_URL", "https://example.com/hook")
+	n, err = FromEnv()
+	require.NoError(t, err)
+	assert.IsType(t, &TwilioProvider{}, n.providers[SMS])
+	assert.IsType(t, &WebhookProvider{}, n.providers[Webhook])
+	assert.NotContains(t, n.providers, Email)
+
+	t.Setenv("NOTIFICATIONS", "carrier-pigeon")
+	_, err = FromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"tabletoppers/mailer"
+)
+
+// FileProvider writes every message as a JSON file into Dir, so tests and local
+// runs can read what guests would have been sent.
+type FileProvider struct {
+	Dir string
+	seq atomic.Int64
+}
+
+// NewFileProvider creates dir if needed and returns a FileProvider writing into it.
+func NewFileProvider(dir string) (*FileProvider, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating notifications directory: %w", err)
+	}
+	return &FileProvider{Dir: dir}, nil
+}
+
+func (p *FileProvider) Send(ctx context.Context, msg Message) error {
+	content, err := json.MarshalIndent(msg, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%04d-%s-%s.json", time.Now().UTC().Format("20060102T150405"), p.seq.Add(1), msg.Channel, msg.Kind)
+	return os.WriteFile(filepath.Join(p.Dir, name), content, 0o644)
+}
+
+// MailProvider sends email through a mailer.
+type MailProvider struct {
+	Mailer mailer.Mailer
+}
+
+func (p *MailProvider) Send(ctx context.Context, msg Message) error {
+	return p.Mailer.Send(ctx, mailer.Message{To: msg.To, Subject: msg.Subject, Body: msg.Body})
+}
+
+// TwilioProvider sends SMS through the Twilio Messages API. BaseURL and Client
+// default to Twilio's API and a client with requestTimeout.
+type TwilioProvider struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	BaseURL    string
+	Client     *http.Client
+}
+
+func (p *TwilioProvider) Send(ctx context.Context, msg Message) error {
+	base := p.BaseURL
+	if base == "" {
+		base = "https://api.twilio.com"
+	}
+	form := url.Values{"To": {msg.To}, "From": {p.From}, "Body": {msg.Body}}
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", base, url.PathEscape(p.AccountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.AccountSID, p.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return do(p.Client, req)
+}
+
+// WebhookProvider posts every message as JSON to URL.
+type WebhookProvider struct {
+	URL    string
+	Client *http.Client
+}
+
+func (p *WebhookProvider) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return do(p.Client, req)
+}
+
+// do sends req and fails on any status outside 2xx.
+func do(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered with status %d", req.URL.Host, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"tabletoppers/notifications"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Notification records one message sent to a guest about a reservation and
+// whether it went out.
+type Notification struct {
+	ID            string    `json:"id"`
+	RestaurantID  string    `json:"restaurant_id"`
+	ReservationID string    `json:"reservation_id"`
+	Kind          string    `json:"kind"`
+	Channel       string    `json:"channel"`
+	Recipient     string    `json:"recipient"` // phone number or email address; empty for webhooks
+	Status        string    `json:"status"`
+	Error         string    `json:"error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// notifyingStore tells guests when a write through it confirms or cancels their
+// reservation or offers them a table from the waitlist, and records every
+// delivery. Wrapping the store reaches the handlers and the sweepers alike.
+// Messages are queued once the write has returned and sent one at a time in the
+// background, so a slow provider holds up later messages but never a write.
+// When the queue is full further messages are logged and dropped.
+type notifyingStore struct {
+	Store
+	notifier *notifications.Notifier
+
+	mu     sync.Mutex // guards closed and sends on queue
+	closed bool
+	queue  chan func()
+	done   chan struct{} // closed once run has sent everything queued
+}
+
+// notifyQueueSize is how many messages may wait to be sent.
+const notifyQueueSize = 256
+
+// withNotifications wraps store so guests are notified through notifier. A nil
+// notifier leaves store as it is.
+func withNotifications(store Store, notifier *notifications.Notifier) Store {
+	if notifier == nil {
+		return store
+	}
+	return newNotifyingStore(store, notifier, notifyQueueSize)
+}
+
+// newNotifyingStore wraps store with a queue of size messages and starts
+// sending them.
+func newNotifyingStore(store Store, notifier *notifications.Notifier, size int) *notifyingStore {
+	s := &notifyingStore{Store: store, notifier: notifier, queue: make(chan func(), size), done: make(chan struct{})}
+	go s.run()
+	return s
+}
+
+// run sends queued messages in the order their writes happened.
+func (s *notifyingStore) run() {
+	defer close(s.done)
+	for send := range s.queue {
+		send()
+	}
+}
+
+// enqueue hands send to the background sender without waiting for room. The
+// context it gets keeps the values of ctx but not its deadline, since the
+// request that made the write is usually over by the time the message goes out.
+func (s *notifyingStore) enqueue(ctx context.Context, send func(ctx context.Context)) {
+	ctx = withServiceRole(context.WithoutCancel(ctx))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		log.Printf("Dropping a guest notification: notifications are shut down")
+		return
+	}
+	select {
+	case s.queue <- func() { send(ctx) }:
+	default:
+		log.Printf("Dropping a guest notification: %d are already waiting to be sent", cap(s.queue))
+	}
+}
+
+// Close stops taking messages, waits until those queued have been sent and
+// recorded, then closes the wrapped store if it can be closed.
+func (s *notifyingStore) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	<-s.done
+	if closer, ok := s.Store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (s *notifyingStore) CreateReservation(ctx context.Context, reservation ReservationCreate) (*Reservation, error) {
+	created, err := s.Store.CreateReservation(ctx, reservation)
+	if err == nil && created.Status == statusConfirmed {
+		r := *created
+		s.enqueue(ctx, func(ctx context.Context) {
+			notifyGuest(ctx, s.Store, s.notifier, notifications.Confirmation, r, "", time.Time{})
+		})
+	}
+	return created, err
+}
+
+func (s *notifyingStore) TransitionReservation(ctx context.Context, transition ReservationTransition) (*Reservation, error) {
+	moved, err := s.Store.TransitionReservation(ctx, transition)
+	if err != nil {
+		return moved, err
+	}
+	r := *moved
+	switch transition.To {
+	case statusConfirmed:
+		s.enqueue(ctx, func(ctx context.Context) {
+			notifyGuest(ctx, s.Store, s.notifier, notifications.Confirmation, r, "", time.Time{})
+		})
+	case statusCancelled:
+		s.enqueue(ctx, func(ctx context.Context) {
+			if !s.heldForOffer(ctx, r) {
+				notifyGuest(ctx, s.Store, s.notifier, notifications.Cancellation, r, "", time.Time{})
+			}
+		})
+	}
+	return moved, nil
+}
+
+func (s *notifyingStore) CreateWaitlistOffer(ctx context.Context, offer WaitlistOfferCreate) (*WaitlistOffer, error) {
+	created, err := s.Store.CreateWaitlistOffer(ctx, offer)
+	if err != nil {
+		return created, err
+	}
+	o := *created
+	s.enqueue(ctx, func(ctx context.Context) {
+		held, err := s.Store.GetReservation(ctx, o.RestaurantID, o.ReservationID)
+		if err != nil {
+			log.Printf("Failed to load the reservation held for offer %s: %v", o.ID, err)
+			return
+		}
+		name := ""
+		if entry, err := s.Store.GetWaitlistEntry(ctx, o.RestaurantID, o.EntryID); err == nil {
+			name = entry.Name
+		}
+		notifyGuest(ctx, s.Store, s.notifier, notifications.TableReady, *held, name, o.ExpiresAt)
+	})
+	return created, nil
+}
+
+// heldForOffer reports whether r holds a table offered to the waitlist that was
+// never accepted. Releasing such a hold is not a cancellation the guest made.
+func (s *notifyingStore) heldForOffer(ctx context.Context, r Reservation) bool {
+	offer, err := s.Store.GetWaitlistOfferByReservation(ctx, r.RestaurantID, r.ID)
+	return err == nil && offer.Status != offerAccepted
+}
+
+// notifyGuest sends kind to the guest of r and records each delivery in store.
+// The guest is reached at the reservation's phone number and their profile's
+// email and number. name greets guests without a profile; holdUntil is set for
+// offers. Failures are logged; the error only says that nothing reached the
+// guest, for callers that can try again.
+func notifyGuest(ctx context.Context, store Store, notifier *notifications.Notifier, kind notifications.Kind, r Reservation, name string, holdUntil time.Time) error {
+	ctx = withServiceRole(ctx)
+	restaurant, err := store.GetRestaurant(ctx, r.RestaurantID)
+	if err != nil {
+		log.Printf("Failed to load restaurant %s to notify about reservation %s: %v", r.RestaurantID, r.ID, err)
+		return err
+	}
+	to := notifications.Recipient{Phone: r.PhoneNumber}
+	data := notifications.Data{Name: name, Restaurant: restaurant.Name, Date: r.Date, Time: r.Time, Guests: r.Guests}
+	if r.UserID != "" {
+		if profile, err := store.GetProfile(ctx, r.UserID); err == nil {
+			to.Email = profile.Email
+			if to.Phone == "" {
+				to.Phone = profile.PhoneNumber
+			}
+			if data.Name == "" {
+				data.Name = profile.FirstName
+			}
+		}
+	}
+	if !holdUntil.IsZero() {
+		data.HoldUntil = holdUntil.In(restaurant.location()).Format(timeLayout)
+	}
+
+	deliveries, err := notifier.Send(ctx, kind, to, data)
+	if err != nil {
+		log.Printf("Failed to notify about reservation %s: %v", r.ID, err)
+	}
+	delivered := false
+	for _, d := range deliveries {
+		delivered = delivered || d.Status == notifications.Sent
+		_, err := store.RecordNotification(ctx, Notification{
+			RestaurantID:  r.RestaurantID,
+			ReservationID: r.ID,
+			Kind:          string(d.Kind),
+			Channel:       string(d.Channel),
+			Recipient:     d.To,
+			Status:        d.Status,
+			Error:         d.Error,
+		})
+		if err != nil {
+			log.Printf("Failed to record %s %s for reservation %s: %v", d.Channel, d.Kind, r.ID, err)
+		}
+	}
+	if !delivered && (err != nil || len(deliveries) > 0) {
+		return fmt.Errorf("%s for reservation %s was not delivered", kind, r.ID)
+	}
+	return nil
+}
+
+// Get Notifications Handler. Lists the messages sent to a restaurant's guests,
+// oldest first, timed on the restaurant's clock.
+func getNotifications(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, store, c.Param("id"))
+		if !ok {
+			return
+		}
+
+		sent, err := store.ListNotifications(c.Request.Context(), restaurant.ID)
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch notifications"})
+			return
+		}
+
+		loc := restaurant.location()
+		for i := range sent {
+			sent[i].CreatedAt = sent[i].CreatedAt.In(loc)
+		}
+		c.JSON(http.StatusOK, sent)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"tabletoppers/notifications"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outbox is a notifications.Provider that keeps what it is sent.
+type outbox struct {
+	sent []notifications.Message
+}
+
+func (o *outbox) Send(ctx context.Context, msg notifications.Message) error {
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+// flush waits until every message queued so far has been sent and recorded:
+// messages go out in order, so once a marker queued after them runs they have.
+func (s *notifyingStore) flush() {
+	sent := make(chan struct{})
+	s.enqueue(context.Background(), func(context.Context) { close(sent) })
+	<-sent
+}
+
+// take returns the messages sent since the last call.
+func (o *outbox) take() []notifications.Message {
+	sent := o.sent
+	o.sent = nil
+	return sent
+}
+
+func TestRouter_Notifications(t *testing.T) {
+	ctx := context.Background()
+	memory := newMemoryStore()
+	messages := &outbox{}
+	store := withNotifications(memory, notifications.New(map[notifications.Channel]notifications.Provider{
+		notifications.SMS:   messages,
+		notifications.Email: messages,
+	})).(*notifyingStore)
+	cfg := defaultSettings()
+	router := newRouter(store, cfg, nil, nil, testVerifier())
+	restaurant, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", Location: "X", OwnerID: "manager-1"})
+	require.NoError(t, err)
+	_, err = store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: tableAvailable})
+	require.NoError(t, err)
+	_, err = store.CreateProfile(ctx, Profile{UserID: "carol", Username: "carol", Email: "carol@example.com", FirstName: "Carol"})
+	require.NoError(t, err)
+	manager := testToken(t, "manager-1", roleManager)
+	carol := testToken(t, "carol", roleCustomer)
+	base := "/restaurants/" + restaurant.ID
+	delivered := func() []notifications.Message {
+		store.flush()
+		return messages.take()
+	}
+
+	// Carol books with a number to text; nothing is sent until staff confirm
+	tomorrow := time.Now().AddDate(0, 0, 1).Format(dateLayout)
+	rr := serve(router, carol, http.MethodPost, base+"/reservations", ReservationCreate{Date: tomorrow, Time: "19:00", Guests: 2, PhoneNumber: "+15550100"})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var created struct {
+		Reservation Reservation `json:"reservation"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	assert.Empty(t, delivered(), "pending bookings are not confirmed yet")
+
+	rr = serve(router, manager, http.MethodPost, base+"/reservations/"+created.Reservation.ID+"/confirm", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	sent := delivered()
+	require.Len(t, sent, 2)
+	assert.Equal(t, notifications.Confirmation, sent[0].Kind)
+	assert.Equal(t, "+15550100", sent[0].To, "the booking's number beats the profile")
+	assert.Equal(t, "carol@example.com", sent[1].To)
+	assert.Equal(t, "Hi Carol, your table for 2 at Diner on "+tomorrow+" at 19:00 is confirmed.", sent[1].Body)
+
+	rr = serve(router, carol, http.MethodPost, base+"/reservations/"+created.Reservation.ID+"/cancel", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	sent = delivered()
+	require.Len(t, sent, 2)
+	assert.Equal(t, notifications.Cancellation, sent[0].Kind)
+
+	// A walk-in on the waitlist is texted when a table is held for them
+	_, err = store.CreateWaitlistEntry(ctx, WaitlistEntryCreate{RestaurantID: restaurant.ID, Name: "Dee", PhoneNumber: "+15550199", PartySize: 3})
+	require.NoError(t, err)
+	now := time.Now()
+	require.NoError(t, offerTables(ctx, store, cfg, restaurant.ID, now))
+	sent = delivered()
+	require.Len(t, sent, 1)
+	assert.Equal(t, notifications.TableReady, sent[0].Kind)
+	assert.Equal(t, "+15550199", sent[0].To)
+	assert.Contains(t, sent[0].Body, "Hi Dee")
+	assert.Contains(t, sent[0].Body, now.Add(cfg.OfferHold).UTC().Format(timeLayout))
+
+	require.NoError(t, expireOffers(ctx, store, cfg, restaurant.ID, now.Add(cfg.OfferHold+time.Minute)))
+	assert.Empty(t, delivered(), "releasing a lapsed hold is not a cancellation")
+
+	rr = serve(router, carol, http.MethodGet, base+"/notifications", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = serve(router, manager, http.MethodGet, base+"/notifications", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var logged []Notification
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &logged))
+	require.Len(t, logged, 5)
+	assert.Equal(t, created.Reservation.ID, logged[0].ReservationID)
+	assert.Equal(t, []string{"sms", "sent"}, []string{logged[0].Channel, logged[0].Status})
+	assert.Equal(t, "table_ready", logged[4].Kind)
+}
+
+// stalled is a notifications.Provider that tells started about each message
+// and does not answer until released.
+type stalled struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s stalled) Send(ctx context.Context, msg notifications.Message) error {
+	s.started <- struct{}{}
+	<-s.release
+	return nil
+}
+
+func TestNotifyingStore_WritesDoNotWaitForProviders(t *testing.T) {
+	ctx := context.Background()
+	memory := newMemoryStore()
+	provider := stalled{started: make(chan struct{}, 3), release: make(chan struct{})}
+	store := newNotifyingStore(memory, notifications.New(map[notifications.Channel]notifications.Provider{notifications.SMS: provider}), 1)
+	restaurant, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", Location: "X"})
+	require.NoError(t, err)
+	confirm := func(clock string) {
+		t.Helper()
+		pending, err := store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, Date: "2025-06-01", Time: clock, Guests: 2, Status: statusPending, PhoneNumber: "+15550100"})
+		require.NoError(t, err)
+		done := make(chan error, 1)
+		go func() {
+			_, err := store.TransitionReservation(ctx, ReservationTransition{RestaurantID: restaurant.ID, ReservationID: pending.ID, From: statusPending, To: statusConfirmed})
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("confirming waited for the SMS provider")
+		}
+	}
+
+	// The first message is being sent, the second waits and the queue is full,
+	// so the third is dropped rather than holding up its write
+	confirm("17:00")
+	<-provider.started
+	confirm("19:00")
+	confirm("21:00")
+
+	// Closing sends what was queued before returning
+	close(provider.release)
+	require.NoError(t, store.Close())
+	logged, err := memory.ListNotifications(ctx, restaurant.ID)
+	require.NoError(t, err)
+	require.Len(t, logged, 2)
+	assert.Equal(t, "sent", logged[1].Status)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpeningInterval is one stretch of service, in HH:MM wall-clock time of the
+// restaurant. A Close at or before Open runs past midnight. LastSeating is the
+// latest start accepted for a seating; without one, seatings must end by Close.
+type OpeningInterval struct {
+	Open        string `json:"open"`
+	Close       string `json:"close"`
+	LastSeating string `json:"last_seating,omitempty"`
+}
+
+// OpeningHours is a restaurant's weekly schedule, keyed by lowercase weekday
+// name. Days left out are closed. A restaurant without opening hours takes
+// reservations at any time.
+type OpeningHours map[string][]OpeningInterval
+
+// Special availability statuses.
+const (
+	specialOpen    = "open"
+	specialClosed  = "closed"
+	specialLimited = "limited"
+)
+
+// SpecialAvailability overrides the weekly schedule on one date: closed for a
+// holiday or private event, or open only for Hours, such as a limited service or
+// an opening on a day the restaurant is usually closed.
+type SpecialAvailability struct {
+	Date   string            `json:"date"`
+	Reason string            `json:"reason"`
+	Status string            `json:"status"`
+	Hours  []OpeningInterval `json:"hours,omitempty"`
+}
+
+// weekdays names the days of the week as OpeningHours keys, indexed by time.Weekday.
+var weekdays = [...]string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// hoursOn returns the intervals the restaurant is open on day and the reason
+// when a special availability entry decides them. scheduled is false when
+// neither the entries nor the weekly schedule say anything about day.
+func (r *Restaurant) hoursOn(day time.Time) (hours []OpeningInterval, reason string, scheduled bool) {
+	date := day.Format(dateLayout)
+	for _, special := range r.SpecialAvailability {
+		if special.Date != date {
+			continue
+		}
+		if special.Status == specialClosed {
+			return nil, special.Reason, true
+		}
+		return special.Hours, special.Reason, true
+	}
+	if len(r.OpeningHours) == 0 {
+		return nil, "", false
+	}
+	return r.OpeningHours[weekdays[day.Weekday()]], "", true
+}
+
+// window returns when an interval opening on day opens and the last start time
+// it accepts for a seating of duration. ok is false if the clock times do not
+// parse or no seating fits.
+func (in OpeningInterval) window(day time.Time, duration time.Duration) (open, last time.Time, ok bool) {
+	open, err := parseSlot(day.Format(dateLayout), in.Open)
+	if err != nil {
+		return open, last, false
+	}
+	afterOpen := func(clock string) (time.Time, error) {
+		t, err := parseSlot(day.Format(dateLayout), clock)
+		if err == nil && t.Before(open) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, err
+	}
+	closing, err := afterOpen(in.Close)
+	if err != nil {
+		return open, last, false
+	}
+	if closing.Equal(open) {
+		closing = closing.AddDate(0, 0, 1)
+	}
+	last = closing.Add(-duration)
+	if in.LastSeating != "" {
+		if last, err = afterOpen(in.LastSeating); err != nil {
+			return open, last, false
+		}
+	}
+	return open, last, !last.Before(open)
+}
+
+// seatingOpen reports whether a seating of duration may start at start: between
+// the opening and last seating time of an interval of its day, or of one of the
+// day before that runs past midnight. Days without a schedule are always open.
+func (r *Restaurant) seatingOpen(start time.Time, duration time.Duration) bool {
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	if _, _, scheduled := r.hoursOn(day); !scheduled {
+		return true
+	}
+	for _, d := range []time.Time{day.AddDate(0, 0, -1), day} {
+		hours, _, _ := r.hoursOn(d)
+		for _, in := range hours {
+			open, last, ok := in.window(d, duration)
+			if ok && !start.Before(open) && !start.After(last) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkOpen checks that the restaurant takes a seating starting at start,
+// answering 400 when it is closed then. On failure the response has been
+// written and ok is false.
+func checkOpen(c *gin.Context, restaurant *Restaurant, cfg settings, start time.Time) (ok bool) {
+	local := wallClock(start.In(restaurant.location()))
+	if restaurant.seatingOpen(local, cfg.DiningDuration) {
+		return true
+	}
+
+	hours, reason, _ := restaurant.hoursOn(local)
+	if len(hours) > 0 {
+		validationFailed(c, map[string]string{"time": "must be within opening hours and no later than the last seating"})
+		return false
+	}
+	message := "the restaurant is closed on this date"
+	if reason != "" {
+		message += ": " + reason
+	}
+	validationFailed(c, map[string]string{"date": message})
+	return false
+}
+
+// validClock reports whether clock is an HH:MM time.
+func validClock(clock string) bool {
+	_, err := time.Parse(timeLayout, clock)
+	return err == nil
+}
+
+// validateIntervals checks a day's intervals, adding errors to fields under key.
+func validateIntervals(fields map[string]string, key string, hours []OpeningInterval) {
+	for i, in := range hours {
+		at := fmt.Sprintf("%s[%d]", key, i)
+		if !validClock(in.Open) || !validClock(in.Close) {
+			fields[at] = "open and close must be times in HH:MM format"
+			continue
+		}
+		if in.LastSeating == "" {
+			continue
+		}
+		if !validClock(in.LastSeating) {
+			fields[at] = "last_seating must be a time in HH:MM format"
+			continue
+		}
+		day := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		_, last, _ := in.window(day, 0)
+		_, closing, _ := OpeningInterval{Open: in.Open, Close: in.Close}.window(day, 0)
+		if last.After(closing) {
+			fields[at] = "last_seating must fall between open and close"
+		}
+	}
+}
+
+// validateSchedule checks a restaurant's timezone, opening hours and special
+// availability, returning errors by field. Entries left nil are not checked.
+func validateSchedule(timezone string, hours OpeningHours, special []SpecialAvailability) map[string]string {
+	fields := map[string]string{}
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil || timezone == "Local" {
+			fields["timezone"] = "must be an IANA time zone such as Europe/Paris"
+		}
+	}
+	for day, intervals := range hours {
+		key := "opening_hours." + day
+		if !slices.Contains(weekdays[:], day) {
+			fields[key] = "must be a day of the week: " + strings.Join(weekdays[:], ", ")
+			continue
+		}
+		validateIntervals(fields, key, intervals)
+	}
+	seen := map[string]bool{}
+	for i, s := range special {
+		key := fmt.Sprintf("special_availability[%d]", i)
+		if _, err := time.Parse(dateLayout, s.Date); err != nil {
+			fields[key+".date"] = "must be a date in YYYY-MM-DD format"
+		} else if seen[s.Date] {
+			fields[key+".date"] = "is listed more than once"
+		}
+		seen[s.Date] = true
+		switch s.Status {
+		case specialClosed:
+			if len(s.Hours) > 0 {
+				fields[key+".hours"] = "must be empty when closed"
+			}
+		case specialOpen, specialLimited:
+			if len(s.Hours) == 0 {
+				fields[key+".hours"] = "must list the hours the restaurant is open"
+			}
+			validateIntervals(fields, key+".hours", s.Hours)
+		default:
+			fields[key+".status"] = "must be open, closed or limited"
+		}
+	}
+	return fields
+}
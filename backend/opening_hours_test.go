@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bistro opens twice on Mondays and late on Fridays, with a few special dates.
+var bistro = Restaurant{
+	OpeningHours: OpeningHours{
+		"monday": {{Open: "11:00", Close: "15:00", LastSeating: "14:00"}, {Open: "18:00", Close: "22:00"}},
+		"friday": {{Open: "18:00", Close: "01:00"}},
+	},
+	SpecialAvailability: []SpecialAvailability{
+		{Date: "2025-05-12", Reason: "Private event", Status: specialClosed},
+		{Date: "2025-05-19", Reason: "Short staffed", Status: specialLimited, Hours: []OpeningInterval{{Open: "18:00", Close: "21:00"}}},
+		{Date: "2025-05-18", Reason: "Mother's day", Status: specialOpen, Hours: []OpeningInterval{{Open: "12:00", Close: "16:00"}}},
+	},
+}
+
+func TestSeatingOpen(t *testing.T) {
+	cases := []struct {
+		date, clock string
+		open        bool
+	}{
+		{"2025-05-05", "11:00", true},
+		{"2025-05-05", "10:30", false},
+		{"2025-05-05", "14:00", true},
+		{"2025-05-05", "14:30", false}, // after the last seating
+		{"2025-05-05", "20:30", true},
+		{"2025-05-05", "21:00", false}, // would run past closing
+		{"2025-05-06", "12:00", false}, // tuesdays are closed
+		{"2025-05-09", "23:30", true},
+		{"2025-05-10", "00:30", false}, // a 01:00 close leaves no room for a seating
+		{"2025-05-10", "01:00", false},
+		{"2025-05-12", "12:00", false}, // private event
+		{"2025-05-19", "12:00", false}, // limited to the evening
+		{"2025-05-19", "19:30", true},
+		{"2025-05-18", "13:00", true}, // opens on a sunday
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.open, bistro.seatingOpen(mustSlot(t, tc.date, tc.clock), 90*time.Minute), "%s %s", tc.date, tc.clock)
+	}
+
+	late := Restaurant{OpeningHours: OpeningHours{"friday": {{Open: "18:00", Close: "02:00", LastSeating: "00:30"}}}}
+	assert.True(t, late.seatingOpen(mustSlot(t, "2025-05-10", "00:30"), 90*time.Minute), "seatings past midnight count for the day before")
+	assert.False(t, late.seatingOpen(mustSlot(t, "2025-05-10", "01:00"), 90*time.Minute))
+
+	assert.True(t, (&Restaurant{}).seatingOpen(mustSlot(t, "2025-05-06", "03:00"), 90*time.Minute), "no schedule, no restriction")
+}
+
+func TestValidateSchedule(t *testing.T) {
+	assert.Empty(t, validateSchedule("Europe/Paris", bistro.OpeningHours, bistro.SpecialAvailability))
+
+	fields := validateSchedule("Mars/Olympus", OpeningHours{
+		"funday":  {{Open: "11:00", Close: "15:00"}},
+		"monday":  {{Open: "11", Close: "15:00"}},
+		"tuesday": {{Open: "11:00", Close: "15:00", LastSeating: "16:00"}},
+	}, []SpecialAvailability{
+		{Date: "2025-12-25", Status: specialClosed},
+		{Date: "2025-12-25", Status: specialLimited},
+		{Date: "soon", Status: "maybe"},
+	})
+	assert.Contains(t, fields, "timezone")
+	assert.Contains(t, fields, "opening_hours.funday")
+	assert.Contains(t, fields, "opening_hours.monday[0]")
+	assert.Equal(t, "last_seating must fall between open and close", fields["opening_hours.tuesday[0]"])
+	assert.Equal(t, "is listed more than once", fields["special_availability[1].date"])
+	assert.Contains(t, fields, "special_availability[1].hours")
+	assert.Contains(t, fields, "special_availability[2].date")
+	assert.Contains(t, fields, "special_availability[2].status")
+	assert.NotContains(t, fields, "special_availability[0].date")
+}
+
+func TestRouter_OpeningHours(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	router := newRouter(store, defaultSettings(), nil, nil, testVerifier())
+	manager := testToken(t, "manager-1", roleManager)
+
+	invalid := RestaurantCreate{Name: "Bistro", Location: "Paris", OpeningHours: OpeningHours{"monday": {{Open: "late", Close: "15:00"}}}}
+	rr := serve(router, manager, http.MethodPost, "/restaurants", invalid)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"opening_hours.monday[0]"`)
+
+	restaurant, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Bistro", Location: "Paris", OwnerID: "manager-1", Timezone: "Europe/Paris", OpeningHours: bistro.OpeningHours})
+	require.NoError(t, err)
+	_, err = store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: tableAvailable})
+	require.NoError(t, err)
+	base := "/restaurants/" + restaurant.ID
+	customer := testToken(t, "user-1", roleCustomer)
+
+	rr = serve(router, customer, http.MethodPost, base+"/reservations", ReservationCreate{Date: "2025-05-05", Time: "16:00", Guests: 2})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"time"`)
+
+	rr = serve(router, customer, http.MethodPost, base+"/reservations", ReservationCreate{Date: "2025-05-05", Time: "12:00", Guests: 2})
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var created struct {
+		Reservation Reservation `json:"reservation"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+
+	rr = serve(router, customer, http.MethodPut, base+"/reservations/"+created.Reservation.ID, ReservationUpdate{Date: "2025-05-06"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "tuesdays are closed")
+
+	rr = serve(router, "", http.MethodGet, base+"/availability?date=2025-05-05&party_size=2", nil)
+	var day DayAvailability
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &day))
+	times := []string{}
+	for _, s := range day.Slots {
+		times = append(times, s.Time)
+	}
+	assert.Equal(t, []string{"13:30", "14:00", "18:00", "18:30", "19:00", "19:30", "20:00", "20:30"}, times)
+	assert.Len(t, day.Hours, 2)
+	assert.Empty(t, day.Reason)
+
+	rr = serve(router, "", http.MethodGet, base+"/availability?date=2025-05-05&time=16:00&party_size=2", nil)
+	var slot TableAvailability
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &slot))
+	assert.False(t, slot.Available, "nothing is free while the restaurant is closed")
+
+	closed := []SpecialAvailability{{Date: "2025-05-12", Reason: "Private event", Status: specialClosed}}
+	rr = serve(router, manager, http.MethodPatch, base, RestaurantUpdate{SpecialAvailability: &closed})
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = serve(router, customer, http.MethodPost, base+"/reservations", ReservationCreate{Date: "2025-05-12", Time: "12:00", Guests: 2})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "closed on this date: Private event")
+
+	rr = serve(router, "", http.MethodGet, base+"/availability?date=2025-05-12&party_size=2", nil)
+	day = DayAvailability{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &day))
+	assert.Empty(t, day.Slots)
+	assert.Equal(t, "Private event", day.Reason)
+}
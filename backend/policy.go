@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Application roles, matching the frontend User model. The global role comes
+// from the access token; managers and staff additionally hold a per-restaurant
+// Member role that decides what they may do in each restaurant.
+const (
+	roleCustomer = "customer"
+	roleStaff    = "staff"
+	roleManager  = "manager"
+	roleAdmin    = "admin"
+)
+
+func forbidden(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+}
+
+// hasRestaurantRole reports whether principal holds one of roles in restaurantID.
+// Admins pass for every restaurant; everybody else needs a matching membership.
+func hasRestaurantRole(ctx context.Context, store Store, principal *Principal, restaurantID string, roles ...string) (bool, error) {
+	if principal.Role == roleAdmin {
+		return true, nil
+	}
+	member, err := store.GetMember(ctx, restaurantID, principal.UserID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(roles, member.Role), nil
+}
+
+// isRestaurantStaff reports whether principal may operate restaurantID on behalf
+// of the restaurant, as opposed to acting as a customer.
+func isRestaurantStaff(ctx context.Context, store Store, principal *Principal, restaurantID string) (bool, error) {
+	return hasRestaurantRole(ctx, store, principal, restaurantID, roleStaff, roleManager)
+}
+
+// allowRoles lets through callers whose global role is one of roles.
+// It must run after requireAuth.
+func allowRoles(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := currentPrincipal(c)
+		if principal == nil || !slices.Contains(roles, principal.Role) {
+			forbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// allowRestaurantRoles lets through admins and members holding one of roles in
+// the restaurant named by the :id route parameter. It must run after requireAuth.
+func allowRestaurantRoles(store Store, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := currentPrincipal(c)
+		if principal == nil {
+			forbidden(c)
+			return
+		}
+		allowed, err := hasRestaurantRole(c.Request.Context(), store, principal, c.Param("id"), roles...)
+		if err != nil {
+			c.AbortWithStatusJSON(storeStatus(err), gin.H{"error": "Failed to check permissions"})
+			return
+		}
+		if !allowed {
+			forbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// canAccessReservation reports whether principal may read or change reservation:
+// the customer who booked it, or staff of its restaurant.
+func canAccessReservation(ctx context.Context, store Store, principal *Principal, reservation *Reservation) (bool, error) {
+	if reservation.UserID != "" && reservation.UserID == principal.UserID {
+		return true, nil
+	}
+	return isRestaurantStaff(ctx, store, principal, reservation.RestaurantID)
+}
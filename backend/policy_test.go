@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasRestaurantRole(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", OwnerID: "manager-1"})
+	_, err := store.AddMember(ctx, MemberCreate{RestaurantID: restaurant.ID, UserID: "staff-1", Role: roleStaff})
+	require.NoError(t, err)
+
+	check := func(principal *Principal, restaurantID string, roles ...string) bool {
+		allowed, err := hasRestaurantRole(ctx, store, principal, restaurantID, roles...)
+		require.NoError(t, err)
+		return allowed
+	}
+	staff := &Principal{UserID: "staff-1", Role: roleStaff}
+	manager := &Principal{UserID: "manager-1", Role: roleManager}
+	admin := &Principal{UserID: "admin-1", Role: roleAdmin}
+	customer := &Principal{UserID: "user-1", Role: roleCustomer}
+
+	assert.True(t, check(staff, restaurant.ID, roleStaff, roleManager))
+	assert.False(t, check(staff, "other", roleStaff, roleManager))
+	assert.False(t, check(staff, restaurant.ID, roleManager))
+	assert.True(t, check(manager, restaurant.ID, roleManager), "the owner is a manager")
+	assert.True(t, check(admin, "other", roleManager))
+	assert.False(t, check(customer, restaurant.ID, roleStaff, roleManager))
+}
+
+func TestRouter_EnforcesRoles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := newMemoryStore()
+	restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", OwnerID: "manager-1"})
+	other, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Other", OwnerID: "manager-2"})
+	store.AddMember(ctx, MemberCreate{RestaurantID: restaurant.ID, UserID: "staff-1", Role: roleStaff})
+	store.AddMember(ctx, MemberCreate{RestaurantID: other.ID, UserID: "staff-2", Role: roleStaff})
+	base := "/restaurants/" + restaurant.ID
+	router := newRouter(store, defaultSettings(), nil, nil, testVerifier())
+
+	customer := testToken(t, "user-1", roleCustomer)
+	otherStaff := testToken(t, "staff-2", roleStaff)
+	staff := testToken(t, "staff-1", roleStaff)
+	manager := testToken(t, "manager-1", roleManager)
+	table := TableCreate{Number: 1, MinCapacity: 1, MaxCapacity: 2, Status: "available"}
+
+	cases := []struct {
+		name, token, method, path string
+		body                      interface{}
+		want                      int
+	}{
+		{"customer creates restaurant", customer, http.MethodPost, "/restaurants", RestaurantCreate{Name: "Mine", Location: "X"}, http.StatusForbidden},
+		{"staff edits restaurant", staff, http.MethodPatch, base, RestaurantUpdate{Name: "Renamed"}, http.StatusForbidden},
+		{"manager edits restaurant", manager, http.MethodPatch, base, RestaurantUpdate{Name: "Renamed"}, http.StatusOK},
+		{"customer adds table", customer, http.MethodPost, base + "/tables", table, http.StatusForbidden},
+		{"staff of other restaurant adds table", otherStaff, http.MethodPost, base + "/tables", table, http.StatusForbidden},
+		{"staff adds table", staff, http.MethodPost, base + "/tables", table, http.StatusCreated},
+		{"customer joins waitlist", customer, http.MethodPost, base + "/waitlist", WaitlistEntryCreate{Name: "Ann", PartySize: 2}, http.StatusCreated},
+		{"customer reads own waitlist place", customer, http.MethodGet, base + "/waitlist", nil, http.StatusOK},
+		{"staff reads waitlist", staff, http.MethodGet, base + "/waitlist", nil, http.StatusOK},
+	}
+	for _, tc := range cases {
+		rr := serve(router, tc.token, tc.method, tc.path, tc.body)
+		assert.Equal(t, tc.want, rr.Code, tc.name)
+	}
+}
+
+func TestRouter_Members(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newRouter(newMemoryStore(), defaultSettings(), nil, nil, testVerifier())
+	manager := testToken(t, "manager-1", roleManager)
+	staff := testToken(t, "staff-1", roleStaff)
+
+	require.Equal(t, http.StatusCreated, serve(router, manager, http.MethodPost, "/restaurants", RestaurantCreate{Name: "Diner", Location: "X"}).Code)
+	var mine []Restaurant
+	rr := serve(router, manager, http.MethodGet, "/restaurants?mine=true", nil)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &mine))
+	require.Len(t, mine, 1)
+	assert.Equal(t, "manager-1", mine[0].OwnerID)
+	base := "/restaurants/" + mine[0].ID
+
+	assert.Equal(t, http.StatusForbidden, serve(router, staff, http.MethodGet, base+"/members", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(router, manager, http.MethodPost, base+"/members", MemberCreate{UserID: "staff-1", Role: "owner"}).Code)
+	assert.Equal(t, http.StatusCreated, serve(router, manager, http.MethodPost, base+"/members", MemberCreate{UserID: "staff-1", Role: roleStaff}).Code)
+	assert.Equal(t, http.StatusConflict, serve(router, manager, http.MethodPost, base+"/members", MemberCreate{UserID: "staff-1", Role: roleStaff}).Code)
+
+	var members []Member
+	rr = serve(router, staff, http.MethodGet, base+"/members", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &members))
+	assert.Len(t, members, 2)
+
+	// Staff do not manage the restaurant, so mine=true is empty for them
+	rr = serve(router, staff, http.MethodGet, "/restaurants?mine=true", nil)
+	assert.JSONEq(t, `[]`, rr.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, serve(router, "", http.MethodGet, "/restaurants?mine=true", nil).Code)
+
+	assert.Equal(t, http.StatusForbidden, serve(router, staff, http.MethodDelete, base+"/members/staff-1", nil).Code)
+	assert.Equal(t, http.StatusConflict, serve(router, manager, http.MethodDelete, base+"/members/manager-1", nil).Code)
+	assert.Equal(t, http.StatusOK, serve(router, manager, http.MethodDelete, base+"/members/staff-1", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, manager, http.MethodDelete, base+"/members/staff-1", nil).Code)
+	assert.Equal(t, http.StatusForbidden, serve(router, staff, http.MethodPost, base+"/tables", TableCreate{Number: 1, MinCapacity: 1, MaxCapacity: 2, Status: "available"}).Code)
+}
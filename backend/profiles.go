@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Profile holds the account details collected at registration, keyed by the
+// Supabase user ID. Usernames are case-insensitive and stored in lower case.
+type Profile struct {
+	UserID      string    `json:"user_id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	PhoneNumber string    `json:"phone_number"`
+	FirstName   string    `json:"first_name"`
+	LastName    string    `json:"last_name"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// RegisterRequest struct for registration requests
+type RegisterRequest struct {
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	Username    string `json:"username"`
+	PhoneNumber string `json:"phone_number"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+}
+
+var (
+	usernamePattern = regexp.MustCompile(`^[a-z0-9_.]{3,30}$`)
+	e164Pattern     = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+)
+
+// normalize trims the request and lower-cases the username and email.
+func (r *RegisterRequest) normalize() {
+	r.Email = strings.ToLower(strings.TrimSpace(r.Email))
+	r.Username = strings.ToLower(strings.TrimSpace(r.Username))
+	r.PhoneNumber = strings.TrimSpace(r.PhoneNumber)
+	r.FirstName = strings.TrimSpace(r.FirstName)
+	r.LastName = strings.TrimSpace(r.LastName)
+}
+
+// validate returns a message per invalid field, or nil when the request is valid.
+func (r *RegisterRequest) validate() map[string]string {
+	fields := map[string]string{}
+
+	if address, err := mail.ParseAddress(r.Email); err != nil || address.Address != r.Email {
+		fields["email"] = "must be a valid email address"
+	}
+	if problem := passwordProblem(r.Password); problem != "" {
+		fields["password"] = problem
+	}
+	if !usernamePattern.MatchString(r.Username) {
+		fields["username"] = "must be 3-30 letters, digits, dots or underscores"
+	}
+	if !e164Pattern.MatchString(r.PhoneNumber) {
+		fields["phone_number"] = "must be in E.164 format, e.g. +14155550123"
+	}
+	if r.FirstName == "" || len(r.FirstName) > 50 {
+		fields["first_name"] = "is required and must be at most 50 characters"
+	}
+	if r.LastName == "" || len(r.LastName) > 50 {
+		fields["last_name"] = "is required and must be at most 50 characters"
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// passwordProblem describes why password is too weak, or returns "".
+func passwordProblem(password string) string {
+	if len(password) < 8 {
+		return "must be at least 8 characters"
+	}
+	var upper, lower, digit bool
+	for _, ch := range password {
+		switch {
+		case unicode.IsUpper(ch):
+			upper = true
+		case unicode.IsLower(ch):
+			lower = true
+		case unicode.IsDigit(ch):
+			digit = true
+		}
+	}
+	if !upper || !lower || !digit {
+		return "must contain an upper-case letter, a lower-case letter and a digit"
+	}
+	return ""
+}
+
+// validationFailed answers 400 with the per-field messages.
+func validationFailed(c *gin.Context, fields map[string]string) {
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "fields": fields})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase-community/gotrue-go"
+	"github.com/supabase-community/gotrue-go/types"
+)
+
+func validRegistration() RegisterRequest {
+	return RegisterRequest{
+		Email:       "Ann@Example.com",
+		Password:    "Secret123",
+		Username:    "Ann_Lee",
+		PhoneNumber: "+14155550123",
+		FirstName:   "Ann",
+		LastName:    "Lee",
+	}
+}
+
+func TestRegisterRequest_Validate(t *testing.T) {
+	request := validRegistration()
+	request.normalize()
+	assert.Nil(t, request.validate())
+	assert.Equal(t, "ann@example.com", request.Email)
+	assert.Equal(t, "ann_lee", request.Username)
+
+	invalid := RegisterRequest{Email: "not-an-email", Password: "password", Username: "a", PhoneNumber: "415-555-0123"}
+	invalid.normalize()
+	fields := invalid.validate()
+	for _, field := range []string{"email", "password", "username", "phone_number", "first_name", "last_name"} {
+		assert.Contains(t, fields, field)
+	}
+}
+
+func TestPasswordProblem(t *testing.T) {
+	assert.NotEmpty(t, passwordProblem("Ab1"))
+	assert.NotEmpty(t, passwordProblem("password123"))
+	assert.NotEmpty(t, passwordProblem("PASSWORD123"))
+	assert.NotEmpty(t, passwordProblem("Passwordxyz"))
+	assert.Empty(t, passwordProblem("Password123"))
+}
+
+func registerRouter(auth *mockGoTrue, admin gotrue.Client, store Store) *gin.Engine {
+	router := setupRouter()
+	router.POST("/register", registerHandler(auth, admin, store))
+	return router
+}
+
+func TestRegisterHandler_CreatesProfile(t *testing.T) {
+	store := new(mockStore)
+	auth := new(mockGoTrue)
+	userID := uuid.New()
+	store.On("GetProfileByUsername", "ann_lee").Return(nil, ErrNotFound)
+	auth.On("Signup", mock.MatchedBy(func(req types.SignupRequest) bool {
+		return req.Email == "ann@example.com" && req.Data["phone_number"] == "+14155550123"
+	})).Return(&types.SignupResponse{User: types.User{ID: userID}}, nil)
+	expected := Profile{UserID: userID.String(), Username: "ann_lee", Email: "ann@example.com", PhoneNumber: "+14155550123", FirstName: "Ann", LastName: "Lee"}
+	store.On("CreateProfile", expected).Return(&expected, nil)
+
+	rr := serve(registerRouter(auth, nil, store), "", http.MethodPost, "/register", validRegistration())
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var body struct {
+		Message string  `json:"message"`
+		Profile Profile `json:"profile"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, "User registered successfully", body.Message)
+	assert.Equal(t, expected, body.Profile)
+	auth.AssertExpectations(t)
+	store.AssertExpectations(t)
+}
+
+func TestRegisterHandler_FieldErrors(t *testing.T) {
+	store := new(mockStore)
+	auth := new(mockGoTrue)
+	request := validRegistration()
+	request.PhoneNumber = "5550123"
+	request.Password = "short"
+
+	rr := serve(registerRouter(auth, nil, store), "", http.MethodPost, "/register", request)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"error":"Validation failed","fields":{"password":"must be at least 8 characters","phone_number":"must be in E.164 format, e.g. +14155550123"}}`, rr.Body.String())
+	auth.AssertNotCalled(t, "Signup", mock.Anything)
+}
+
+func TestRegisterHandler_UsernameTaken(t *testing.T) {
+	store := new(mockStore)
+	auth := new(mockGoTrue)
+	store.On("GetProfileByUsername", "ann_lee").Return(&Profile{UserID: "someone"}, nil)
+
+	rr := serve(registerRouter(auth, nil, store), "", http.MethodPost, "/register", validRegistration())
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.JSONEq(t, `{"error":"Validation failed","fields":{"username":"is already taken"}}`, rr.Body.String())
+	auth.AssertNotCalled(t, "Signup", mock.Anything)
+}
+
+func TestRegisterHandler_SignupError(t *testing.T) {
+	store := new(mockStore)
+	auth := new(mockGoTrue)
+	store.On("GetProfileByUsername", "ann_lee").Return(nil, ErrNotFound)
+	auth.On("Signup", mock.Anything).Return(nil, errors.New("user already registered"))
+
+	rr := serve(registerRouter(auth, nil, store), "", http.MethodPost, "/register", validRegistration())
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	store.AssertNotCalled(t, "CreateProfile", mock.Anything)
+}
+
+func TestRegisterHandler_ProfileErrorRemovesUser(t *testing.T) {
+	store := new(mockStore)
+	auth := new(mockGoTrue)
+	admin := new(mockGoTrue)
+	userID := uuid.New()
+	store.On("GetProfileByUsername", "ann_lee").Return(nil, ErrNotFound)
+	auth.On("Signup", mock.Anything).Return(&types.SignupResponse{User: types.User{ID: userID}}, nil)
+	store.On("CreateProfile", mock.Anything).Return(nil, errors.New("relation \"profiles\" does not exist"))
+	admin.On("AdminDeleteUser", types.AdminDeleteUserRequest{UserID: userID}).Return(nil)
+
+	rr := serve(registerRouter(auth, admin, store), "", http.MethodPost, "/register", validRegistration())
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	admin.AssertExpectations(t)
+}
+
+func TestRegisterHandler_ProfileErrorWithoutAdminNeedsCleanup(t *testing.T) {
+	store := new(mockStore)
+	auth := new(mockGoTrue)
+	userID := uuid.New()
+	store.On("GetProfileByUsername", "ann_lee").Return(nil, ErrNotFound)
+	auth.On("Signup", mock.Anything).Return(&types.SignupResponse{User: types.User{ID: userID}}, nil)
+	store.On("CreateProfile", mock.Anything).Return(nil, ErrConflict)
+
+	rr := serve(registerRouter(auth, nil, store), "", http.MethodPost, "/register", validRegistration())
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code, "not a plain username clash: the email is now stuck")
+	assert.Contains(t, rr.Body.String(), "removed by an administrator")
+	assert.Contains(t, rr.Body.String(), userID.String())
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"tabletoppers/notifications"
+)
+
+// runReservationJobs marks the restaurant's missed seatings as no-shows and, when
+// guests are notified, sends the reminders due at now.
+func runReservationJobs(ctx context.Context, store Store, cfg settings, notifier *notifications.Notifier, restaurant *Restaurant, now time.Time) error {
+	var lookahead time.Duration
+	for _, offset := range cfg.ReminderOffsets {
+		lookahead = max(lookahead, offset)
+	}
+	reservations, err := reservationsBetween(ctx, store, restaurant, now.AddDate(0, 0, -1), now.Add(lookahead))
+	if err != nil {
+		return err
+	}
+	if err := markNoShows(ctx, store, cfg, restaurant.ID, reservations, now); err != nil {
+		return err
+	}
+	if notifier == nil {
+		return nil
+	}
+	return remindGuests(ctx, store, cfg, notifier, restaurant.ID, reservations, now)
+}
+
+// reservationsBetween lists the restaurant's reservations dated from the day of
+// from through the day of to on its clock.
+func reservationsBetween(ctx context.Context, store Store, restaurant *Restaurant, from, to time.Time) ([]Reservation, error) {
+	loc := restaurant.location()
+	from, to = from.In(loc), to.In(loc)
+	var reservations []Reservation
+	last := to.Format(dateLayout)
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc); day.Format(dateLayout) <= last; day = day.AddDate(0, 0, 1) {
+		booked, err := store.ListReservations(ctx, restaurant.ID, ReservationFilter{Date: day.Format(dateLayout)})
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, booked...)
+	}
+	return reservations, nil
+}
+
+// markNoShows moves the pending and confirmed reservations whose seating began
+// more than cfg.NoShowGrace before now to no_show, frees their tables and offers
+// what is left of each seating to the waitlist. Tables held for a pending
+// waitlist offer are left to the offer. A reservation seated or moved on in the
+// meantime fails the transition and is skipped, so marking is idempotent.
+func markNoShows(ctx context.Context, store Store, cfg settings, restaurantID string, reservations []Reservation, now time.Time) error {
+	offers, err := store.ListWaitlistOffers(ctx, restaurantID)
+	if err != nil {
+		return err
+	}
+	held := map[string]bool{}
+	for _, o := range offers {
+		if o.Status == offerPending {
+			held[o.ReservationID] = true
+		}
+	}
+
+	for _, r := range reservations {
+		if (r.Status != statusPending && r.Status != statusConfirmed) || held[r.ID] {
+			continue
+		}
+		start, _, err := seatingWindow(r, cfg.DiningDuration)
+		if err != nil || now.Before(start.Add(cfg.NoShowGrace)) {
+			continue
+		}
+		missed, err := store.TransitionReservation(ctx, ReservationTransition{
+			RestaurantID:  restaurantID,
+			ReservationID: r.ID,
+			From:          r.Status,
+			To:            statusNoShow,
+			ActorID:       serverActor,
+		})
+		if errors.Is(err, ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		syncReservationTables(ctx, store, cfg, r, *missed)
+		if _, err := promoteWaitlist(ctx, store, cfg, *missed, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remindGuests reminds the guests of confirmed seatings yet to start of the
+// latest reminder due: the smallest of cfg.ReminderOffsets whose time has come.
+// Each reminder is claimed in the store before it is sent, so only one server
+// instance sends it, and reminders passed over while the server was down are
+// skipped. A reminder that reaches the guest on no channel is released again
+// and retried by the next sweep until the seating starts. A reminder due before
+// the reservation was confirmed is not sent; the confirmation said as much.
+func remindGuests(ctx context.Context, store Store, cfg settings, notifier *notifications.Notifier, restaurantID string, reservations []Reservation, now time.Time) error {
+	for _, r := range reservations {
+		if r.Status != statusConfirmed {
+			continue
+		}
+		start, _, err := seatingWindow(r, cfg.DiningDuration)
+		if err != nil || !now.Before(start) {
+			continue
+		}
+		var due time.Duration
+		for _, offset := range cfg.ReminderOffsets {
+			if !now.Before(start.Add(-offset)) && (due == 0 || offset < due) {
+				due = offset
+			}
+		}
+		if due == 0 {
+			continue
+		}
+
+		key := fmt.Sprintf("reminder:%s:%d", r.ID, int(due/time.Minute))
+		err = store.ClaimJob(ctx, restaurantID, key)
+		if errors.Is(err, ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		confirmed, err := confirmedAfter(ctx, store, r, start.Add(-due))
+		if err != nil {
+			releaseJob(ctx, store, restaurantID, key)
+			return err
+		}
+		if confirmed {
+			continue
+		}
+		if err := notifyGuest(ctx, store, notifier, notifications.Reminder, r, "", time.Time{}); err != nil {
+			log.Printf("Reminding the guest of reservation %s failed, retrying next sweep: %v", r.ID, err)
+			releaseJob(ctx, store, restaurantID, key)
+		}
+	}
+	return nil
+}
+
+// releaseJob gives up the claim on key so a later sweep can try the job again.
+func releaseJob(ctx context.Context, store Store, restaurantID, key string) {
+	if err := store.ReleaseJob(ctx, restaurantID, key); err != nil {
+		log.Printf("Failed to release job %s: %v", key, err)
+	}
+}
+
+// confirmedAfter reports whether r was confirmed after t. Reservations booked as
+// confirmed have no event and count as confirmed before.
+func confirmedAfter(ctx context.Context, store Store, r Reservation, t time.Time) (bool, error) {
+	events, err := store.ListReservationEvents(ctx, r.RestaurantID, r.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range events {
+		if e.ToStatus == statusConfirmed && e.CreatedAt.After(t) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sweepReservations runs the reservation jobs of every restaurant every interval
+// until ctx is done. Reminders are claimed and no-shows compare-and-set in the
+// store, so neither repeats after a restart or on a second server instance.
+func sweepReservations(ctx context.Context, store Store, cfg settings, notifier *notifications.Notifier, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		restaurants, err := store.ListRestaurants(ctx, RestaurantFilter{})
+		if err != nil {
+			log.Printf("Listing restaurants for reservation jobs failed: %v", err)
+		}
+		for i := range restaurants {
+			if err := runReservationJobs(ctx, store, cfg, notifier, &restaurants[i], now); err != nil {
+				log.Printf("Running reservation jobs for restaurant %s failed: %v", restaurants[i].ID, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tabletoppers/notifications"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemindGuests(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	messages := &outbox{}
+	notifier := notifications.New(map[notifications.Channel]notifications.Provider{notifications.SMS: messages})
+	cfg := defaultSettings()
+	restaurant, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", Location: "X"})
+	require.NoError(t, err)
+	now := time.Now().UTC().Truncate(time.Minute)
+	book := func(start time.Time, status, phone string) *Reservation {
+		r, err := store.CreateReservation(ctx, ReservationCreate{
+			RestaurantID: restaurant.ID, Date: start.Format(dateLayout), Time: start.Format(timeLayout), StartsAt: start,
+			Guests: 2, Status: status, PhoneNumber: phone,
+		})
+		require.NoError(t, err)
+		return r
+	}
+	soon := book(now.Add(90*time.Minute), statusConfirmed, "+15550100")
+	book(now.Add(30*time.Hour), statusConfirmed, "+15550101")
+	book(now.Add(time.Hour), statusPending, "+15550102")
+	late := book(now.Add(100*time.Minute), statusPending, "+15550103")
+	_, err = store.TransitionReservation(ctx, ReservationTransition{RestaurantID: restaurant.ID, ReservationID: late.ID, From: statusPending, To: statusConfirmed})
+	require.NoError(t, err)
+
+	require.NoError(t, runReservationJobs(ctx, store, cfg, notifier, restaurant, now))
+	sent := messages.take()
+	require.Len(t, sent, 1, "one reminder for the seating within two hours, none for the one tomorrow, the pending one or the one confirmed since")
+	assert.Equal(t, notifications.Reminder, sent[0].Kind)
+	assert.Equal(t, "+15550100", sent[0].To)
+
+	// The next sweep, or one after a restart, does not send it again
+	require.NoError(t, runReservationJobs(ctx, store, cfg, notifier, restaurant, now.Add(time.Minute)))
+	assert.Empty(t, messages.take())
+	logged, err := store.ListNotifications(ctx, restaurant.ID)
+	require.NoError(t, err)
+	require.Len(t, logged, 1)
+	assert.Equal(t, soon.ID, logged[0].ReservationID)
+
+	// Six hours on, the seating tomorrow is due its day-ahead reminder
+	require.NoError(t, runReservationJobs(ctx, store, cfg, notifier, restaurant, now.Add(6*time.Hour)))
+	sent = messages.take()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "+15550101", sent[0].To)
+}
+
+func TestMarkNoShows(t *testing.T) {
+	ctx := context.Background()
+	store, restaurant := offerFixture(t)
+	cfg := defaultSettings()
+	tables, _ := store.ListTables(ctx, restaurant.ID)
+	table := tables[0]
+	now := time.Now().UTC().Truncate(time.Minute)
+	book := func(start time.Time, status string, number int) *Reservation {
+		t.Helper()
+		tableID, guests := table.ID, 2
+		if number != table.Number {
+			other, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: number, MinCapacity: 5, MaxCapacity: 8, Status: tableAvailable})
+			require.NoError(t, err)
+			tableID, guests = other.ID, 6
+		}
+		r, err := store.CreateReservation(ctx, ReservationCreate{
+			RestaurantID: restaurant.ID, UserID: "carol", TableID: tableID, Date: start.Format(dateLayout), Time: start.Format(timeLayout), StartsAt: start,
+			Guests: guests, Status: status, DurationMinutes: 90,
+		})
+		require.NoError(t, err)
+		return r
+	}
+	missed := book(now.Add(-20*time.Minute), statusConfirmed, table.Number)
+	running := book(now.Add(-10*time.Minute), statusConfirmed, 2)
+	seated := book(now.Add(-40*time.Minute), statusSeated, 3)
+
+	require.NoError(t, runReservationJobs(ctx, store, cfg, nil, restaurant, now))
+
+	got, err := store.GetReservation(ctx, restaurant.ID, missed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, statusNoShow, got.Status)
+	events, err := store.ListReservationEvents(ctx, restaurant.ID, missed.ID)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, serverActor, events[0].ActorID)
+	for _, r := range []*Reservation{running, seated} {
+		got, err := store.GetReservation(ctx, restaurant.ID, r.ID)
+		require.NoError(t, err)
+		assert.Equal(t, r.Status, got.Status, "within the grace period or seated")
+	}
+
+	// The rest of the missed seating goes to the first party waiting
+	offer := pendingOffer(t, store, restaurant.ID)
+	assert.Equal(t, "ann", offer.UserID)
+	held, err := store.GetReservation(ctx, restaurant.ID, offer.ReservationID)
+	require.NoError(t, err)
+	assert.Equal(t, table.ID, held.TableID)
+	assert.Equal(t, now.Format(timeLayout), held.Time)
+
+	// Sweeping again changes nothing, and the held table is not a no-show
+	require.NoError(t, runReservationJobs(ctx, store, cfg, nil, restaurant, now.Add(cfg.NoShowGrace)))
+	assert.Equal(t, offer, pendingOffer(t, store, restaurant.ID))
+	events, err = store.ListReservationEvents(ctx, restaurant.ID, missed.ID)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+}
+
+// flaky is a notifications.Provider that fails while down is set.
+type flaky struct {
+	outbox
+	down bool
+}
+
+func (f *flaky) Send(ctx context.Context, msg notifications.Message) error {
+	if f.down {
+		return errors.New("provider unavailable")
+	}
+	return f.outbox.Send(ctx, msg)
+}
+
+func TestRemindGuests_RetriesFailedReminders(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	messages := &flaky{down: true}
+	notifier := notifications.New(map[notifications.Channel]notifications.Provider{notifications.SMS: messages})
+	cfg := defaultSettings()
+	restaurant, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", Location: "X"})
+	require.NoError(t, err)
+	now := time.Now().UTC().Truncate(time.Minute)
+	start := now.Add(90 * time.Minute)
+	_, err = store.CreateReservation(ctx, ReservationCreate{
+		RestaurantID: restaurant.ID, Date: start.Format(dateLayout), Time: start.Format(timeLayout), StartsAt: start,
+		Guests: 2, Status: statusConfirmed, PhoneNumber: "+15550100",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, runReservationJobs(ctx, store, cfg, notifier, restaurant, now))
+	logged, err := store.ListNotifications(ctx, restaurant.ID)
+	require.NoError(t, err)
+	require.Len(t, logged, 1)
+	assert.Equal(t, notifications.Failed, logged[0].Status)
+
+	// Once the provider is back the next sweep sends it, and only once
+	messages.down = false
+	require.NoError(t, runReservationJobs(ctx, store, cfg, notifier, restaurant, now.Add(time.Minute)))
+	require.Len(t, messages.take(), 1)
+	require.NoError(t, runReservationJobs(ctx, store, cfg, notifier, restaurant, now.Add(2*time.Minute)))
+	assert.Empty(t, messages.take())
+}
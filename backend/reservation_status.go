@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reservation statuses. Customers book pending reservations and staff may book
+// confirmed ones; from there a reservation only moves along
+// reservationTransitions.
+const (
+	statusPending   = "pending"
+	statusConfirmed = "confirmed"
+	statusSeated    = "seated"
+	statusCompleted = "completed"
+	statusCancelled = "cancelled"
+	statusNoShow    = "no_show"
+)
+
+// reservationTransitions lists the statuses each status may move to. Completed,
+// cancelled and no-show reservations are final.
+var reservationTransitions = map[string][]string{
+	statusPending:   {statusConfirmed, statusCancelled, statusNoShow},
+	statusConfirmed: {statusSeated, statusCancelled, statusNoShow},
+	statusSeated:    {statusCompleted},
+}
+
+// canTransition reports whether a reservation may move from one status to
+// another. Reservations saved without a status count as pending.
+func canTransition(from, to string) bool {
+	if from == "" {
+		from = statusPending
+	}
+	return slices.Contains(reservationTransitions[from], to)
+}
+
+// releasesSeating reports whether a reservation moved to status gives up its
+// seating, which can then be offered to the waitlist.
+func releasesSeating(status string) bool {
+	return status == statusCancelled || status == statusNoShow
+}
+
+// serverActor is recorded as the actor of status changes the server makes on its
+// own, such as releasing a table held for a lapsed waitlist offer.
+const serverActor = "server"
+
+// ReservationEvent records one status change of a reservation: who made it and when.
+type ReservationEvent struct {
+	ID            string    `json:"id"`
+	ReservationID string    `json:"reservation_id"`
+	RestaurantID  string    `json:"restaurant_id"`
+	FromStatus    string    `json:"from_status"`
+	ToStatus      string    `json:"to_status"`
+	ActorID       string    `json:"actor_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ReservationTransition asks the store to move a reservation from one status to
+// another on behalf of ActorID. The store fails with ErrConflict when the
+// reservation is no longer in From.
+type ReservationTransition struct {
+	RestaurantID  string
+	ReservationID string
+	From          string
+	To            string
+	ActorID       string
+}
+
+// newReservationEvent builds the event recording t.
+func newReservationEvent(id string, t ReservationTransition) ReservationEvent {
+	return ReservationEvent{
+		ID:            id,
+		ReservationID: t.ReservationID,
+		RestaurantID:  t.RestaurantID,
+		FromStatus:    t.From,
+		ToStatus:      t.To,
+		ActorID:       t.ActorID,
+		CreatedAt:     now(),
+	}
+}
+
+// illegalTransition answers 409 for a status change the state machine forbids.
+func illegalTransition(c *gin.Context, from, to string) {
+	if from == "" {
+		from = statusPending
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   "Cannot move a " + from + " reservation to " + to,
+		"status":  from,
+		"allowed": reservationTransitions[from],
+	})
+}
+
+// moveReservation moves reservation to status on behalf of the caller and
+// records the change. On failure the response has been written and ok is false.
+func moveReservation(c *gin.Context, store Store, reservation *Reservation, status string) (updated *Reservation, ok bool) {
+	if !canTransition(reservation.Status, status) {
+		illegalTransition(c, reservation.Status, status)
+		return nil, false
+	}
+
+	principal, _ := currentPrincipal(c)
+	updated, err := store.TransitionReservation(c.Request.Context(), ReservationTransition{
+		RestaurantID:  reservation.RestaurantID,
+		ReservationID: reservation.ID,
+		From:          reservation.Status,
+		To:            status,
+		ActorID:       principal.UserID,
+	})
+	if errors.Is(err, ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Reservation status has changed, reload it and try again"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(storeStatus(err), gin.H{"error": "Failed to update reservation status"})
+		return nil, false
+	}
+	return updated, true
+}
+
+// Reservation Status Handler. Moves the reservation in the route to status and
+// updates the status of its tables; routes other than cancel are limited to
+// staff by middleware. A cancelled or missed seating is offered to the waitlist.
+func transitionReservation(store Store, cfg settings, status, message string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reservation, ok := loadReservation(c, store)
+		if !ok {
+			return
+		}
+		restaurant, ok := loadRestaurant(c, store, reservation.RestaurantID)
+		if !ok {
+			return
+		}
+
+		updated, ok := moveReservation(c, store, reservation, status)
+		if !ok {
+			return
+		}
+		moved := *reservation
+		moved.Status = status
+		syncReservationTables(c.Request.Context(), store, cfg, *reservation, moved)
+		if releasesSeating(status) {
+			promoteFreed(c.Request.Context(), store, cfg, moved)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": message, "reservation": inLocation([]Reservation{*updated}, restaurant.location())[0]})
+	}
+}
+
+// Get Reservation Events Handler. Lists the status changes of a reservation,
+// oldest first, timed on the restaurant's clock.
+func getReservationEvents(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reservation, ok := loadReservation(c, store)
+		if !ok {
+			return
+		}
+		restaurant, ok := loadRestaurant(c, store, reservation.RestaurantID)
+		if !ok {
+			return
+		}
+
+		events, err := store.ListReservationEvents(c.Request.Context(), reservation.RestaurantID, reservation.ID)
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch reservation events"})
+			return
+		}
+
+		loc := restaurant.location()
+		for i := range events {
+			events[i].CreatedAt = events[i].CreatedAt.In(loc)
+		}
+		c.JSON(http.StatusOK, events)
+	}
+}
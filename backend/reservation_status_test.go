@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{"pending", "confirmed", true},
+		{"", "confirmed", true},
+		{"pending", "seated", false},
+		{"confirmed", "seated", true},
+		{"confirmed", "completed", false},
+		{"seated", "completed", true},
+		{"seated", "cancelled", false},
+		{"pending", "no_show", true},
+		{"confirmed", "no_show", true},
+		{"cancelled", "pending", false},
+		{"completed", "cancelled", false},
+		{"no_show", "confirmed", false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.allowed, canTransition(tc.from, tc.to), "%q -> %q", tc.from, tc.to)
+	}
+}
+
+func TestRouter_ReservationLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", Location: "X"})
+	_, err := store.AddMember(ctx, MemberCreate{RestaurantID: restaurant.ID, UserID: "staff-1", Role: roleStaff})
+	require.NoError(t, err)
+	_, err = store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: "available"})
+	require.NoError(t, err)
+	router := newRouter(store, defaultSettings(), nil, nil, testVerifier())
+	customer := testToken(t, "user-1", roleCustomer)
+	staff := testToken(t, "staff-1", roleStaff)
+
+	rr := serve(router, customer, http.MethodPost, "/restaurants/"+restaurant.ID+"/reservations", ReservationCreate{Date: "2025-05-01", Time: "19:00", Guests: 2})
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var created struct {
+		Reservation Reservation `json:"reservation"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	path := "/restaurants/" + restaurant.ID + "/reservations/" + created.Reservation.ID
+
+	rr = serve(router, customer, http.MethodPost, path+"/confirm", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "only staff confirm")
+
+	rr = serve(router, staff, http.MethodPost, path+"/seat", nil)
+	assert.Equal(t, http.StatusConflict, rr.Code, "a pending reservation is confirmed before seating")
+	assert.Contains(t, rr.Body.String(), `"allowed":["confirmed","cancelled","no_show"]`)
+
+	for _, step := range []string{"confirm", "seat"} {
+		rr = serve(router, staff, http.MethodPost, path+"/"+step, nil)
+		require.Equal(t, http.StatusOK, rr.Code, step)
+	}
+	rr = serve(router, customer, http.MethodPut, path, ReservationUpdate{Status: statusCancelled})
+	assert.Equal(t, http.StatusConflict, rr.Code, "a seated party cannot cancel")
+	rr = serve(router, staff, http.MethodPut, path, ReservationUpdate{Status: statusCompleted})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = serve(router, staff, http.MethodPost, path+"/no-show", nil)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = serve(router, customer, http.MethodGet, path+"/events", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var events []ReservationEvent
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &events))
+	require.Len(t, events, 3)
+	for i, want := range []string{statusConfirmed, statusSeated, statusCompleted} {
+		assert.Equal(t, want, events[i].ToStatus)
+		assert.Equal(t, "staff-1", events[i].ActorID)
+		assert.NotEmpty(t, events[i].CreatedAt)
+	}
+	assert.Equal(t, statusPending, events[0].FromStatus)
+
+	rr = serve(router, testToken(t, "user-2", roleCustomer), http.MethodGet, path+"/events", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestCreateReservation_RejectsUnknownStatus(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store, Member{RestaurantID: "res-1", UserID: "staff-1", Role: roleStaff})
+	router := reservationRouter(store)
+
+	body := ReservationCreate{Date: "2025-05-01", Time: "19:00", Guests: 2, Status: "seated"}
+	rr := serve(router, testToken(t, "staff-1", roleStaff), http.MethodPost, "/restaurants/res-1/reservations", body)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"status"`)
+}
+
+func TestUpdateReservation_StatusChangesAlone(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	restaurant, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", Location: "X", SpecialAvailability: []SpecialAvailability{
+		{Date: "2025-05-02", Reason: "Private event", Status: specialClosed},
+	}})
+	require.NoError(t, err)
+	_, err = store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: tableAvailable})
+	require.NoError(t, err)
+	router := newRouter(store, defaultSettings(), nil, nil, testVerifier())
+	customer := testToken(t, "user-1", roleCustomer)
+
+	rr := serve(router, customer, http.MethodPost, "/restaurants/"+restaurant.ID+"/reservations", ReservationCreate{Date: "2025-05-01", Time: "19:00", Guests: 2})
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var created struct {
+		Reservation Reservation `json:"reservation"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	path := "/restaurants/" + restaurant.ID + "/reservations/" + created.Reservation.ID
+
+	rr = serve(router, customer, http.MethodPut, path, ReservationUpdate{Time: "20:00", Status: statusCancelled})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"status":"cannot be changed together with other fields"`)
+	got, err := store.GetReservation(ctx, restaurant.ID, created.Reservation.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "19:00", got.Time)
+	assert.Equal(t, statusPending, got.Status)
+
+	rr = serve(router, customer, http.MethodPut, path, ReservationUpdate{Status: statusCancelled})
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// A cancelled reservation is still only moved to a day the restaurant is open
+	rr = serve(router, customer, http.MethodPut, path, ReservationUpdate{Date: "2025-05-02"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Private event")
+}
@@ -1,85 +1,569 @@
 package main
 
 import (
-	"encoding/json" // Import encoding/json
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
 
-	"github.com/gofiber/fiber/v2"
-	"github.com/supabase-community/supabase-go"
-	// "log" // Uncomment if you want to add logging back
+	"github.com/gin-gonic/gin"
 )
 
 // Reservation struct
 type Reservation struct {
-	ID           string `json:"id,omitempty"` // omitempty helps during insert
-	RestaurantID string `json:"restaurant_id"`
-	UserID       string `json:"user_id,omitempty"` // Assuming nullable or set later
-	Date         string `json:"date"`
-	Time         string `json:"time"`
-	Guests       int    `json:"guests"`
-	Status       string `json:"status,omitempty"` // Assuming default or set later
-}
-
-// SetupReservationsRoutes registers the reservation routes
-func SetupReservationsRoutes(app *fiber.App, client *supabase.Client) {
-	// Route to get reservations for a specific restaurant, optionally filtered by date
-	app.Get("/restaurants/:restaurantId/reservations", func(c *fiber.Ctx) error {
-		restaurantID := c.Params("restaurantId")
-		date := c.Query("date") // Get optional date query parameter
-
-		// Start building the Supabase query
-		// Adjust Select call: Added default count ("") and head (false) arguments
-		query := client.From("reservations").Select("*", "", false).Eq("restaurant_id", restaurantID)
-
-		// Add date filter if provided
-		if date != "" {
-			query = query.Eq("date", date)
-		}
-
-		var reservations []Reservation
-		// Execute the query
-		// Execute now returns []byte, count, error
-		respBytes, _, err := query.Execute()
-		if err != nil {
-			// log.Printf("Error fetching reservations: %v\n", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch reservations"})
+	ID              string    `json:"id,omitempty"` // omitempty helps during insert
+	RestaurantID    string    `json:"restaurant_id"`
+	UserID          string    `json:"user_id,omitempty"` // Assuming nullable or set later
+	TableID         string    `json:"table_id,omitempty"`
+	GroupID         string    `json:"group_id,omitempty"` // set when the party sits at a table group led by TableID
+	Date            string    `json:"date"`               // on the restaurant's clock
+	Time            string    `json:"time"`
+	StartsAt        time.Time `json:"starts_at"` // the instant Date and Time name, stored in UTC
+	Guests          int       `json:"guests"`
+	Status          string    `json:"status,omitempty"`           // Assuming default or set later
+	DurationMinutes int       `json:"duration_minutes,omitempty"` // how long the table is held, fixed when booked
+	PhoneNumber     string    `json:"phone_number,omitempty"`     // texted about the booking instead of the guest's profile number
+}
+
+// ReservationCreate struct for creation requests
+type ReservationCreate struct {
+	RestaurantID    string    `json:"restaurant_id"`
+	UserID          string    `json:"user_id,omitempty"`
+	TableID         string    `json:"table_id,omitempty"` // staff only; assigned by the server otherwise
+	GroupID         string    `json:"group_id,omitempty"` // staff only; takes precedence over TableID
+	Date            string    `json:"date" binding:"required"`
+	Time            string    `json:"time" binding:"required"`
+	StartsAt        time.Time `json:"starts_at"` // set by the server from Date and Time
+	Guests          int       `json:"guests" binding:"required,min=1"`
+	Status          string    `json:"status"`
+	DurationMinutes int       `json:"duration_minutes,omitempty"` // set by the server from the dining duration
+	PhoneNumber     string    `json:"phone_number,omitempty"`
+}
+
+// newReservation builds the reservation r creates.
+func newReservation(id string, r ReservationCreate) Reservation {
+	return Reservation{
+		ID:              id,
+		RestaurantID:    r.RestaurantID,
+		UserID:          r.UserID,
+		TableID:         r.TableID,
+		GroupID:         r.GroupID,
+		Date:            r.Date,
+		Time:            r.Time,
+		StartsAt:        r.StartsAt.UTC(),
+		Guests:          r.Guests,
+		Status:          r.Status,
+		DurationMinutes: r.DurationMinutes,
+		PhoneNumber:     r.PhoneNumber,
+	}
+}
+
+// ReservationUpdate struct for update requests. Empty fields are left untouched.
+type ReservationUpdate struct {
+	TableID  string     `json:"table_id,omitempty"` // staff only
+	GroupID  *string    `json:"group_id,omitempty"` // staff only; "" moves the party off its group
+	Date     string     `json:"date,omitempty"`
+	Time     string     `json:"time,omitempty"`
+	StartsAt *time.Time `json:"starts_at,omitempty"` // set by the server when Date or Time change
+	Guests   int        `json:"guests,omitempty" binding:"omitempty,min=1"`
+	Status   string     `json:"status,omitempty"`
+}
+
+// SeatingConflict describes the booking that already holds a table, without
+// revealing whose it is.
+type SeatingConflict struct {
+	TableID string `json:"table_id"`
+	GroupID string `json:"group_id,omitempty"`
+	Date    string `json:"date"`
+	Time    string `json:"time"`
+	Until   string `json:"until"`
+}
+
+// keyedMutex hands out one mutex per key.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock locks the mutex for key and returns its unlock function.
+func (k *keyedMutex) lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = map[string]*sync.Mutex{}
+	}
+	m, ok := k.locks[key]
+	if !ok {
+		m = &sync.Mutex{}
+		k.locks[key] = m
+	}
+	k.mu.Unlock()
+
+	m.Lock()
+	return m.Unlock
+}
+
+// bookingLocks serialises table assignment per restaurant within this process,
+// so concurrent bookings are given different tables instead of colliding. The
+// store still rejects overlapping seatings itself, which covers other instances.
+var bookingLocks keyedMutex
+
+// seat is where a reservation sits: a single table, or a table group led by
+// TableID.
+type seat struct {
+	TableID string
+	GroupID string
+}
+
+// freeSeats returns the tables and table groups free to seat guests for the
+// whole seating from start, each best fit first. The reservation named by
+// exclude is ignored so a reservation being changed does not block itself.
+// Every guest's bookings count, so they are read on the server's behalf rather
+// than with the caller's rights.
+func freeSeats(ctx context.Context, store Store, cfg settings, restaurantID string, start time.Time, guests int, exclude string) ([]Table, []TableGroup, error) {
+	ctx = withServiceRole(ctx)
+	f, err := loadFloor(ctx, store, restaurantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	reservations, err := reservationsAround(ctx, store, restaurantID, start)
+	if err != nil {
+		return nil, nil, err
+	}
+	reservations = without(reservations, func(r Reservation) bool { return r.ID == exclude })
+	tables, groups := freeSeating(f, reservations, start, cfg.DiningDuration, guests)
+	return tables, groups, nil
+}
+
+// seatFor picks a seat from the free tables and groups, both ordered best fit
+// first: the requested seat if one was asked for, else the preferred seat while
+// it is still free, else the best single table, else the best group. Groups are
+// only used once no single table fits. ok is false when nothing suitable is free.
+func seatFor(tables []Table, groups []TableGroup, requested, preferred seat) (seat, bool) {
+	if requested != (seat{}) {
+		return freeSeat(tables, groups, requested)
+	}
+	if s, ok := freeSeat(tables, groups, preferred); ok {
+		return s, true
+	}
+	if len(tables) > 0 {
+		return seat{TableID: tables[0].ID}, true
+	}
+	if len(groups) > 0 {
+		return seat{TableID: groups[0].TableIDs[0], GroupID: groups[0].ID}, true
+	}
+	return seat{}, false
+}
+
+// freeSeat reports whether s is among the free tables and groups, filling in the
+// lead table of a group.
+func freeSeat(tables []Table, groups []TableGroup, s seat) (seat, bool) {
+	if s.GroupID != "" {
+		for _, g := range groups {
+			if g.ID == s.GroupID {
+				return seat{TableID: g.TableIDs[0], GroupID: g.ID}, true
+			}
+		}
+		return seat{}, false
+	}
+	if s.TableID != "" && findTable(tables, s.TableID) != nil {
+		return s, true
+	}
+	return seat{}, false
+}
+
+func findTable(tables []Table, id string) *Table {
+	for i := range tables {
+		if tables[i].ID == id {
+			return &tables[i]
 		}
+	}
+	return nil
+}
 
-		// Use json.Unmarshal to parse the response bytes
-		if err := json.Unmarshal(respBytes, &reservations); err != nil {
-			// log.Printf("Error parsing reservation response: %v\n", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to parse response"})
+// noTable answers 409 when candidate cannot be seated. A requested table or
+// group that is booked reports the conflicting slot, on the clock in loc;
+// without a request the caller is pointed at the restaurant's waitlist instead.
+func noTable(c *gin.Context, store Store, cfg settings, loc *time.Location, candidate Reservation) {
+	if candidate.TableID == "" && candidate.GroupID == "" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":    "No table is available for this party at the requested time",
+			"waitlist": "/restaurants/" + candidate.RestaurantID + "/waitlist",
+		})
+		return
+	}
+	if clash := findSeatingConflict(c.Request.Context(), store, cfg, candidate); clash != nil {
+		seatingConflictFound(c, cfg, loc, clash)
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "Table is not available for this party at the requested time"})
+}
+
+// slotTaken answers a failed reservation write. A conflict caused by an
+// overlapping seating on the reservation's table reports that slot; any other
+// failure gets message.
+func slotTaken(c *gin.Context, store Store, cfg settings, loc *time.Location, candidate Reservation, message string, err error) {
+	if errors.Is(err, ErrConflict) {
+		if clash := findSeatingConflict(c.Request.Context(), store, cfg, candidate); clash != nil {
+			seatingConflictFound(c, cfg, loc, clash)
+			return
 		}
+	}
+	c.JSON(storeStatus(err), gin.H{"error": message})
+}
 
-		// Return the fetched reservations as JSON
-		return c.JSON(reservations)
+func seatingConflictFound(c *gin.Context, cfg settings, loc *time.Location, clash *Reservation) {
+	_, end, _ := seatingWindow(*clash, cfg.DiningDuration)
+	c.JSON(http.StatusConflict, gin.H{
+		"error": "Table is already booked for an overlapping time",
+		"conflict": SeatingConflict{
+			TableID: clash.TableID,
+			GroupID: clash.GroupID,
+			Date:    clash.Date,
+			Time:    clash.Time,
+			Until:   end.In(loc).Format(timeLayout),
+		},
 	})
+}
+
+// findSeatingConflict looks up the reservation holding one of candidate's tables
+// during an overlapping seating, or returns nil. Like freeSeats it reads every
+// guest's bookings on the server's behalf.
+func findSeatingConflict(ctx context.Context, store Store, cfg settings, candidate Reservation) *Reservation {
+	ctx = withServiceRole(ctx)
+	day, err := time.Parse(dateLayout, candidate.Date)
+	if err != nil {
+		return nil
+	}
+	existing, err := reservationsAround(ctx, store, candidate.RestaurantID, day)
+	if err != nil {
+		return nil
+	}
+	groups, err := store.ListTableGroups(ctx, candidate.RestaurantID)
+	if err != nil {
+		return nil
+	}
+	if candidate.DurationMinutes == 0 {
+		candidate.DurationMinutes = int(cfg.DiningDuration / time.Minute)
+	}
+	return seatingConflict(candidate, existing, groups)
+}
+
+// bindSlot parses a reservation's date and time on the clock in loc, answering
+// 400 with field errors when they do not parse or name a time skipped there.
+func bindSlot(c *gin.Context, date, clock string, loc *time.Location) (time.Time, bool) {
+	start, err := localSlot(date, clock, loc)
+	if err == nil {
+		return start, true
+	}
+	fields := map[string]string{}
+	if errors.Is(err, errSkippedTime) {
+		fields["time"] = "does not exist on this date in the restaurant's time zone"
+	} else if _, err := time.Parse(dateLayout, date); err != nil {
+		fields["date"] = "must be a date in YYYY-MM-DD format"
+	} else {
+		fields["time"] = "must be a time in HH:MM format"
+	}
+	validationFailed(c, fields)
+	return time.Time{}, false
+}
+
+// loadReservation fetches the reservation named in the route and checks the caller
+// may access it. On failure the response has been written and ok is false.
+func loadReservation(c *gin.Context, store Store) (reservation *Reservation, ok bool) {
+	restaurantID := c.Param("id")
+	reservationID := c.Param("reservation_id")
 
-	// Route to create a new reservation for a specific restaurant
-	app.Post("/restaurants/:restaurantId/reservations", func(c *fiber.Ctx) error {
-		restaurantID := c.Params("restaurantId")
-		var reservation Reservation // Struct to hold the incoming reservation data
+	if restaurantID == "" || reservationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "restaurant_id and reservation_id are required"})
+		return nil, false
+	}
 
-		// Parse the request body into the reservation struct
-		if err := c.BodyParser(&reservation); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	reservation, err := store.GetReservation(c.Request.Context(), restaurantID, reservationID)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch reservations"})
+		return nil, false
+	}
+
+	principal, _ := currentPrincipal(c)
+	allowed, err := canAccessReservation(c.Request.Context(), store, principal, reservation)
+	if err != nil {
+		c.JSON(storeStatus(err), gin.H{"error": "Failed to check permissions"})
+		return nil, false
+	}
+	if !allowed {
+		forbidden(c)
+		return nil, false
+	}
+	return reservation, true
+}
+
+// Get Reservations or Single Reservation for a Restaurant Handler. Staff see every
+// reservation of their restaurant; customers only see their own.
+func getReservations(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID := c.Param("id")
+
+		if restaurantID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "restaurant_id is required"})
+			return
+		}
+
+		restaurant, ok := loadRestaurant(c, store, restaurantID)
+		if !ok {
+			return
+		}
+		if c.Param("reservation_id") != "" {
+			reservation, ok := loadReservation(c, store)
+			if !ok {
+				return
+			}
+			c.JSON(http.StatusOK, inLocation([]Reservation{*reservation}, restaurant.location())[0])
+			return
 		}
 
-		// Set the RestaurantID from the URL parameter
-		reservation.RestaurantID = restaurantID
-		// reservation.Status = "pending" // Example: Set default status
+		principal, _ := currentPrincipal(c)
+		staff, err := isRestaurantStaff(c.Request.Context(), store, principal, restaurantID)
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to check permissions"})
+			return
+		}
+		filter := ReservationFilter{Date: c.Query("date")}
+		if !staff {
+			filter.UserID = principal.UserID
+		}
 
-		// Insert the new reservation into the Supabase table
-		// Adjust Insert call: Added default upsert (false), returning ("representation"),
-		// onConflict (""), and count ("") arguments.
-		// Pass the reservation struct (or a slice/map) as the first argument.
-		_, _, err := client.From("reservations").Insert(reservation, false, "representation", "", "").Execute()
+		reservations, err := store.ListReservations(c.Request.Context(), restaurantID, filter)
 		if err != nil {
-			// log.Printf("Error creating reservation: %v\n", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create reservation"})
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch reservations"})
+			return
 		}
 
-		// Return success message
-		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Reservation created successfully"})
-	})
+		c.JSON(http.StatusOK, inLocation(reservations, restaurant.location()))
+	}
+}
+
+// Create Reservation Handler. Customers always book for themselves; staff may
+// book on behalf of another user and pick the table. Otherwise the party gets
+// the smallest free table that seats it for the whole seating.
+func createReservation(store Store, cfg settings) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID := c.Param("id")
+
+		if restaurantID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "restaurant_id is required"})
+			return
+		}
+
+		var newReservation ReservationCreate
+		if err := c.ShouldBindJSON(&newReservation); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+			return
+		}
+		newReservation.RestaurantID = restaurantID
+
+		restaurant, ok := loadRestaurant(c, store, restaurantID)
+		if !ok {
+			return
+		}
+		loc := restaurant.location()
+		start, ok := bindSlot(c, newReservation.Date, newReservation.Time, loc)
+		if !ok {
+			return
+		}
+
+		principal, _ := currentPrincipal(c)
+		staff, err := isRestaurantStaff(c.Request.Context(), store, principal, restaurantID)
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to check permissions"})
+			return
+		}
+		if !staff {
+			newReservation.UserID = principal.UserID
+			newReservation.TableID = ""
+			newReservation.GroupID = ""
+			newReservation.Status = ""
+		}
+		if newReservation.Status == "" {
+			newReservation.Status = statusPending
+		}
+		if newReservation.Status != statusPending && newReservation.Status != statusConfirmed {
+			validationFailed(c, map[string]string{"status": "must be pending or confirmed"})
+			return
+		}
+		if !checkOpen(c, restaurant, cfg, start) {
+			return
+		}
+		newReservation.StartsAt = start.UTC()
+		newReservation.DurationMinutes = int(cfg.DiningDuration / time.Minute)
+		candidate := Reservation{
+			RestaurantID:    restaurantID,
+			TableID:         newReservation.TableID,
+			GroupID:         newReservation.GroupID,
+			Date:            newReservation.Date,
+			Time:            newReservation.Time,
+			StartsAt:        newReservation.StartsAt,
+			Guests:          newReservation.Guests,
+			Status:          newReservation.Status,
+			DurationMinutes: newReservation.DurationMinutes,
+		}
+
+		unlock := bookingLocks.lock(restaurantID)
+		defer unlock()
+
+		tables, groups, err := freeSeats(c.Request.Context(), store, cfg, restaurantID, start, newReservation.Guests, "")
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to find a table"})
+			return
+		}
+		chosen, ok := seatFor(tables, groups, seat{TableID: newReservation.TableID, GroupID: newReservation.GroupID}, seat{})
+		if !ok {
+			noTable(c, store, cfg, loc, candidate)
+			return
+		}
+		newReservation.TableID, newReservation.GroupID = chosen.TableID, chosen.GroupID
+		candidate.TableID, candidate.GroupID = chosen.TableID, chosen.GroupID
+
+		created, err := store.CreateReservation(c.Request.Context(), newReservation)
+		if err != nil {
+			slotTaken(c, store, cfg, loc, candidate, "Failed to create reservation", err)
+			return
+		}
+		syncReservationTables(c.Request.Context(), store, cfg, candidate)
+
+		created.StartsAt = created.StartsAt.In(loc)
+		c.JSON(http.StatusCreated, gin.H{"message": "Reservation created successfully", "reservation": created})
+	}
+}
+
+// Update Reservation Handler. Customers may reschedule or cancel their own
+// reservation but only staff can move it to another status or table. A status
+// change is made on its own, through the state machine, so a body may not also
+// edit fields. A changed date or time is checked against the opening hours and
+// re-anchored on the restaurant's clock; a changed slot or party size keeps the
+// current table while it still fits and is free. A cancelled or missed seating
+// is offered to the waitlist.
+func updateReservation(store Store, cfg settings) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var updatedReservation ReservationUpdate
+
+		if err := c.ShouldBindJSON(&updatedReservation); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+			return
+		}
+		updatedReservation.StartsAt = nil
+
+		reservation, ok := loadReservation(c, store)
+		if !ok {
+			return
+		}
+
+		requested := seat{TableID: updatedReservation.TableID}
+		if updatedReservation.GroupID != nil {
+			requested.GroupID = *updatedReservation.GroupID
+		}
+		if (updatedReservation.Status != "" && updatedReservation.Status != statusCancelled) || updatedReservation.TableID != "" || updatedReservation.GroupID != nil {
+			principal, _ := currentPrincipal(c)
+			staff, err := isRestaurantStaff(c.Request.Context(), store, principal, reservation.RestaurantID)
+			if err != nil {
+				c.JSON(storeStatus(err), gin.H{"error": "Failed to check permissions"})
+				return
+			}
+			if !staff {
+				forbidden(c)
+				return
+			}
+		}
+
+		// Status changes go through the state machine and are recorded as events
+		status := updatedReservation.Status
+		updatedReservation.Status = ""
+		if status == reservation.Status {
+			status = ""
+		}
+		edited := updatedReservation != (ReservationUpdate{})
+		if status != "" && edited {
+			validationFailed(c, map[string]string{"status": "cannot be changed together with other fields"})
+			return
+		}
+		if status != "" && !canTransition(reservation.Status, status) {
+			illegalTransition(c, reservation.Status, status)
+			return
+		}
+
+		restaurant, ok := loadRestaurant(c, store, reservation.RestaurantID)
+		if !ok {
+			return
+		}
+		loc := restaurant.location()
+
+		// Work out the reservation as it will be after the update
+		changed := *reservation
+		setString(&changed.Date, updatedReservation.Date)
+		setString(&changed.Time, updatedReservation.Time)
+		setInt(&changed.Guests, updatedReservation.Guests)
+		setString(&changed.Status, status)
+		if edited {
+			unlock := bookingLocks.lock(reservation.RestaurantID)
+			defer unlock()
+
+			start, ok := bindSlot(c, changed.Date, changed.Time, loc)
+			if !ok {
+				return
+			}
+			if updatedReservation.Date != "" || updatedReservation.Time != "" {
+				if !checkOpen(c, restaurant, cfg, start) {
+					return
+				}
+				startsAt := start.UTC()
+				updatedReservation.StartsAt = &startsAt
+				changed.StartsAt = startsAt
+			}
+			if occupiesTable(changed) {
+				tables, groups, err := freeSeats(c.Request.Context(), store, cfg, reservation.RestaurantID, start, changed.Guests, reservation.ID)
+				if err != nil {
+					c.JSON(storeStatus(err), gin.H{"error": "Failed to find a table"})
+					return
+				}
+				chosen, ok := seatFor(tables, groups, requested, seat{TableID: reservation.TableID, GroupID: reservation.GroupID})
+				if !ok {
+					changed.TableID, changed.GroupID = requested.TableID, requested.GroupID
+					noTable(c, store, cfg, loc, changed)
+					return
+				}
+				updatedReservation.TableID, updatedReservation.GroupID = chosen.TableID, nil
+				if chosen.GroupID != reservation.GroupID {
+					updatedReservation.GroupID = &chosen.GroupID
+				}
+				changed.TableID, changed.GroupID = chosen.TableID, chosen.GroupID
+			}
+
+			_, err := store.UpdateReservation(c.Request.Context(), reservation.RestaurantID, reservation.ID, updatedReservation)
+			if err != nil {
+				slotTaken(c, store, cfg, loc, changed, "Failed to update reservation", err)
+				return
+			}
+		}
+		if status != "" {
+			if _, ok := moveReservation(c, store, reservation, status); !ok {
+				return
+			}
+		}
+		syncReservationTables(c.Request.Context(), store, cfg, *reservation, changed)
+		if releasesSeating(status) {
+			promoteFreed(c.Request.Context(), store, cfg, changed)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Reservation updated successfully"})
+	}
 }
 
+// Cancel Reservation Handler. Reservations are kept for history, so cancelling
+// marks the row as cancelled instead of deleting it.
+func cancelReservation(store Store, cfg settings) gin.HandlerFunc {
+	return transitionReservation(store, cfg, statusCancelled, "Reservation cancelled successfully")
+}
@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
 	"tabletoppers/mocks"
 	"testing"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetReservations(t *testing.T) {
@@ -20,3 +28,348 @@ func TestGetReservations(t *testing.T) {
 	assert.Len(t, reservations, 2)
 	assert.Equal(t, "res1", reservations[0]["id"])
 }
+
+// withMembers makes GetMember answer for members and treat every other caller
+// as not belonging to the restaurant. Restaurants not registered before the call
+// exist, without a schedule and on UTC.
+func withMembers(store *mockStore, members ...Member) {
+	for _, m := range members {
+		store.On("GetMember", m.RestaurantID, m.UserID).Return(&m, nil).Maybe()
+	}
+	store.On("GetMember", mock.Anything, mock.Anything).Return(nil, ErrNotFound).Maybe()
+	store.On("GetRestaurant", mock.Anything).Return(&Restaurant{}, nil).Maybe()
+}
+
+// withFloor gives restaurantID, open around the clock, the tables and bookings
+// seen when assigning a table. Tests book on 2025-05-01, so the days either side are answered too.
+// Table groups registered before the call take precedence over the empty list.
+func withFloor(store *mockStore, restaurantID string, tables []Table, booked ...Reservation) {
+	store.On("GetRestaurant", restaurantID).Return(&Restaurant{ID: restaurantID}, nil).Maybe()
+	store.On("ListTables", restaurantID).Return(tables, nil).Maybe()
+	store.On("ListTableGroups", restaurantID).Return([]TableGroup{}, nil).Maybe()
+	for _, date := range []string{"2025-04-30", "2025-05-01", "2025-05-02"} {
+		day := []Reservation{}
+		for _, r := range booked {
+			if r.Date == date {
+				day = append(day, r)
+			}
+		}
+		store.On("ListReservations", restaurantID, ReservationFilter{Date: date}).Return(day, nil).Maybe()
+	}
+}
+
+var floorTables = []Table{
+	{ID: "tbl-2", RestaurantID: "res-1", Number: 2, MinCapacity: 1, MaxCapacity: 4},
+	{ID: "tbl-1", RestaurantID: "res-1", Number: 1, MinCapacity: 1, MaxCapacity: 2},
+	{ID: "tbl-3", RestaurantID: "res-1", Number: 3, MinCapacity: 4, MaxCapacity: 8},
+}
+
+// reservationRouter serves the reservation handlers behind the auth middleware.
+func reservationRouter(store Store) *gin.Engine {
+	router := setupRouter()
+	router.Use(authenticate(testVerifier()))
+	router.GET("/restaurants/:id/reservations", getReservations(store))
+	router.GET("/restaurants/:id/reservations/:reservation_id", getReservations(store))
+	router.POST("/restaurants/:id/reservations", createReservation(store, defaultSettings()))
+	router.PUT("/restaurants/:id/reservations/:reservation_id", updateReservation(store, defaultSettings()))
+	router.DELETE("/restaurants/:id/reservations/:reservation_id", cancelReservation(store, defaultSettings()))
+	return router
+}
+
+func TestListReservations_DateFilter(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store, Member{RestaurantID: "res-1", UserID: "staff-1", Role: roleStaff})
+	store.On("ListReservations", "res-1", ReservationFilter{Date: "2025-05-01"}).Return([]Reservation{
+		{ID: "rsv-1", RestaurantID: "res-1", Date: "2025-05-01", Time: "19:00", Guests: 4, Status: "pending"},
+	}, nil)
+	router := reservationRouter(store)
+
+	rr := serve(router, testToken(t, "staff-1", "staff"), http.MethodGet, "/restaurants/res-1/reservations?date=2025-05-01", nil)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var actualBody []Reservation
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualBody))
+	assert.Len(t, actualBody, 1)
+	assert.Equal(t, 4, actualBody[0].Guests)
+	store.AssertExpectations(t)
+}
+
+func TestListReservations_CustomerSeesOwnOnly(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store)
+	store.On("ListReservations", "res-1", ReservationFilter{UserID: "user-1"}).Return([]Reservation{}, nil)
+	router := reservationRouter(store)
+
+	rr := serve(router, testToken(t, "user-1", "customer"), http.MethodGet, "/restaurants/res-1/reservations", nil)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	store.AssertExpectations(t)
+}
+
+func TestListReservations_StaffOfOtherRestaurantSeesOwnOnly(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store, Member{RestaurantID: "res-2", UserID: "staff-2", Role: roleStaff})
+	store.On("ListReservations", "res-1", ReservationFilter{UserID: "staff-2"}).Return([]Reservation{}, nil)
+	router := reservationRouter(store)
+
+	rr := serve(router, testToken(t, "staff-2", "staff"), http.MethodGet, "/restaurants/res-1/reservations", nil)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	store.AssertExpectations(t)
+}
+
+func TestGetSingleReservation_NotFound(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store)
+	store.On("GetReservation", "res-1", "missing").Return(nil, ErrNotFound)
+	router := reservationRouter(store)
+
+	rr := serve(router, testToken(t, "user-1", "customer"), http.MethodGet, "/restaurants/res-1/reservations/missing", nil)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGetSingleReservation_OtherCustomerForbidden(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store)
+	store.On("GetReservation", "res-1", "rsv-1").Return(&Reservation{ID: "rsv-1", RestaurantID: "res-1", UserID: "user-1"}, nil)
+	router := reservationRouter(store)
+
+	rr := serve(router, testToken(t, "user-2", "customer"), http.MethodGet, "/restaurants/res-1/reservations/rsv-1", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = serve(router, testToken(t, "user-1", "customer"), http.MethodGet, "/restaurants/res-1/reservations/rsv-1", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestCreateReservation_DefaultsToPending(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store)
+	withFloor(store, "res-1", floorTables)
+	expected := ReservationCreate{RestaurantID: "res-1", UserID: "user-1", TableID: "tbl-1", Date: "2025-05-01", Time: "19:00", StartsAt: mustSlot(t, "2025-05-01", "19:00"), Guests: 2, Status: "pending", DurationMinutes: 90}
+	store.On("CreateReservation", expected).Return(&Reservation{ID: "rsv-1"}, nil)
+	router := reservationRouter(store)
+
+	body := ReservationCreate{UserID: "someone-else", TableID: "tbl-3", Date: "2025-05-01", Time: "19:00", Guests: 2, Status: "confirmed"}
+	rr := serve(router, testToken(t, "user-1", "customer"), http.MethodPost, "/restaurants/res-1/reservations", body)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	store.AssertExpectations(t)
+}
+
+func TestCreateReservation_StaffBooksForGuest(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store, Member{RestaurantID: "res-1", UserID: "staff-1", Role: roleStaff})
+	withFloor(store, "res-1", floorTables)
+	expected := ReservationCreate{RestaurantID: "res-1", UserID: "user-9", TableID: "tbl-2", Date: "2025-05-01", Time: "19:00", StartsAt: mustSlot(t, "2025-05-01", "19:00"), Guests: 2, Status: "confirmed", DurationMinutes: 90}
+	store.On("CreateReservation", expected).Return(&Reservation{ID: "rsv-1"}, nil)
+	router := reservationRouter(store)
+
+	body := ReservationCreate{UserID: "user-9", TableID: "tbl-2", Date: "2025-05-01", Time: "19:00", Guests: 2, Status: "confirmed"}
+	rr := serve(router, testToken(t, "staff-1", "staff"), http.MethodPost, "/restaurants/res-1/reservations", body)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	store.AssertExpectations(t)
+}
+
+func TestCreateReservation_InvalidInput(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store)
+	router := reservationRouter(store)
+
+	rr := serve(router, testToken(t, "user-1", "customer"), http.MethodPost, "/restaurants/res-1/reservations", gin.H{"date": "2025-05-01"})
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	store.AssertNotCalled(t, "CreateReservation", mock.Anything)
+}
+
+func TestCreateReservation_SkipsBookedTables(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store)
+	withFloor(store, "res-1", floorTables,
+		Reservation{ID: "rsv-8", TableID: "tbl-1", Date: "2025-05-01", Time: "18:30", Guests: 2, Status: "confirmed"},
+		Reservation{ID: "rsv-9", TableID: "tbl-2", Date: "2025-05-01", Time: "21:00", Guests: 2, Status: "cancelled"})
+	store.On("CreateReservation", mock.MatchedBy(func(r ReservationCreate) bool { return r.TableID == "tbl-2" })).Return(&Reservation{ID: "rsv-1", TableID: "tbl-2"}, nil)
+	router := reservationRouter(store)
+
+	body := ReservationCreate{Date: "2025-05-01", Time: "19:00", Guests: 2}
+	rr := serve(router, testToken(t, "user-1", "customer"), http.MethodPost, "/restaurants/res-1/reservations", body)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"table_id":"tbl-2"`)
+	store.AssertExpectations(t)
+}
+
+func TestCreateReservation_NoTableOffersWaitlist(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store, Member{RestaurantID: "res-1", UserID: "staff-1", Role: roleStaff})
+	withFloor(store, "res-1", floorTables,
+		Reservation{ID: "rsv-8", TableID: "tbl-3", Date: "2025-05-01", Time: "20:00", Guests: 6, Status: "confirmed"})
+	router := reservationRouter(store)
+
+	body := ReservationCreate{Date: "2025-05-01", Time: "19:00", Guests: 5}
+	rr := serve(router, testToken(t, "user-1", "customer"), http.MethodPost, "/restaurants/res-1/reservations", body)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	var actualBody map[string]string
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualBody))
+	assert.Equal(t, "/restaurants/res-1/waitlist", actualBody["waitlist"])
+
+	body = ReservationCreate{TableID: "tbl-3", Date: "2025-05-01", Time: "22:00", Guests: 2}
+	rr = serve(router, testToken(t, "staff-1", "staff"), http.MethodPost, "/restaurants/res-1/reservations", body)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	store.AssertNotCalled(t, "CreateReservation", mock.Anything)
+}
+
+func TestCreateReservation_InvalidSlot(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store)
+	router := reservationRouter(store)
+
+	body := ReservationCreate{Date: "2025-05-01", Time: "7pm", Guests: 2}
+	rr := serve(router, testToken(t, "user-1", "customer"), http.MethodPost, "/restaurants/res-1/reservations", body)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"time"`)
+	store.AssertNotCalled(t, "CreateReservation", mock.Anything)
+}
+
+func TestUpdateReservation_RescheduleKeepsOrMovesTable(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store)
+	current := Reservation{ID: "rsv-1", RestaurantID: "res-1", UserID: "user-1", TableID: "tbl-2", Date: "2025-05-01", Time: "19:00", Guests: 2, Status: "confirmed"}
+	withFloor(store, "res-1", floorTables, current,
+		Reservation{ID: "rsv-2", TableID: "tbl-2", Date: "2025-05-01", Time: "21:30", Guests: 3, Status: "confirmed"})
+	store.On("GetReservation", "res-1", "rsv-1").Return(&current, nil)
+	store.On("UpdateReservation", "res-1", "rsv-1", ReservationUpdate{TableID: "tbl-2", Guests: 3}).Return(&current, nil).Once()
+	moved := mustSlot(t, "2025-05-01", "21:00")
+	store.On("UpdateReservation", "res-1", "rsv-1", ReservationUpdate{TableID: "tbl-1", Time: "21:00", StartsAt: &moved}).Return(&current, nil).Once()
+	router := reservationRouter(store)
+	token := testToken(t, "user-1", "customer")
+
+	rr := serve(router, token, http.MethodPut, "/restaurants/res-1/reservations/rsv-1", ReservationUpdate{Guests: 3})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = serve(router, token, http.MethodPut, "/restaurants/res-1/reservations/rsv-1", ReservationUpdate{Time: "21:00"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = serve(router, token, http.MethodPut, "/restaurants/res-1/reservations/rsv-1", ReservationUpdate{TableID: "tbl-3"})
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	store.AssertExpectations(t)
+}
+
+func TestUpdateReservation_CustomerCannotConfirm(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store)
+	store.On("GetReservation", "res-1", "rsv-1").Return(&Reservation{ID: "rsv-1", RestaurantID: "res-1", UserID: "user-1"}, nil)
+	router := reservationRouter(store)
+
+	rr := serve(router, testToken(t, "user-1", "customer"), http.MethodPut, "/restaurants/res-1/reservations/rsv-1", ReservationUpdate{Status: "confirmed"})
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	store.AssertNotCalled(t, "UpdateReservation", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCancelReservation_MarksCancelled(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store)
+	store.On("GetReservation", "res-1", "rsv-1").Return(&Reservation{ID: "rsv-1", RestaurantID: "res-1", UserID: "user-1", Status: "pending"}, nil)
+	store.On("TransitionReservation", ReservationTransition{RestaurantID: "res-1", ReservationID: "rsv-1", From: "pending", To: "cancelled", ActorID: "user-1"}).
+		Return(&Reservation{ID: "rsv-1", Status: "cancelled"}, nil)
+	router := reservationRouter(store)
+
+	rr := serve(router, testToken(t, "user-1", "customer"), http.MethodDelete, "/restaurants/res-1/reservations/rsv-1", nil)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	store.AssertExpectations(t)
+}
+
+func TestCancelReservation_FinalStatusConflicts(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store)
+	store.On("GetReservation", "res-1", "rsv-1").Return(&Reservation{ID: "rsv-1", RestaurantID: "res-1", UserID: "user-1", Status: "completed"}, nil)
+	router := reservationRouter(store)
+
+	rr := serve(router, testToken(t, "user-1", "customer"), http.MethodDelete, "/restaurants/res-1/reservations/rsv-1", nil)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Cannot move a completed reservation to cancelled")
+	store.AssertNotCalled(t, "TransitionReservation", mock.Anything)
+}
+
+func TestCreateReservation_ConcurrentBookingsGetDistinctTables(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	restaurant, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Bistro", Location: "Paris"})
+	require.NoError(t, err)
+	for number := 1; number <= 3; number++ {
+		_, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: number, MinCapacity: 1, MaxCapacity: 2, Status: "available"})
+		require.NoError(t, err)
+	}
+	router := reservationRouter(store)
+	path := "/restaurants/" + restaurant.ID + "/reservations"
+
+	var wg sync.WaitGroup
+	codes := make([]int, 6)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := ReservationCreate{Date: "2025-05-01", Time: "19:00", Guests: 2}
+			codes[i] = serve(router, testToken(t, "user-"+strconv.Itoa(i), "customer"), http.MethodPost, path, body).Code
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, code := range codes {
+		if code == http.StatusCreated {
+			created++
+		} else {
+			assert.Equal(t, http.StatusConflict, code)
+		}
+	}
+	assert.Equal(t, 3, created)
+	booked, err := store.ListReservations(ctx, restaurant.ID, ReservationFilter{})
+	require.NoError(t, err)
+	tables := map[string]bool{}
+	for _, r := range booked {
+		tables[r.TableID] = true
+	}
+	assert.Len(t, tables, 3)
+}
+
+func TestCreateReservation_ReportsConflictingSlot(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	restaurant, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Bistro", Location: "Paris", OwnerID: "manager-1"})
+	require.NoError(t, err)
+	table, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: "available"})
+	require.NoError(t, err)
+	_, err = store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, UserID: "user-1", TableID: table.ID, Date: "2025-05-01", Time: "19:00", Guests: 2, Status: "confirmed", DurationMinutes: 120})
+	require.NoError(t, err)
+	router := reservationRouter(store)
+
+	body := ReservationCreate{TableID: table.ID, Date: "2025-05-01", Time: "20:00", Guests: 2}
+	rr := serve(router, testToken(t, "manager-1", "manager"), http.MethodPost, "/restaurants/"+restaurant.ID+"/reservations", body)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	var actualBody struct {
+		Conflict SeatingConflict `json:"conflict"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &actualBody))
+	assert.Equal(t, SeatingConflict{TableID: table.ID, Date: "2025-05-01", Time: "19:00", Until: "21:00"}, actualBody.Conflict)
+}
+
+func TestCreateReservation_StoreConflictIsReported(t *testing.T) {
+	store := new(mockStore)
+	withMembers(store)
+	withFloor(store, "res-1", floorTables)
+	store.On("CreateReservation", mock.Anything).Return(nil, ErrConflict)
+	router := reservationRouter(store)
+
+	body := ReservationCreate{Date: "2025-05-01", Time: "19:00", Guests: 2}
+	rr := serve(router, testToken(t, "user-1", "customer"), http.MethodPost, "/restaurants/res-1/reservations", body)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
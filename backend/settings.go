@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// settings holds the tunable business rules read from the environment.
+type settings struct {
+	// DiningDuration is how long a party occupies its table.
+	DiningDuration time.Duration
+	// SlotInterval is the step between start times offered by availability search.
+	SlotInterval time.Duration
+	// ReservedLead is how long before a confirmed seating its table shows as reserved.
+	ReservedLead time.Duration
+	// OfferHold is how long a waitlisted party has to accept a table offered to it.
+	OfferHold time.Duration
+	// ReminderOffsets are how long before a confirmed seating its guest is reminded.
+	ReminderOffsets []time.Duration
+	// NoShowGrace is how long after its start a seating nobody was seated for
+	// becomes a no-show.
+	NoShowGrace time.Duration
+}
+
+func defaultSettings() settings {
+	return settings{
+		DiningDuration:  90 * time.Minute,
+		SlotInterval:    30 * time.Minute,
+		ReservedLead:    30 * time.Minute,
+		OfferHold:       10 * time.Minute,
+		ReminderOffsets: []time.Duration{24 * time.Hour, 2 * time.Hour},
+		NoShowGrace:     15 * time.Minute,
+	}
+}
+
+// initSettings reads DINING_DURATION_MINUTES, SLOT_INTERVAL_MINUTES,
+// RESERVED_LEAD_MINUTES, OFFER_HOLD_MINUTES, REMINDER_OFFSETS_MINUTES (a comma
+// separated list) and NO_SHOW_GRACE_MINUTES, falling back to defaultSettings
+// for unset variables.
+func initSettings() (settings, error) {
+	cfg := defaultSettings()
+	if err := minutesFromEnv("DINING_DURATION_MINUTES", &cfg.DiningDuration); err != nil {
+		return cfg, err
+	}
+	if err := minutesFromEnv("SLOT_INTERVAL_MINUTES", &cfg.SlotInterval); err != nil {
+		return cfg, err
+	}
+	if err := minutesFromEnv("RESERVED_LEAD_MINUTES", &cfg.ReservedLead); err != nil {
+		return cfg, err
+	}
+	if err := minutesFromEnv("OFFER_HOLD_MINUTES", &cfg.OfferHold); err != nil {
+		return cfg, err
+	}
+	if value := os.Getenv("REMINDER_OFFSETS_MINUTES"); value != "" {
+		cfg.ReminderOffsets = nil
+		for _, field := range strings.Split(value, ",") {
+			var offset time.Duration
+			if err := parseMinutes("REMINDER_OFFSETS_MINUTES", strings.TrimSpace(field), &offset); err != nil {
+				return cfg, err
+			}
+			cfg.ReminderOffsets = append(cfg.ReminderOffsets, offset)
+		}
+	}
+	if err := minutesFromEnv("NO_SHOW_GRACE_MINUTES", &cfg.NoShowGrace); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// minutesFromEnv parses a positive number of minutes from name into target.
+func minutesFromEnv(name string, target *time.Duration) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	return parseMinutes(name, value, target)
+}
+
+// parseMinutes parses value, a positive number of minutes named name, into target.
+func parseMinutes(name, value string, target *time.Duration) error {
+	minutes, err := strconv.Atoi(value)
+	if err != nil || minutes <= 0 {
+		return fmt.Errorf("%s must be a positive number of minutes, got %q", name, value)
+	}
+	*target = time.Duration(minutes) * time.Minute
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Store is the persistence layer used by the HTTP handlers. Handlers receive it
+// by injection so the backing database can be swapped without touching them.
+//
+// CreateRestaurant records a non-empty OwnerID as a manager of the new restaurant.
+// DeleteTable also deletes the table groups the table belongs to.
+// TransitionReservation changes the status and records a ReservationEvent as one
+// write. SeatWaitlistEntry books the reservation, moves the entry to seated and
+// marks the reservation's tables occupied as one write. ResolveWaitlistOffer
+// only moves pending offers and fails with ErrConflict for any other.
+// RecordNotification assigns the ID and CreatedAt.
+// ClaimJob records that the background job named key has been done and fails
+// with ErrConflict when it already was, so no job runs twice across restarts or
+// server instances. ReleaseJob removes the claim of a job that failed so it can
+// be claimed again.
+type Store interface {
+	ListRestaurants(ctx context.Context, filter RestaurantFilter) ([]Restaurant, error)
+	GetRestaurant(ctx context.Context, id string) (*Restaurant, error)
+	CreateRestaurant(ctx context.Context, restaurant RestaurantCreate) (*Restaurant, error)
+	UpdateRestaurant(ctx context.Context, id string, update RestaurantUpdate) (*Restaurant, error)
+	DeleteRestaurant(ctx context.Context, id string) error
+
+	ListTables(ctx context.Context, restaurantID string) ([]Table, error)
+	GetTable(ctx context.Context, restaurantID, tableID string) (*Table, error)
+	CreateTable(ctx context.Context, table TableCreate) (*Table, error)
+	UpdateTable(ctx context.Context, restaurantID, tableID string, update TableUpdate) (*Table, error)
+	DeleteTable(ctx context.Context, restaurantID, tableID string) error
+
+	ListTableGroups(ctx context.Context, restaurantID string) ([]TableGroup, error)
+	CreateTableGroup(ctx context.Context, group TableGroupCreate) (*TableGroup, error)
+	DeleteTableGroup(ctx context.Context, restaurantID, groupID string) error
+
+	ListWaitlist(ctx context.Context, restaurantID string) ([]WaitlistEntry, error)
+	GetWaitlistEntry(ctx context.Context, restaurantID, entryID string) (*WaitlistEntry, error)
+	CreateWaitlistEntry(ctx context.Context, entry WaitlistEntryCreate) (*WaitlistEntry, error)
+	TransitionWaitlistEntry(ctx context.Context, transition WaitlistTransition) (*WaitlistEntry, error)
+	SeatWaitlistEntry(ctx context.Context, transition WaitlistTransition, reservation ReservationCreate) (*WaitlistEntry, *Reservation, error)
+	DeleteWaitlistEntry(ctx context.Context, restaurantID, entryID string) error
+
+	ListWaitlistOffers(ctx context.Context, restaurantID string) ([]WaitlistOffer, error)
+	GetWaitlistOffer(ctx context.Context, restaurantID, offerID string) (*WaitlistOffer, error)
+	GetWaitlistOfferByReservation(ctx context.Context, restaurantID, reservationID string) (*WaitlistOffer, error)
+	CreateWaitlistOffer(ctx context.Context, offer WaitlistOfferCreate) (*WaitlistOffer, error)
+	ResolveWaitlistOffer(ctx context.Context, restaurantID, offerID, status string) (*WaitlistOffer, error)
+
+	ListReservations(ctx context.Context, restaurantID string, filter ReservationFilter) ([]Reservation, error)
+	GetReservation(ctx context.Context, restaurantID, reservationID string) (*Reservation, error)
+	CreateReservation(ctx context.Context, reservation ReservationCreate) (*Reservation, error)
+	UpdateReservation(ctx context.Context, restaurantID, reservationID string, update ReservationUpdate) (*Reservation, error)
+	TransitionReservation(ctx context.Context, transition ReservationTransition) (*Reservation, error)
+	ListReservationEvents(ctx context.Context, restaurantID, reservationID string) ([]ReservationEvent, error)
+
+	RecordNotification(ctx context.Context, notification Notification) (*Notification, error)
+	ListNotifications(ctx context.Context, restaurantID string) ([]Notification, error)
+	ClaimJob(ctx context.Context, restaurantID, key string) error
+	ReleaseJob(ctx context.Context, restaurantID, key string) error
+
+	ListMembers(ctx context.Context, restaurantID string) ([]Member, error)
+	ListMemberships(ctx context.Context, userID string) ([]Member, error)
+	GetMember(ctx context.Context, restaurantID, userID string) (*Member, error)
+	AddMember(ctx context.Context, member MemberCreate) (*Member, error)
+	RemoveMember(ctx context.Context, restaurantID, userID string) error
+
+	GetProfile(ctx context.Context, userID string) (*Profile, error)
+	GetProfileByUsername(ctx context.Context, username string) (*Profile, error)
+	CreateProfile(ctx context.Context, profile Profile) (*Profile, error)
+}
+
+// RestaurantFilter narrows ListRestaurants. Empty fields are ignored.
+type RestaurantFilter struct {
+	City string   // exact match on location
+	Name string   // case-insensitive substring match
+	IDs  []string // restricts the result to these ids when non-nil
+}
+
+// ReservationFilter narrows ListReservations. Empty fields are ignored.
+type ReservationFilter struct {
+	Date   string
+	UserID string
+}
+
+var (
+	// ErrNotFound is returned when the requested row does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write violates a uniqueness or reference rule.
+	ErrConflict = errors.New("conflict")
+)
+
+// StoreError carries the HTTP status returned by an upstream data service.
+type StoreError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StoreError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("store request failed with status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("store request failed with status %d", e.StatusCode)
+}
+
+// Is lets callers match upstream 404/409 responses against ErrNotFound/ErrConflict.
+func (e *StoreError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	}
+	return false
+}
+
+// storeStatus maps a Store error to the HTTP status a handler should answer with.
+func storeStatus(err error) int {
+	var storeErr *StoreError
+	switch {
+	case errors.As(err, &storeErr):
+		return storeErr.StatusCode
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
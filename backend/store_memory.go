@@ -0,0 +1,864 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryStore is an in-process Store for local development and tests. It applies
+// the same rules as the database schema: table numbers are unique per restaurant,
+// child rows must reference an existing restaurant, and deleting a restaurant
+// cascades to its tables, waitlist, waitlist offers, reservations, reservation
+// events, notifications, job claims and members. Deleting a table also deletes the table groups it belongs
+// to, and deleting a waitlist entry the offers made to it.
+type memoryStore struct {
+	mu            sync.RWMutex
+	restaurants   []Restaurant
+	tables        []Table
+	groups        []TableGroup
+	waitlist      []WaitlistEntry
+	offers        []WaitlistOffer
+	reservations  []Reservation
+	events        []ReservationEvent
+	notifications []Notification
+	claims        []jobClaim
+	members       []Member
+	profiles      []Profile
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{}
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// restaurantExists must be called with mu held.
+func (s *memoryStore) restaurantExists(id string) bool {
+	for _, r := range s.restaurants {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) ListRestaurants(ctx context.Context, filter RestaurantFilter) ([]Restaurant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	restaurants := []Restaurant{}
+	for _, r := range s.restaurants {
+		if filter.City != "" && r.Location != filter.City {
+			continue
+		}
+		if filter.Name != "" && !strings.Contains(strings.ToLower(r.Name), strings.ToLower(filter.Name)) {
+			continue
+		}
+		if filter.IDs != nil && !slices.Contains(filter.IDs, r.ID) {
+			continue
+		}
+		restaurants = append(restaurants, r)
+	}
+	return restaurants, nil
+}
+
+func (s *memoryStore) GetRestaurant(ctx context.Context, id string) (*Restaurant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, r := range s.restaurants {
+		if r.ID == id {
+			return &r, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) CreateRestaurant(ctx context.Context, restaurant RestaurantCreate) (*Restaurant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	created := Restaurant{
+		ID:                  uuid.NewString(),
+		Name:                restaurant.Name,
+		Location:            restaurant.Location,
+		Description:         restaurant.Description,
+		Phone:               restaurant.Phone,
+		Timezone:            restaurant.Timezone,
+		OpeningHours:        restaurant.OpeningHours,
+		SpecialAvailability: restaurant.SpecialAvailability,
+		Img:                 restaurant.Img,
+		OwnerID:             restaurant.OwnerID,
+		CreatedAt:           now(),
+	}
+	s.restaurants = append(s.restaurants, created)
+	if created.OwnerID != "" {
+		s.members = append(s.members, Member{RestaurantID: created.ID, UserID: created.OwnerID, Role: roleManager, CreatedAt: created.CreatedAt})
+	}
+	return &created, nil
+}
+
+func (s *memoryStore) UpdateRestaurant(ctx context.Context, id string, update RestaurantUpdate) (*Restaurant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.restaurants {
+		r := &s.restaurants[i]
+		if r.ID != id {
+			continue
+		}
+		setString(&r.Name, update.Name)
+		setString(&r.Location, update.Location)
+		setString(&r.Description, update.Description)
+		setString(&r.Phone, update.Phone)
+		setString(&r.Timezone, update.Timezone)
+		if update.Timezone != "" {
+			s.rezoneReservations(id, r.location())
+		}
+		if update.OpeningHours != nil {
+			r.OpeningHours = *update.OpeningHours
+		}
+		if update.SpecialAvailability != nil {
+			r.SpecialAvailability = *update.SpecialAvailability
+		}
+		setString(&r.Img, update.Img)
+		updated := *r
+		return &updated, nil
+	}
+	return nil, ErrNotFound
+}
+
+// rezoneReservations re-anchors the restaurant's reservations to loc so they
+// keep their date and time on its clock. It must be called with mu held.
+func (s *memoryStore) rezoneReservations(restaurantID string, loc *time.Location) {
+	for i := range s.reservations {
+		r := &s.reservations[i]
+		if r.RestaurantID != restaurantID {
+			continue
+		}
+		if start, ok := anchorReservation(*r, loc); ok {
+			r.StartsAt = start
+		}
+	}
+}
+
+func (s *memoryStore) DeleteRestaurant(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.restaurantExists(id) {
+		return ErrNotFound
+	}
+	s.restaurants = without(s.restaurants, func(r Restaurant) bool { return r.ID == id })
+	s.tables = without(s.tables, func(t Table) bool { return t.RestaurantID == id })
+	s.groups = without(s.groups, func(g TableGroup) bool { return g.RestaurantID == id })
+	s.waitlist = without(s.waitlist, func(e WaitlistEntry) bool { return e.RestaurantID == id })
+	s.offers = without(s.offers, func(o WaitlistOffer) bool { return o.RestaurantID == id })
+	s.reservations = without(s.reservations, func(r Reservation) bool { return r.RestaurantID == id })
+	s.events = without(s.events, func(e ReservationEvent) bool { return e.RestaurantID == id })
+	s.notifications = without(s.notifications, func(n Notification) bool { return n.RestaurantID == id })
+	s.claims = without(s.claims, func(c jobClaim) bool { return c.restaurantID == id })
+	s.members = without(s.members, func(m Member) bool { return m.RestaurantID == id })
+	return nil
+}
+
+func (s *memoryStore) ListTables(ctx context.Context, restaurantID string) ([]Table, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tables := []Table{}
+	for _, t := range s.tables {
+		if t.RestaurantID == restaurantID {
+			tables = append(tables, t)
+		}
+	}
+	return tables, nil
+}
+
+func (s *memoryStore) GetTable(ctx context.Context, restaurantID, tableID string) (*Table, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, t := range s.tables {
+		if t.RestaurantID == restaurantID && t.ID == tableID {
+			return &t, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// tableNumberTaken must be called with mu held.
+func (s *memoryStore) tableNumberTaken(restaurantID string, number int, exceptID string) bool {
+	for _, t := range s.tables {
+		if t.RestaurantID == restaurantID && t.Number == number && t.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) CreateTable(ctx context.Context, table TableCreate) (*Table, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.restaurantExists(table.RestaurantID) {
+		return nil, fmt.Errorf("%w: restaurant %s does not exist", ErrConflict, table.RestaurantID)
+	}
+	if s.tableNumberTaken(table.RestaurantID, table.Number, "") {
+		return nil, fmt.Errorf("%w: table number %d already exists", ErrConflict, table.Number)
+	}
+
+	created := Table{
+		ID:           uuid.NewString(),
+		RestaurantID: table.RestaurantID,
+		Number:       table.Number,
+		MinCapacity:  table.MinCapacity,
+		MaxCapacity:  table.MaxCapacity,
+		Status:       table.Status,
+		X:            table.X,
+		Y:            table.Y,
+	}
+	s.tables = append(s.tables, created)
+	return &created, nil
+}
+
+func (s *memoryStore) UpdateTable(ctx context.Context, restaurantID, tableID string, update TableUpdate) (*Table, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.tables {
+		t := &s.tables[i]
+		if t.RestaurantID != restaurantID || t.ID != tableID {
+			continue
+		}
+		if update.Number != 0 && s.tableNumberTaken(restaurantID, update.Number, tableID) {
+			return nil, fmt.Errorf("%w: table number %d already exists", ErrConflict, update.Number)
+		}
+		setInt(&t.Number, update.Number)
+		setInt(&t.MinCapacity, update.MinCapacity)
+		setInt(&t.MaxCapacity, update.MaxCapacity)
+		setString(&t.Status, update.Status)
+		setInt(&t.X, update.X)
+		setInt(&t.Y, update.Y)
+		updated := *t
+		return &updated, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) DeleteTable(ctx context.Context, restaurantID, tableID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := len(s.tables)
+	s.tables = without(s.tables, func(t Table) bool { return t.RestaurantID == restaurantID && t.ID == tableID })
+	if len(s.tables) == before {
+		return ErrNotFound
+	}
+	for i := range s.reservations {
+		if s.reservations[i].TableID == tableID {
+			s.reservations[i].TableID = ""
+		}
+	}
+	for i := range s.waitlist {
+		if s.waitlist[i].TableID == tableID {
+			s.waitlist[i].TableID = ""
+		}
+	}
+	s.dropGroups(func(g TableGroup) bool { return slices.Contains(g.TableIDs, tableID) })
+	return nil
+}
+
+func (s *memoryStore) ListTableGroups(ctx context.Context, restaurantID string) ([]TableGroup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	groups := []TableGroup{}
+	for _, g := range s.groups {
+		if g.RestaurantID == restaurantID {
+			g.TableIDs = slices.Clone(g.TableIDs)
+			groups = append(groups, g)
+		}
+	}
+	return groups, nil
+}
+
+func (s *memoryStore) CreateTableGroup(ctx context.Context, group TableGroupCreate) (*TableGroup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.restaurantExists(group.RestaurantID) {
+		return nil, fmt.Errorf("%w: restaurant %s does not exist", ErrConflict, group.RestaurantID)
+	}
+	for _, id := range group.TableIDs {
+		if !slices.ContainsFunc(s.tables, func(t Table) bool { return t.RestaurantID == group.RestaurantID && t.ID == id }) {
+			return nil, fmt.Errorf("%w: table %s does not exist", ErrConflict, id)
+		}
+	}
+
+	created := TableGroup{
+		ID:           uuid.NewString(),
+		RestaurantID: group.RestaurantID,
+		Name:         group.Name,
+		TableIDs:     slices.Clone(group.TableIDs),
+		MinCapacity:  group.MinCapacity,
+		MaxCapacity:  group.MaxCapacity,
+	}
+	s.groups = append(s.groups, created)
+	created.TableIDs = slices.Clone(created.TableIDs)
+	return &created, nil
+}
+
+func (s *memoryStore) DeleteTableGroup(ctx context.Context, restaurantID, groupID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dropGroups(func(g TableGroup) bool { return g.RestaurantID == restaurantID && g.ID == groupID }) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// dropGroups deletes the groups matching drop and takes their reservations off
+// them, reporting whether any matched. It must be called with mu held.
+func (s *memoryStore) dropGroups(drop func(TableGroup) bool) bool {
+	before := len(s.groups)
+	var dropped []string
+	s.groups = without(s.groups, func(g TableGroup) bool {
+		if drop(g) {
+			dropped = append(dropped, g.ID)
+			return true
+		}
+		return false
+	})
+	for i := range s.reservations {
+		if slices.Contains(dropped, s.reservations[i].GroupID) {
+			s.reservations[i].GroupID = ""
+		}
+	}
+	return len(s.groups) < before
+}
+
+func (s *memoryStore) ListWaitlist(ctx context.Context, restaurantID string) ([]WaitlistEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := []WaitlistEntry{}
+	for _, e := range s.waitlist {
+		if e.RestaurantID == restaurantID {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (s *memoryStore) CreateWaitlistEntry(ctx context.Context, entry WaitlistEntryCreate) (*WaitlistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.restaurantExists(entry.RestaurantID) {
+		return nil, fmt.Errorf("%w: restaurant %s does not exist", ErrConflict, entry.RestaurantID)
+	}
+
+	created := WaitlistEntry{
+		ID:           uuid.NewString(),
+		RestaurantID: entry.RestaurantID,
+		UserID:       entry.UserID,
+		Name:         entry.Name,
+		PhoneNumber:  entry.PhoneNumber,
+		PartySize:    entry.PartySize,
+		Status:       waitlistWaiting,
+		CreatedAt:    now(),
+	}
+	s.waitlist = append(s.waitlist, created)
+	return &created, nil
+}
+
+func (s *memoryStore) GetWaitlistEntry(ctx context.Context, restaurantID, entryID string) (*WaitlistEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, e := range s.waitlist {
+		if e.RestaurantID == restaurantID && e.ID == entryID {
+			return &e, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) TransitionWaitlistEntry(ctx context.Context, transition WaitlistTransition) (*WaitlistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.movableEntry(transition)
+	if err != nil {
+		return nil, err
+	}
+	moveEntry(e, transition)
+	moved := *e
+	return &moved, nil
+}
+
+func (s *memoryStore) SeatWaitlistEntry(ctx context.Context, transition WaitlistTransition, reservation ReservationCreate) (*WaitlistEntry, *Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.movableEntry(transition)
+	if err != nil {
+		return nil, nil, err
+	}
+	seated := newReservation(uuid.NewString(), reservation)
+	if err := s.checkSeating(seated); err != nil {
+		return nil, nil, err
+	}
+	s.reservations = append(s.reservations, seated)
+	transition.ReservationID = seated.ID
+	moveEntry(e, transition)
+	held := heldTables(seated, s.groups)
+	for i := range s.tables {
+		if s.tables[i].RestaurantID == seated.RestaurantID && slices.Contains(held, s.tables[i].ID) {
+			s.tables[i].Status = tableOccupied
+		}
+	}
+	moved := *e
+	return &moved, &seated, nil
+}
+
+// movableEntry finds the entry transition moves, failing with ErrConflict when
+// it is no longer in transition.From. It must be called with mu held.
+func (s *memoryStore) movableEntry(transition WaitlistTransition) (*WaitlistEntry, error) {
+	for i := range s.waitlist {
+		e := &s.waitlist[i]
+		if e.RestaurantID != transition.RestaurantID || e.ID != transition.EntryID {
+			continue
+		}
+		if e.Status != transition.From {
+			return nil, fmt.Errorf("%w: waitlist entry %s is %s", ErrConflict, e.ID, e.Status)
+		}
+		return e, nil
+	}
+	return nil, ErrNotFound
+}
+
+func moveEntry(e *WaitlistEntry, transition WaitlistTransition) {
+	e.Status = transition.To
+	setString(&e.TableID, transition.TableID)
+	setString(&e.ReservationID, transition.ReservationID)
+}
+
+func (s *memoryStore) DeleteWaitlistEntry(ctx context.Context, restaurantID, entryID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := len(s.waitlist)
+	s.waitlist = without(s.waitlist, func(e WaitlistEntry) bool { return e.RestaurantID == restaurantID && e.ID == entryID })
+	if len(s.waitlist) == before {
+		return ErrNotFound
+	}
+	s.offers = without(s.offers, func(o WaitlistOffer) bool { return o.EntryID == entryID })
+	return nil
+}
+
+func (s *memoryStore) ListWaitlistOffers(ctx context.Context, restaurantID string) ([]WaitlistOffer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	offers := []WaitlistOffer{}
+	for _, o := range s.offers {
+		if o.RestaurantID == restaurantID {
+			offers = append(offers, o)
+		}
+	}
+	return offers, nil
+}
+
+func (s *memoryStore) GetWaitlistOffer(ctx context.Context, restaurantID, offerID string) (*WaitlistOffer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, o := range s.offers {
+		if o.RestaurantID == restaurantID && o.ID == offerID {
+			return &o, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) GetWaitlistOfferByReservation(ctx context.Context, restaurantID, reservationID string) (*WaitlistOffer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, o := range s.offers {
+		if o.RestaurantID == restaurantID && o.ReservationID == reservationID {
+			return &o, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) CreateWaitlistOffer(ctx context.Context, offer WaitlistOfferCreate) (*WaitlistOffer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !slices.ContainsFunc(s.waitlist, func(e WaitlistEntry) bool { return e.RestaurantID == offer.RestaurantID && e.ID == offer.EntryID }) {
+		return nil, fmt.Errorf("%w: waitlist entry %s does not exist", ErrConflict, offer.EntryID)
+	}
+	if !slices.ContainsFunc(s.reservations, func(r Reservation) bool { return r.RestaurantID == offer.RestaurantID && r.ID == offer.ReservationID }) {
+		return nil, fmt.Errorf("%w: reservation %s does not exist", ErrConflict, offer.ReservationID)
+	}
+
+	created := WaitlistOffer{
+		ID:            uuid.NewString(),
+		RestaurantID:  offer.RestaurantID,
+		EntryID:       offer.EntryID,
+		UserID:        offer.UserID,
+		ReservationID: offer.ReservationID,
+		Status:        offerPending,
+		ExpiresAt:     offer.ExpiresAt.UTC(),
+		CreatedAt:     now(),
+	}
+	s.offers = append(s.offers, created)
+	return &created, nil
+}
+
+func (s *memoryStore) ResolveWaitlistOffer(ctx context.Context, restaurantID, offerID, status string) (*WaitlistOffer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.offers {
+		o := &s.offers[i]
+		if o.RestaurantID != restaurantID || o.ID != offerID {
+			continue
+		}
+		if o.Status != offerPending {
+			return nil, fmt.Errorf("%w: offer %s is %s", ErrConflict, o.ID, o.Status)
+		}
+		o.Status = status
+		resolved := *o
+		return &resolved, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) ListReservations(ctx context.Context, restaurantID string, filter ReservationFilter) ([]Reservation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reservations := []Reservation{}
+	for _, r := range s.reservations {
+		if r.RestaurantID != restaurantID {
+			continue
+		}
+		if filter.Date != "" && r.Date != filter.Date {
+			continue
+		}
+		if filter.UserID != "" && r.UserID != filter.UserID {
+			continue
+		}
+		reservations = append(reservations, r)
+	}
+	return reservations, nil
+}
+
+func (s *memoryStore) GetReservation(ctx context.Context, restaurantID, reservationID string) (*Reservation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, r := range s.reservations {
+		if r.RestaurantID == restaurantID && r.ID == reservationID {
+			return &r, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) CreateReservation(ctx context.Context, reservation ReservationCreate) (*Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.restaurantExists(reservation.RestaurantID) {
+		return nil, fmt.Errorf("%w: restaurant %s does not exist", ErrConflict, reservation.RestaurantID)
+	}
+
+	created := newReservation(uuid.NewString(), reservation)
+	if err := s.checkSeating(created); err != nil {
+		return nil, err
+	}
+	s.reservations = append(s.reservations, created)
+	return &created, nil
+}
+
+// checkSeating rejects a reservation on a missing group or on tables already
+// held for an overlapping seating. It must be called with mu held.
+func (s *memoryStore) checkSeating(candidate Reservation) error {
+	if candidate.GroupID != "" && !slices.ContainsFunc(s.groups, func(g TableGroup) bool { return g.ID == candidate.GroupID }) {
+		return fmt.Errorf("%w: table group %s does not exist", ErrConflict, candidate.GroupID)
+	}
+	if clash := seatingConflict(candidate, s.reservations, s.groups); clash != nil {
+		return fmt.Errorf("%w: table %s is booked at %s %s", ErrConflict, clash.TableID, clash.Date, clash.Time)
+	}
+	return nil
+}
+
+func (s *memoryStore) UpdateReservation(ctx context.Context, restaurantID, reservationID string, update ReservationUpdate) (*Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.reservations {
+		r := &s.reservations[i]
+		if r.RestaurantID != restaurantID || r.ID != reservationID {
+			continue
+		}
+		updated := *r
+		setString(&updated.TableID, update.TableID)
+		if update.GroupID != nil {
+			updated.GroupID = *update.GroupID
+		}
+		setString(&updated.Date, update.Date)
+		setString(&updated.Time, update.Time)
+		if update.StartsAt != nil {
+			updated.StartsAt = update.StartsAt.UTC()
+		}
+		setInt(&updated.Guests, update.Guests)
+		setString(&updated.Status, update.Status)
+		if err := s.checkSeating(updated); err != nil {
+			return nil, err
+		}
+		*r = updated
+		return &updated, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) TransitionReservation(ctx context.Context, transition ReservationTransition) (*Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.reservations {
+		r := &s.reservations[i]
+		if r.RestaurantID != transition.RestaurantID || r.ID != transition.ReservationID {
+			continue
+		}
+		if r.Status != transition.From {
+			return nil, fmt.Errorf("%w: reservation %s is %s", ErrConflict, r.ID, r.Status)
+		}
+		r.Status = transition.To
+		s.events = append(s.events, newReservationEvent(uuid.NewString(), transition))
+		updated := *r
+		return &updated, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) ListReservationEvents(ctx context.Context, restaurantID, reservationID string) ([]ReservationEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := []ReservationEvent{}
+	for _, e := range s.events {
+		if e.RestaurantID == restaurantID && e.ReservationID == reservationID {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (s *memoryStore) RecordNotification(ctx context.Context, notification Notification) (*Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !slices.ContainsFunc(s.reservations, func(r Reservation) bool {
+		return r.RestaurantID == notification.RestaurantID && r.ID == notification.ReservationID
+	}) {
+		return nil, fmt.Errorf("%w: reservation %s does not exist", ErrConflict, notification.ReservationID)
+	}
+	notification.ID = uuid.NewString()
+	notification.CreatedAt = now()
+	s.notifications = append(s.notifications, notification)
+	return &notification, nil
+}
+
+func (s *memoryStore) ListNotifications(ctx context.Context, restaurantID string) ([]Notification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	notifications := []Notification{}
+	for _, n := range s.notifications {
+		if n.RestaurantID == restaurantID {
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications, nil
+}
+
+// jobClaim is a background job that has been done.
+type jobClaim struct {
+	restaurantID, key string
+}
+
+func (s *memoryStore) ClaimJob(ctx context.Context, restaurantID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.restaurantExists(restaurantID) {
+		return fmt.Errorf("%w: restaurant %s does not exist", ErrConflict, restaurantID)
+	}
+	if slices.ContainsFunc(s.claims, func(c jobClaim) bool { return c.key == key }) {
+		return fmt.Errorf("%w: job %s is already claimed", ErrConflict, key)
+	}
+	s.claims = append(s.claims, jobClaim{restaurantID: restaurantID, key: key})
+	return nil
+}
+
+func (s *memoryStore) ReleaseJob(ctx context.Context, restaurantID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.claims = without(s.claims, func(c jobClaim) bool { return c.restaurantID == restaurantID && c.key == key })
+	return nil
+}
+
+func (s *memoryStore) ListMembers(ctx context.Context, restaurantID string) ([]Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	members := []Member{}
+	for _, m := range s.members {
+		if m.RestaurantID == restaurantID {
+			members = append(members, m)
+		}
+	}
+	return members, nil
+}
+
+func (s *memoryStore) ListMemberships(ctx context.Context, userID string) ([]Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	memberships := []Member{}
+	for _, m := range s.members {
+		if m.UserID == userID {
+			memberships = append(memberships, m)
+		}
+	}
+	return memberships, nil
+}
+
+func (s *memoryStore) GetMember(ctx context.Context, restaurantID, userID string) (*Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, m := range s.members {
+		if m.RestaurantID == restaurantID && m.UserID == userID {
+			return &m, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) AddMember(ctx context.Context, member MemberCreate) (*Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.restaurantExists(member.RestaurantID) {
+		return nil, fmt.Errorf("%w: restaurant %s does not exist", ErrConflict, member.RestaurantID)
+	}
+	for _, m := range s.members {
+		if m.RestaurantID == member.RestaurantID && m.UserID == member.UserID {
+			return nil, fmt.Errorf("%w: user %s is already a member", ErrConflict, member.UserID)
+		}
+	}
+
+	created := Member{
+		RestaurantID: member.RestaurantID,
+		UserID:       member.UserID,
+		Role:         member.Role,
+		CreatedAt:    now(),
+	}
+	s.members = append(s.members, created)
+	return &created, nil
+}
+
+func (s *memoryStore) RemoveMember(ctx context.Context, restaurantID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := len(s.members)
+	s.members = without(s.members, func(m Member) bool { return m.RestaurantID == restaurantID && m.UserID == userID })
+	if len(s.members) == before {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *memoryStore) GetProfile(ctx context.Context, userID string) (*Profile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.profiles {
+		if p.UserID == userID {
+			return &p, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) GetProfileByUsername(ctx context.Context, username string) (*Profile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.profiles {
+		if p.Username == username {
+			return &p, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) CreateProfile(ctx context.Context, profile Profile) (*Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.profiles {
+		if p.UserID == profile.UserID || p.Username == profile.Username {
+			return nil, fmt.Errorf("%w: profile already exists", ErrConflict)
+		}
+	}
+
+	profile.CreatedAt = now()
+	s.profiles = append(s.profiles, profile)
+	return &profile, nil
+}
+
+// setString and setInt apply partial updates: zero values mean "unchanged",
+// matching how PostgREST treats the omitempty update structs.
+func setString(field *string, value string) {
+	if value != "" {
+		*field = value
+	}
+}
+
+func setInt(field *int, value int) {
+	if value != 0 {
+		*field = value
+	}
+}
+
+// without returns rows with every element matching drop removed.
+func without[T any](rows []T, drop func(T) bool) []T {
+	kept := rows[:0]
+	for _, row := range rows {
+		if !drop(row) {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,1208 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"modernc.org/sqlite"
+)
+
+// sqlStore implements Store directly on a SQL database through database/sql.
+// SQLite is meant for single-node deployments; Postgres for everything else.
+type sqlStore struct {
+	db       *sql.DB
+	postgres bool
+}
+
+// openSQLStore connects to driver ("sqlite" or "postgres") at dsn and applies any
+// pending migrations.
+func openSQLStore(ctx context.Context, driver, dsn string) (*sqlStore, error) {
+	var s *sqlStore
+	switch driver {
+	case "sqlite":
+		// Foreign keys are off by default in SQLite and must be enabled per connection.
+		if !strings.Contains(dsn, "foreign_keys") {
+			separator := "?"
+			if strings.Contains(dsn, "?") {
+				separator = "&"
+			}
+			dsn += separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+		}
+		db, err := sql.Open("sqlite", dsn)
+		if err != nil {
+			return nil, err
+		}
+		// SQLite allows a single writer; one connection also keeps :memory: databases shared.
+		db.SetMaxOpenConns(1)
+		s = &sqlStore{db: db}
+	case "postgres":
+		db, err := sql.Open("pgx", dsn)
+		if err != nil {
+			return nil, err
+		}
+		s = &sqlStore{db: db, postgres: true}
+	default:
+		return nil, fmt.Errorf("unsupported SQL driver %q", driver)
+	}
+
+	if err := s.db.PingContext(ctx); err != nil {
+		s.db.Close()
+		return nil, fmt.Errorf("error connecting to %s: %w", driver, err)
+	}
+	if err := migrate(ctx, s.db, s.rebind); err != nil {
+		s.db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// rebind rewrites ? placeholders into Postgres' $1, $2, ... form.
+func (s *sqlStore) rebind(query string) string {
+	if !s.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, ch := range query {
+		if ch == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+func (s *sqlStore) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := s.db.ExecContext(ctx, s.rebind(query), args...)
+	return result, mapSQLError(err)
+}
+
+func (s *sqlStore) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	return rows, mapSQLError(err)
+}
+
+// mapSQLError turns unique and foreign key violations into ErrConflict, the same
+// way PostgREST reports them with a 409.
+func mapSQLError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (pgErr.Code == "23505" || pgErr.Code == "23503") {
+		return fmt.Errorf("%w: %s", ErrConflict, pgErr.Message)
+	}
+	var liteErr *sqlite.Error
+	if errors.As(err, &liteErr) && liteErr.Code()&0xff == 19 { // SQLITE_CONSTRAINT
+		return fmt.Errorf("%w: %s", ErrConflict, liteErr.Error())
+	}
+	return err
+}
+
+// affected returns ErrNotFound when a write touched no rows.
+func affected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// nullable stores empty strings as NULL for optional reference columns.
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// timestampLayout keeps microseconds at a fixed width so timestamps stored as
+// text sort in time order.
+const timestampLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+// timestampText formats t in UTC for a timestamp TEXT column.
+func timestampText(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// nullableTime formats t for a nullable timestamp TEXT column, storing the zero
+// time as NULL.
+func nullableTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return timestampText(t)
+}
+
+// textTime scans a timestamp TEXT column into the time it points at. Empty
+// columns scan as the zero time.
+type textTime struct{ t *time.Time }
+
+func (tt textTime) Scan(src interface{}) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	case time.Time:
+		*tt.t = v.UTC()
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into a timestamp", src)
+	}
+	if text == "" {
+		*tt.t = time.Time{}
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339, text)
+	if err != nil {
+		return err
+	}
+	*tt.t = parsed.UTC()
+	return nil
+}
+
+// jsonText encodes value for a JSON TEXT column, storing empty values as an
+// empty string.
+func jsonText(value interface{}) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	switch text := string(encoded); text {
+	case "null", "{}", "[]":
+		return "", nil
+	default:
+		return text, nil
+	}
+}
+
+// fromJSONText decodes a JSON TEXT column into value, leaving it zero when empty.
+func fromJSONText(text string, value interface{}) error {
+	if text == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(text), value)
+}
+
+// assignments collects the non-zero columns of a partial update.
+type assignments struct {
+	columns []string
+	args    []interface{}
+}
+
+func (a *assignments) setString(column, value string) {
+	if value != "" {
+		a.columns = append(a.columns, column+" = ?")
+		a.args = append(a.args, value)
+	}
+}
+
+// setNullable assigns value when it is set; an empty string clears the column.
+func (a *assignments) setNullable(column string, value *string) {
+	if value != nil {
+		a.columns = append(a.columns, column+" = ?")
+		a.args = append(a.args, nullable(*value))
+	}
+}
+
+// setJSON assigns value, encoded by jsonText, when it is set.
+func (a *assignments) setJSON(column string, value interface{}, set bool) error {
+	if !set {
+		return nil
+	}
+	text, err := jsonText(value)
+	if err != nil {
+		return err
+	}
+	a.columns = append(a.columns, column+" = ?")
+	a.args = append(a.args, text)
+	return nil
+}
+
+func (a *assignments) setInt(column string, value int) {
+	if value != 0 {
+		a.columns = append(a.columns, column+" = ?")
+		a.args = append(a.args, value)
+	}
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// withTx runs fn inside a transaction, committing only when fn succeeds.
+func (s *sqlStore) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return mapSQLError(err)
+	}
+	return tx.Commit()
+}
+
+// update applies the collected assignments to the rows matching where. An empty
+// update only checks that the row exists.
+func (s *sqlStore) update(ctx context.Context, table string, a assignments, where string, whereArgs ...interface{}) error {
+	if len(a.columns) == 0 {
+		var exists int
+		row := s.db.QueryRowContext(ctx, s.rebind("SELECT 1 FROM "+table+" WHERE "+where), whereArgs...)
+		if err := row.Scan(&exists); errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		} else if err != nil {
+			return err
+		}
+		return nil
+	}
+	query := "UPDATE " + table + " SET " + strings.Join(a.columns, ", ") + " WHERE " + where
+	result, err := s.exec(ctx, query, append(a.args, whereArgs...)...)
+	if err != nil {
+		return err
+	}
+	return affected(result)
+}
+
+const restaurantColumns = `id, name, location, description, phone, timezone, opening_hours, special_availability, img, COALESCE(owner_id, ''), created_at`
+
+func scanRestaurants(rows *sql.Rows) ([]Restaurant, error) {
+	defer rows.Close()
+	restaurants := []Restaurant{}
+	for rows.Next() {
+		var r Restaurant
+		var hours, special string
+		if err := rows.Scan(&r.ID, &r.Name, &r.Location, &r.Description, &r.Phone, &r.Timezone, &hours, &special, &r.Img, &r.OwnerID, textTime{&r.CreatedAt}); err != nil {
+			return nil, err
+		}
+		if err := fromJSONText(hours, &r.OpeningHours); err != nil {
+			return nil, fmt.Errorf("error decoding opening hours of restaurant %s: %w", r.ID, err)
+		}
+		if err := fromJSONText(special, &r.SpecialAvailability); err != nil {
+			return nil, fmt.Errorf("error decoding special availability of restaurant %s: %w", r.ID, err)
+		}
+		restaurants = append(restaurants, r)
+	}
+	return restaurants, rows.Err()
+}
+
+func (s *sqlStore) ListRestaurants(ctx context.Context, filter RestaurantFilter) ([]Restaurant, error) {
+	query := "SELECT " + restaurantColumns + " FROM restaurants WHERE 1 = 1"
+	var args []interface{}
+	if filter.City != "" {
+		query += " AND location = ?"
+		args = append(args, filter.City)
+	}
+	if filter.Name != "" {
+		query += " AND LOWER(name) LIKE ?"
+		args = append(args, "%"+strings.ToLower(filter.Name)+"%")
+	}
+	if filter.IDs != nil {
+		if len(filter.IDs) == 0 {
+			return []Restaurant{}, nil
+		}
+		query += " AND id IN (?" + strings.Repeat(", ?", len(filter.IDs)-1) + ")"
+		for _, id := range filter.IDs {
+			args = append(args, id)
+		}
+	}
+	rows, err := s.query(ctx, query+" ORDER BY created_at, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanRestaurants(rows)
+}
+
+func (s *sqlStore) GetRestaurant(ctx context.Context, id string) (*Restaurant, error) {
+	rows, err := s.query(ctx, "SELECT "+restaurantColumns+" FROM restaurants WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	restaurants, err := scanRestaurants(rows)
+	if err != nil {
+		return nil, err
+	}
+	return one(restaurants)
+}
+
+func (s *sqlStore) CreateRestaurant(ctx context.Context, restaurant RestaurantCreate) (*Restaurant, error) {
+	created := Restaurant{
+		ID:                  uuid.NewString(),
+		Name:                restaurant.Name,
+		Location:            restaurant.Location,
+		Description:         restaurant.Description,
+		Phone:               restaurant.Phone,
+		Timezone:            restaurant.Timezone,
+		OpeningHours:        restaurant.OpeningHours,
+		SpecialAvailability: restaurant.SpecialAvailability,
+		Img:                 restaurant.Img,
+		OwnerID:             restaurant.OwnerID,
+		CreatedAt:           now(),
+	}
+	hours, err := jsonText(created.OpeningHours)
+	if err != nil {
+		return nil, err
+	}
+	special, err := jsonText(created.SpecialAvailability)
+	if err != nil {
+		return nil, err
+	}
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, s.rebind("INSERT INTO restaurants (id, name, location, description, phone, timezone, opening_hours, special_availability, img, owner_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+			created.ID, created.Name, created.Location, created.Description, created.Phone, created.Timezone, hours, special, created.Img, nullable(created.OwnerID), timestampText(created.CreatedAt))
+		if err != nil || created.OwnerID == "" {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, s.rebind("INSERT INTO restaurant_members ("+memberColumns+") VALUES (?, ?, ?, ?)"),
+			created.ID, created.OwnerID, roleManager, timestampText(created.CreatedAt))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (s *sqlStore) UpdateRestaurant(ctx context.Context, id string, update RestaurantUpdate) (*Restaurant, error) {
+	var a assignments
+	a.setString("name", update.Name)
+	a.setString("location", update.Location)
+	a.setString("description", update.Description)
+	a.setString("phone", update.Phone)
+	a.setString("timezone", update.Timezone)
+	if err := a.setJSON("opening_hours", update.OpeningHours, update.OpeningHours != nil); err != nil {
+		return nil, err
+	}
+	if err := a.setJSON("special_availability", update.SpecialAvailability, update.SpecialAvailability != nil); err != nil {
+		return nil, err
+	}
+	a.setString("img", update.Img)
+	if update.Timezone == "" {
+		if err := s.update(ctx, "restaurants", a, "id = ?", id); err != nil {
+			return nil, err
+		}
+		return s.GetRestaurant(ctx, id)
+	}
+
+	// A new timezone re-anchors the reservations in the same transaction
+	loc, err := time.LoadLocation(update.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, s.rebind("UPDATE restaurants SET "+strings.Join(a.columns, ", ")+" WHERE id = ?"), append(a.args, id)...)
+		if err != nil {
+			return err
+		}
+		if err := affected(result); err != nil {
+			return err
+		}
+		return s.rezoneReservations(ctx, tx, id, loc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetRestaurant(ctx, id)
+}
+
+// rezoneReservations re-anchors the restaurant's reservations to loc so they
+// keep their date and time on its clock.
+func (s *sqlStore) rezoneReservations(ctx context.Context, tx *sql.Tx, restaurantID string, loc *time.Location) error {
+	rows, err := tx.QueryContext(ctx, s.rebind("SELECT "+reservationColumns+" FROM reservations WHERE restaurant_id = ?"), restaurantID)
+	if err != nil {
+		return err
+	}
+	reservations, err := scanReservations(rows)
+	if err != nil {
+		return err
+	}
+	for _, r := range reservations {
+		start, ok := anchorReservation(r, loc)
+		if !ok || start.Equal(r.StartsAt) {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, s.rebind("UPDATE reservations SET starts_at = ? WHERE id = ?"), timestampText(start), r.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) DeleteRestaurant(ctx context.Context, id string) error {
+	result, err := s.exec(ctx, "DELETE FROM restaurants WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return affected(result)
+}
+
+const tableColumns = `id, restaurant_id, number, min_capacity, max_capacity, status, x, y`
+
+func scanTables(rows *sql.Rows) ([]Table, error) {
+	defer rows.Close()
+	tables := []Table{}
+	for rows.Next() {
+		var t Table
+		if err := rows.Scan(&t.ID, &t.RestaurantID, &t.Number, &t.MinCapacity, &t.MaxCapacity, &t.Status, &t.X, &t.Y); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
+
+func (s *sqlStore) ListTables(ctx context.Context, restaurantID string) ([]Table, error) {
+	rows, err := s.query(ctx, "SELECT "+tableColumns+" FROM tables WHERE restaurant_id = ? ORDER BY number", restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	return scanTables(rows)
+}
+
+func (s *sqlStore) GetTable(ctx context.Context, restaurantID, tableID string) (*Table, error) {
+	rows, err := s.query(ctx, "SELECT "+tableColumns+" FROM tables WHERE restaurant_id = ? AND id = ?", restaurantID, tableID)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := scanTables(rows)
+	if err != nil {
+		return nil, err
+	}
+	return one(tables)
+}
+
+func (s *sqlStore) CreateTable(ctx context.Context, table TableCreate) (*Table, error) {
+	created := Table{
+		ID:           uuid.NewString(),
+		RestaurantID: table.RestaurantID,
+		Number:       table.Number,
+		MinCapacity:  table.MinCapacity,
+		MaxCapacity:  table.MaxCapacity,
+		Status:       table.Status,
+		X:            table.X,
+		Y:            table.Y,
+	}
+	_, err := s.exec(ctx, "INSERT INTO tables ("+tableColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		created.ID, created.RestaurantID, created.Number, created.MinCapacity, created.MaxCapacity, created.Status, created.X, created.Y)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (s *sqlStore) UpdateTable(ctx context.Context, restaurantID, tableID string, update TableUpdate) (*Table, error) {
+	var a assignments
+	a.setInt("number", update.Number)
+	a.setInt("min_capacity", update.MinCapacity)
+	a.setInt("max_capacity", update.MaxCapacity)
+	a.setString("status", update.Status)
+	a.setInt("x", update.X)
+	a.setInt("y", update.Y)
+	if err := s.update(ctx, "tables", a, "restaurant_id = ? AND id = ?", restaurantID, tableID); err != nil {
+		return nil, err
+	}
+	return s.GetTable(ctx, restaurantID, tableID)
+}
+
+// DeleteTable removes the table and every group it belongs to; reservations on
+// those groups keep their lead table.
+func (s *sqlStore) DeleteTable(ctx context.Context, restaurantID, tableID string) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, s.rebind("DELETE FROM table_groups WHERE id IN (SELECT group_id FROM table_group_members WHERE table_id = ?)"), tableID)
+		if err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, s.rebind("DELETE FROM tables WHERE restaurant_id = ? AND id = ?"), restaurantID, tableID)
+		if err != nil {
+			return err
+		}
+		return affected(result)
+	})
+}
+
+const tableGroupColumns = `id, restaurant_id, name, min_capacity, max_capacity`
+
+// listTableGroups reads the groups of a restaurant with their tables in order,
+// on the database or inside a transaction.
+func (s *sqlStore) listTableGroups(ctx context.Context, q queryer, restaurantID string) ([]TableGroup, error) {
+	rows, err := q.QueryContext(ctx, s.rebind("SELECT "+tableGroupColumns+" FROM table_groups WHERE restaurant_id = ? ORDER BY name, id"), restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	groups := []TableGroup{}
+	index := map[string]int{}
+	for rows.Next() {
+		var g TableGroup
+		if err := rows.Scan(&g.ID, &g.RestaurantID, &g.Name, &g.MinCapacity, &g.MaxCapacity); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[g.ID] = len(groups)
+		groups = append(groups, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.QueryContext(ctx, s.rebind("SELECT m.group_id, m.table_id FROM table_group_members m JOIN table_groups g ON g.id = m.group_id WHERE g.restaurant_id = ? ORDER BY m.group_id, m.position"), restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var groupID, tableID string
+		if err := rows.Scan(&groupID, &tableID); err != nil {
+			return nil, err
+		}
+		if i, ok := index[groupID]; ok {
+			groups[i].TableIDs = append(groups[i].TableIDs, tableID)
+		}
+	}
+	return groups, rows.Err()
+}
+
+func (s *sqlStore) ListTableGroups(ctx context.Context, restaurantID string) ([]TableGroup, error) {
+	groups, err := s.listTableGroups(ctx, s.db, restaurantID)
+	return groups, mapSQLError(err)
+}
+
+func (s *sqlStore) CreateTableGroup(ctx context.Context, group TableGroupCreate) (*TableGroup, error) {
+	created := TableGroup{
+		ID:           uuid.NewString(),
+		RestaurantID: group.RestaurantID,
+		Name:         group.Name,
+		TableIDs:     slices.Clone(group.TableIDs),
+		MinCapacity:  group.MinCapacity,
+		MaxCapacity:  group.MaxCapacity,
+	}
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, s.rebind("INSERT INTO table_groups ("+tableGroupColumns+") VALUES (?, ?, ?, ?, ?)"),
+			created.ID, created.RestaurantID, created.Name, created.MinCapacity, created.MaxCapacity)
+		if err != nil {
+			return err
+		}
+		for position, tableID := range created.TableIDs {
+			// Selecting the table scopes it to the group's restaurant.
+			result, err := tx.ExecContext(ctx, s.rebind("INSERT INTO table_group_members (group_id, table_id, position) SELECT ?, id, ? FROM tables WHERE restaurant_id = ? AND id = ?"),
+				created.ID, position, created.RestaurantID, tableID)
+			if err != nil {
+				return err
+			}
+			if err := affected(result); err != nil {
+				return fmt.Errorf("%w: table %s does not exist", ErrConflict, tableID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (s *sqlStore) DeleteTableGroup(ctx context.Context, restaurantID, groupID string) error {
+	result, err := s.exec(ctx, "DELETE FROM table_groups WHERE restaurant_id = ? AND id = ?", restaurantID, groupID)
+	if err != nil {
+		return err
+	}
+	return affected(result)
+}
+
+const waitlistColumns = `id, restaurant_id, COALESCE(user_id, ''), name, phone_number, party_size, status, COALESCE(table_id, ''), COALESCE(reservation_id, ''), created_at`
+
+func scanWaitlist(rows *sql.Rows) ([]WaitlistEntry, error) {
+	defer rows.Close()
+	entries := []WaitlistEntry{}
+	for rows.Next() {
+		var e WaitlistEntry
+		if err := rows.Scan(&e.ID, &e.RestaurantID, &e.UserID, &e.Name, &e.PhoneNumber, &e.PartySize, &e.Status, &e.TableID, &e.ReservationID, textTime{&e.CreatedAt}); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *sqlStore) ListWaitlist(ctx context.Context, restaurantID string) ([]WaitlistEntry, error) {
+	rows, err := s.query(ctx, "SELECT "+waitlistColumns+" FROM waitlist WHERE restaurant_id = ? ORDER BY created_at, id", restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	return scanWaitlist(rows)
+}
+
+func (s *sqlStore) CreateWaitlistEntry(ctx context.Context, entry WaitlistEntryCreate) (*WaitlistEntry, error) {
+	created := WaitlistEntry{
+		ID:           uuid.NewString(),
+		RestaurantID: entry.RestaurantID,
+		UserID:       entry.UserID,
+		Name:         entry.Name,
+		PhoneNumber:  entry.PhoneNumber,
+		PartySize:    entry.PartySize,
+		Status:       waitlistWaiting,
+		CreatedAt:    now(),
+	}
+	_, err := s.exec(ctx, "INSERT INTO waitlist (id, restaurant_id, user_id, name, phone_number, party_size, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		created.ID, created.RestaurantID, nullable(created.UserID), created.Name, created.PhoneNumber, created.PartySize, created.Status, timestampText(created.CreatedAt))
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (s *sqlStore) GetWaitlistEntry(ctx context.Context, restaurantID, entryID string) (*WaitlistEntry, error) {
+	rows, err := s.query(ctx, "SELECT "+waitlistColumns+" FROM waitlist WHERE restaurant_id = ? AND id = ?", restaurantID, entryID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := scanWaitlist(rows)
+	if err != nil {
+		return nil, err
+	}
+	return one(entries)
+}
+
+func (s *sqlStore) TransitionWaitlistEntry(ctx context.Context, transition WaitlistTransition) (*WaitlistEntry, error) {
+	var moved *WaitlistEntry
+	err := s.withTx(ctx, func(tx *sql.Tx) (err error) {
+		moved, err = s.moveWaitlistEntry(ctx, tx, transition)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+func (s *sqlStore) SeatWaitlistEntry(ctx context.Context, transition WaitlistTransition, reservation ReservationCreate) (*WaitlistEntry, *Reservation, error) {
+	seated := newReservation(uuid.NewString(), reservation)
+	transition.ReservationID = seated.ID
+	var moved *WaitlistEntry
+	err := s.withTx(ctx, func(tx *sql.Tx) (err error) {
+		if err := s.insertReservation(ctx, tx, seated); err != nil {
+			return err
+		}
+		if moved, err = s.moveWaitlistEntry(ctx, tx, transition); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, s.rebind("UPDATE tables SET status = ? WHERE restaurant_id = ? AND (id = ? OR id IN (SELECT table_id FROM table_group_members WHERE group_id = ?))"),
+			tableOccupied, seated.RestaurantID, seated.TableID, seated.GroupID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return moved, &seated, nil
+}
+
+// moveWaitlistEntry applies transition within tx, failing with ErrConflict when
+// the entry is no longer in transition.From.
+func (s *sqlStore) moveWaitlistEntry(ctx context.Context, tx *sql.Tx, transition WaitlistTransition) (*WaitlistEntry, error) {
+	var a assignments
+	a.setString("status", transition.To)
+	a.setString("table_id", transition.TableID)
+	a.setString("reservation_id", transition.ReservationID)
+	result, err := tx.ExecContext(ctx, s.rebind("UPDATE waitlist SET "+strings.Join(a.columns, ", ")+" WHERE restaurant_id = ? AND id = ? AND status = ?"),
+		append(a.args, transition.RestaurantID, transition.EntryID, transition.From)...)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.QueryContext(ctx, s.rebind("SELECT "+waitlistColumns+" FROM waitlist WHERE restaurant_id = ? AND id = ?"), transition.RestaurantID, transition.EntryID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := scanWaitlist(rows)
+	if err != nil {
+		return nil, err
+	}
+	moved, err := one(entries)
+	if err != nil {
+		return nil, err
+	}
+	if err := affected(result); err != nil {
+		return nil, fmt.Errorf("%w: waitlist entry %s is %s", ErrConflict, moved.ID, moved.Status)
+	}
+	return moved, nil
+}
+
+func (s *sqlStore) DeleteWaitlistEntry(ctx context.Context, restaurantID, entryID string) error {
+	result, err := s.exec(ctx, "DELETE FROM waitlist WHERE restaurant_id = ? AND id = ?", restaurantID, entryID)
+	if err != nil {
+		return err
+	}
+	return affected(result)
+}
+
+const waitlistOfferColumns = `id, restaurant_id, entry_id, COALESCE(user_id, ''), reservation_id, status, expires_at, created_at`
+
+func scanWaitlistOffers(rows *sql.Rows) ([]WaitlistOffer, error) {
+	defer rows.Close()
+	offers := []WaitlistOffer{}
+	for rows.Next() {
+		var o WaitlistOffer
+		if err := rows.Scan(&o.ID, &o.RestaurantID, &o.EntryID, &o.UserID, &o.ReservationID, &o.Status, textTime{&o.ExpiresAt}, textTime{&o.CreatedAt}); err != nil {
+			return nil, err
+		}
+		offers = append(offers, o)
+	}
+	return offers, rows.Err()
+}
+
+func (s *sqlStore) ListWaitlistOffers(ctx context.Context, restaurantID string) ([]WaitlistOffer, error) {
+	rows, err := s.query(ctx, "SELECT "+waitlistOfferColumns+" FROM waitlist_offers WHERE restaurant_id = ? ORDER BY created_at, id", restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	return scanWaitlistOffers(rows)
+}
+
+func (s *sqlStore) GetWaitlistOffer(ctx context.Context, restaurantID, offerID string) (*WaitlistOffer, error) {
+	rows, err := s.query(ctx, "SELECT "+waitlistOfferColumns+" FROM waitlist_offers WHERE restaurant_id = ? AND id = ?", restaurantID, offerID)
+	if err != nil {
+		return nil, err
+	}
+	offers, err := scanWaitlistOffers(rows)
+	if err != nil {
+		return nil, err
+	}
+	return one(offers)
+}
+
+func (s *sqlStore) GetWaitlistOfferByReservation(ctx context.Context, restaurantID, reservationID string) (*WaitlistOffer, error) {
+	rows, err := s.query(ctx, "SELECT "+waitlistOfferColumns+" FROM waitlist_offers WHERE restaurant_id = ? AND reservation_id = ?", restaurantID, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	offers, err := scanWaitlistOffers(rows)
+	if err != nil {
+		return nil, err
+	}
+	return one(offers)
+}
+
+func (s *sqlStore) CreateWaitlistOffer(ctx context.Context, offer WaitlistOfferCreate) (*WaitlistOffer, error) {
+	created := WaitlistOffer{
+		ID:            uuid.NewString(),
+		RestaurantID:  offer.RestaurantID,
+		EntryID:       offer.EntryID,
+		UserID:        offer.UserID,
+		ReservationID: offer.ReservationID,
+		Status:        offerPending,
+		ExpiresAt:     offer.ExpiresAt.UTC(),
+		CreatedAt:     now(),
+	}
+	_, err := s.exec(ctx, "INSERT INTO waitlist_offers (id, restaurant_id, entry_id, user_id, reservation_id, status, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		created.ID, created.RestaurantID, created.EntryID, nullable(created.UserID), created.ReservationID, created.Status, timestampText(created.ExpiresAt), timestampText(created.CreatedAt))
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (s *sqlStore) ResolveWaitlistOffer(ctx context.Context, restaurantID, offerID, status string) (*WaitlistOffer, error) {
+	result, err := s.exec(ctx, "UPDATE waitlist_offers SET status = ? WHERE restaurant_id = ? AND id = ? AND status = ?", status, restaurantID, offerID, offerPending)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := s.GetWaitlistOffer(ctx, restaurantID, offerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := affected(result); err != nil {
+		return nil, fmt.Errorf("%w: offer %s is %s", ErrConflict, resolved.ID, resolved.Status)
+	}
+	return resolved, nil
+}
+
+const reservationColumns = `id, restaurant_id, COALESCE(user_id, ''), COALESCE(table_id, ''), COALESCE(group_id, ''), date, time, COALESCE(starts_at, ''), guests, status, duration_minutes, COALESCE(phone_number, '')`
+
+func scanReservations(rows *sql.Rows) ([]Reservation, error) {
+	defer rows.Close()
+	reservations := []Reservation{}
+	for rows.Next() {
+		var r Reservation
+		if err := rows.Scan(&r.ID, &r.RestaurantID, &r.UserID, &r.TableID, &r.GroupID, &r.Date, &r.Time, textTime{&r.StartsAt}, &r.Guests, &r.Status, &r.DurationMinutes, &r.PhoneNumber); err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+	return reservations, rows.Err()
+}
+
+func (s *sqlStore) ListReservations(ctx context.Context, restaurantID string, filter ReservationFilter) ([]Reservation, error) {
+	query := "SELECT " + reservationColumns + " FROM reservations WHERE restaurant_id = ?"
+	args := []interface{}{restaurantID}
+	if filter.Date != "" {
+		query += " AND date = ?"
+		args = append(args, filter.Date)
+	}
+	if filter.UserID != "" {
+		query += " AND user_id = ?"
+		args = append(args, filter.UserID)
+	}
+	rows, err := s.query(ctx, query+" ORDER BY date, time, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanReservations(rows)
+}
+
+func (s *sqlStore) GetReservation(ctx context.Context, restaurantID, reservationID string) (*Reservation, error) {
+	rows, err := s.query(ctx, "SELECT "+reservationColumns+" FROM reservations WHERE restaurant_id = ? AND id = ?", restaurantID, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	reservations, err := scanReservations(rows)
+	if err != nil {
+		return nil, err
+	}
+	return one(reservations)
+}
+
+// checkSeating fails with ErrConflict when one of candidate's tables is already
+// booked for an overlapping seating. It first takes the write lock on each held
+// table's row, in id order, so concurrent bookings of the tables queue up behind
+// tx: the no-op UPDATE locks the row in Postgres and takes the single writer lock
+// in SQLite.
+func (s *sqlStore) checkSeating(ctx context.Context, tx *sql.Tx, candidate Reservation) error {
+	if (candidate.TableID == "" && candidate.GroupID == "") || !occupiesTable(candidate) {
+		return nil
+	}
+	groups, err := s.listTableGroups(ctx, tx, candidate.RestaurantID)
+	if err != nil {
+		return err
+	}
+	if candidate.GroupID != "" && !slices.ContainsFunc(groups, func(g TableGroup) bool { return g.ID == candidate.GroupID }) {
+		return fmt.Errorf("%w: table group %s does not exist", ErrConflict, candidate.GroupID)
+	}
+	held := slices.Clone(heldTables(candidate, groups))
+	slices.Sort(held)
+	for _, id := range held {
+		if _, err := tx.ExecContext(ctx, s.rebind("UPDATE tables SET number = number WHERE id = ?"), id); err != nil {
+			return err
+		}
+	}
+
+	day, err := time.Parse(dateLayout, candidate.Date)
+	if err != nil {
+		return err
+	}
+	args := []interface{}{candidate.RestaurantID}
+	for offset := -1; offset <= 1; offset++ {
+		args = append(args, day.AddDate(0, 0, offset).Format(dateLayout))
+	}
+	rows, err := tx.QueryContext(ctx, s.rebind("SELECT "+reservationColumns+" FROM reservations WHERE restaurant_id = ? AND date IN (?, ?, ?)"), args...)
+	if err != nil {
+		return err
+	}
+	existing, err := scanReservations(rows)
+	if err != nil {
+		return err
+	}
+	if clash := seatingConflict(candidate, existing, groups); clash != nil {
+		return fmt.Errorf("%w: table %s is booked at %s %s", ErrConflict, clash.TableID, clash.Date, clash.Time)
+	}
+	return nil
+}
+
+func (s *sqlStore) CreateReservation(ctx context.Context, reservation ReservationCreate) (*Reservation, error) {
+	created := newReservation(uuid.NewString(), reservation)
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		return s.insertReservation(ctx, tx, created)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// insertReservation inserts r within tx unless its tables are taken.
+func (s *sqlStore) insertReservation(ctx context.Context, tx *sql.Tx, r Reservation) error {
+	if err := s.checkSeating(ctx, tx, r); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, s.rebind("INSERT INTO reservations (id, restaurant_id, user_id, table_id, group_id, date, time, starts_at, guests, status, duration_minutes, phone_number) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+		r.ID, r.RestaurantID, nullable(r.UserID), nullable(r.TableID), nullable(r.GroupID), r.Date, r.Time, nullableTime(r.StartsAt), r.Guests, r.Status, r.DurationMinutes, nullable(r.PhoneNumber))
+	return err
+}
+
+func (s *sqlStore) UpdateReservation(ctx context.Context, restaurantID, reservationID string, update ReservationUpdate) (*Reservation, error) {
+	var a assignments
+	a.setString("table_id", update.TableID)
+	a.setNullable("group_id", update.GroupID)
+	a.setString("date", update.Date)
+	a.setString("time", update.Time)
+	if update.StartsAt != nil {
+		a.columns = append(a.columns, "starts_at = ?")
+		a.args = append(a.args, timestampText(*update.StartsAt))
+	}
+	a.setInt("guests", update.Guests)
+	a.setString("status", update.Status)
+
+	var updated *Reservation
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, s.rebind("SELECT "+reservationColumns+" FROM reservations WHERE restaurant_id = ? AND id = ?"), restaurantID, reservationID)
+		if err != nil {
+			return err
+		}
+		current, err := scanReservations(rows)
+		if err != nil {
+			return err
+		}
+		if updated, err = one(current); err != nil {
+			return err
+		}
+		setString(&updated.TableID, update.TableID)
+		if update.GroupID != nil {
+			updated.GroupID = *update.GroupID
+		}
+		setString(&updated.Date, update.Date)
+		setString(&updated.Time, update.Time)
+		if update.StartsAt != nil {
+			updated.StartsAt = update.StartsAt.UTC()
+		}
+		setInt(&updated.Guests, update.Guests)
+		setString(&updated.Status, update.Status)
+		if err := s.checkSeating(ctx, tx, *updated); err != nil {
+			return err
+		}
+		if len(a.columns) == 0 {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, s.rebind("UPDATE reservations SET "+strings.Join(a.columns, ", ")+" WHERE restaurant_id = ? AND id = ?"),
+			append(a.args, restaurantID, reservationID)...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s *sqlStore) TransitionReservation(ctx context.Context, transition ReservationTransition) (*Reservation, error) {
+	event := newReservationEvent(uuid.NewString(), transition)
+	var updated *Reservation
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, s.rebind("UPDATE reservations SET status = ? WHERE restaurant_id = ? AND id = ? AND status = ?"),
+			transition.To, transition.RestaurantID, transition.ReservationID, transition.From)
+		if err != nil {
+			return err
+		}
+		rows, err := tx.QueryContext(ctx, s.rebind("SELECT "+reservationColumns+" FROM reservations WHERE restaurant_id = ? AND id = ?"), transition.RestaurantID, transition.ReservationID)
+		if err != nil {
+			return err
+		}
+		current, err := scanReservations(rows)
+		if err != nil {
+			return err
+		}
+		if updated, err = one(current); err != nil {
+			return err
+		}
+		if err := affected(result); err != nil {
+			return fmt.Errorf("%w: reservation %s is %s", ErrConflict, updated.ID, updated.Status)
+		}
+		_, err = tx.ExecContext(ctx, s.rebind("INSERT INTO reservation_events ("+reservationEventColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)"),
+			event.ID, event.ReservationID, event.RestaurantID, event.FromStatus, event.ToStatus, event.ActorID, timestampText(event.CreatedAt))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+const reservationEventColumns = `id, reservation_id, restaurant_id, from_status, to_status, actor_id, created_at`
+
+func (s *sqlStore) ListReservationEvents(ctx context.Context, restaurantID, reservationID string) ([]ReservationEvent, error) {
+	rows, err := s.query(ctx, "SELECT "+reservationEventColumns+" FROM reservation_events WHERE restaurant_id = ? AND reservation_id = ? ORDER BY created_at, id", restaurantID, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []ReservationEvent{}
+	for rows.Next() {
+		var e ReservationEvent
+		if err := rows.Scan(&e.ID, &e.ReservationID, &e.RestaurantID, &e.FromStatus, &e.ToStatus, &e.ActorID, textTime{&e.CreatedAt}); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+const notificationColumns = `id, restaurant_id, reservation_id, kind, channel, recipient, status, error, created_at`
+
+func (s *sqlStore) RecordNotification(ctx context.Context, notification Notification) (*Notification, error) {
+	notification.ID = uuid.NewString()
+	notification.CreatedAt = now()
+	_, err := s.exec(ctx, "INSERT INTO notifications ("+notificationColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		notification.ID, notification.RestaurantID, notification.ReservationID, notification.Kind, notification.Channel,
+		notification.Recipient, notification.Status, notification.Error, timestampText(notification.CreatedAt))
+	if err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+func (s *sqlStore) ListNotifications(ctx context.Context, restaurantID string) ([]Notification, error) {
+	rows, err := s.query(ctx, "SELECT "+notificationColumns+" FROM notifications WHERE restaurant_id = ? ORDER BY created_at, id", restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.RestaurantID, &n.ReservationID, &n.Kind, &n.Channel, &n.Recipient, &n.Status, &n.Error, textTime{&n.CreatedAt}); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+func (s *sqlStore) ClaimJob(ctx context.Context, restaurantID, key string) error {
+	_, err := s.exec(ctx, "INSERT INTO job_claims (key, restaurant_id, claimed_at) VALUES (?, ?, ?)", key, restaurantID, timestampText(now()))
+	return err
+}
+
+func (s *sqlStore) ReleaseJob(ctx context.Context, restaurantID, key string) error {
+	_, err := s.exec(ctx, "DELETE FROM job_claims WHERE restaurant_id = ? AND key = ?", restaurantID, key)
+	return err
+}
+
+const memberColumns = `restaurant_id, user_id, role, created_at`
+
+func scanMembers(rows *sql.Rows) ([]Member, error) {
+	defer rows.Close()
+	members := []Member{}
+	for rows.Next() {
+		var m Member
+		if err := rows.Scan(&m.RestaurantID, &m.UserID, &m.Role, textTime{&m.CreatedAt}); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (s *sqlStore) ListMembers(ctx context.Context, restaurantID string) ([]Member, error) {
+	rows, err := s.query(ctx, "SELECT "+memberColumns+" FROM restaurant_members WHERE restaurant_id = ? ORDER BY created_at, user_id", restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	return scanMembers(rows)
+}
+
+func (s *sqlStore) ListMemberships(ctx context.Context, userID string) ([]Member, error) {
+	rows, err := s.query(ctx, "SELECT "+memberColumns+" FROM restaurant_members WHERE user_id = ? ORDER BY created_at, restaurant_id", userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanMembers(rows)
+}
+
+func (s *sqlStore) GetMember(ctx context.Context, restaurantID, userID string) (*Member, error) {
+	rows, err := s.query(ctx, "SELECT "+memberColumns+" FROM restaurant_members WHERE restaurant_id = ? AND user_id = ?", restaurantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := scanMembers(rows)
+	if err != nil {
+		return nil, err
+	}
+	return one(members)
+}
+
+func (s *sqlStore) AddMember(ctx context.Context, member MemberCreate) (*Member, error) {
+	created := Member{
+		RestaurantID: member.RestaurantID,
+		UserID:       member.UserID,
+		Role:         member.Role,
+		CreatedAt:    now(),
+	}
+	_, err := s.exec(ctx, "INSERT INTO restaurant_members ("+memberColumns+") VALUES (?, ?, ?, ?)",
+		created.RestaurantID, created.UserID, created.Role, timestampText(created.CreatedAt))
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (s *sqlStore) RemoveMember(ctx context.Context, restaurantID, userID string) error {
+	result, err := s.exec(ctx, "DELETE FROM restaurant_members WHERE restaurant_id = ? AND user_id = ?", restaurantID, userID)
+	if err != nil {
+		return err
+	}
+	return affected(result)
+}
+
+const profileColumns = `user_id, username, email, phone_number, first_name, last_name, created_at`
+
+func scanProfiles(rows *sql.Rows) ([]Profile, error) {
+	defer rows.Close()
+	profiles := []Profile{}
+	for rows.Next() {
+		var p Profile
+		if err := rows.Scan(&p.UserID, &p.Username, &p.Email, &p.PhoneNumber, &p.FirstName, &p.LastName, textTime{&p.CreatedAt}); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, rows.Err()
+}
+
+func (s *sqlStore) getProfile(ctx context.Context, column, value string) (*Profile, error) {
+	rows, err := s.query(ctx, "SELECT "+profileColumns+" FROM profiles WHERE "+column+" = ?", value)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := scanProfiles(rows)
+	if err != nil {
+		return nil, err
+	}
+	return one(profiles)
+}
+
+func (s *sqlStore) GetProfile(ctx context.Context, userID string) (*Profile, error) {
+	return s.getProfile(ctx, "user_id", userID)
+}
+
+func (s *sqlStore) GetProfileByUsername(ctx context.Context, username string) (*Profile, error) {
+	return s.getProfile(ctx, "username", username)
+}
+
+func (s *sqlStore) CreateProfile(ctx context.Context, profile Profile) (*Profile, error) {
+	profile.CreatedAt = now()
+	_, err := s.exec(ctx, "INSERT INTO profiles ("+profileColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+		profile.UserID, profile.Username, profile.Email, profile.PhoneNumber, profile.FirstName, profile.LastName, timestampText(profile.CreatedAt))
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
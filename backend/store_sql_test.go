@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSQLiteStore(t *testing.T) *sqlStore {
+	store, err := openSQLStore(context.Background(), "sqlite", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteStore(t *testing.T) {
+	testStoreContract(t, func(t *testing.T) Store { return newSQLiteStore(t) })
+}
+
+// Set TEST_POSTGRES_DSN to an empty scratch database to run the contract against Postgres.
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+	testStoreContract(t, func(t *testing.T) Store {
+		store, err := openSQLStore(context.Background(), "postgres", dsn)
+		require.NoError(t, err)
+		for _, table := range []string{"profiles", "restaurant_members", "reservation_events", "reservations", "waitlist", "table_group_members", "table_groups", "tables", "restaurants"} {
+			_, err := store.db.Exec("DELETE FROM " + table)
+			require.NoError(t, err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+func TestMigrate_IsIdempotent(t *testing.T) {
+	path := "file:" + filepath.Join(t.TempDir(), "migrate.db")
+	first, err := openSQLStore(context.Background(), "sqlite", path)
+	require.NoError(t, err)
+	first.Close()
+
+	second, err := openSQLStore(context.Background(), "sqlite", path)
+	require.NoError(t, err)
+	defer second.Close()
+
+	var applied int
+	require.NoError(t, second.db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied))
+	assert.Equal(t, len(migrations), applied)
+}
+
+func TestSQLStore_Rebind(t *testing.T) {
+	assert.Equal(t, "SELECT 1 WHERE a = ? AND b = ?", (&sqlStore{}).rebind("SELECT 1 WHERE a = ? AND b = ?"))
+	assert.Equal(t, "SELECT 1 WHERE a = $1 AND b = $2", (&sqlStore{postgres: true}).rebind("SELECT 1 WHERE a = ? AND b = ?"))
+}
@@ -0,0 +1,566 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Supabase auth modes select the credentials sent to PostgREST.
+const (
+	// supabaseAuthAnon sends the anon key on every request.
+	supabaseAuthAnon = "anon"
+	// supabaseAuthForward sends the caller's access token so Row Level Security
+	// policies run as that user. Anonymous requests fall back to the anon key.
+	supabaseAuthForward = "forward"
+	// supabaseAuthService sends the service role key, which bypasses RLS.
+	supabaseAuthService = "service"
+)
+
+// supabaseStore implements Store on top of the Supabase PostgREST API.
+type supabaseStore struct {
+	baseURL    string
+	apiKey     string
+	serviceKey string
+	authMode   string
+	client     *http.Client
+}
+
+func newSupabaseStore(baseURL, apiKey string) *supabaseStore {
+	return &supabaseStore{
+		baseURL:  baseURL,
+		apiKey:   apiKey,
+		authMode: supabaseAuthAnon,
+		client:   &http.Client{},
+	}
+}
+
+type accessTokenKey struct{}
+type serviceRoleKey struct{}
+
+// withAccessToken attaches the caller's access token to ctx for forwarding.
+func withAccessToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, accessTokenKey{}, token)
+}
+
+// withServiceRole marks ctx as running on behalf of the server itself, such as a
+// background job. Requests made with it use the service role key when one is set.
+func withServiceRole(ctx context.Context) context.Context {
+	return context.WithValue(ctx, serviceRoleKey{}, true)
+}
+
+// credentials returns the apikey and bearer token to send for ctx.
+func (s *supabaseStore) credentials(ctx context.Context) (apiKey, bearer string) {
+	elevated, _ := ctx.Value(serviceRoleKey{}).(bool)
+	if s.serviceKey != "" && (s.authMode == supabaseAuthService || elevated) {
+		return s.serviceKey, s.serviceKey
+	}
+	if s.authMode == supabaseAuthForward {
+		if token, _ := ctx.Value(accessTokenKey{}).(string); token != "" {
+			return s.apiKey, token
+		}
+	}
+	return s.apiKey, s.apiKey
+}
+
+// do sends a request to /rest/v1/<table> and decodes the JSON response into out.
+// Writes ask PostgREST to return the affected rows so callers can detect misses.
+func (s *supabaseStore) do(ctx context.Context, method, table string, query url.Values, body interface{}, out interface{}) error {
+	endpoint := fmt.Sprintf("%s/rest/v1/%s", s.baseURL, table)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		requestBody, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to create request body: %w", err)
+		}
+		reader = bytes.NewBuffer(requestBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	apiKey, bearer := s.credentials(ctx)
+	req.Header.Set("apikey", apiKey)
+	req.Header.Set("Authorization", "Bearer "+bearer)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if method != http.MethodGet {
+		req.Header.Set("Prefer", "return=representation")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var pgErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&pgErr)
+		return &StoreError{StatusCode: resp.StatusCode, Message: pgErr.Message}
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// eq builds a PostgREST equality filter set from column/value pairs.
+func eq(pairs ...string) url.Values {
+	query := url.Values{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		query.Set(pairs[i], "eq."+pairs[i+1])
+	}
+	return query
+}
+
+// one returns the first row of a PostgREST result, or ErrNotFound.
+func one[T any](rows []T) (*T, error) {
+	if len(rows) == 0 {
+		return nil, ErrNotFound
+	}
+	return &rows[0], nil
+}
+
+func (s *supabaseStore) ListRestaurants(ctx context.Context, filter RestaurantFilter) ([]Restaurant, error) {
+	query := url.Values{}
+	if filter.City != "" {
+		query.Set("location", "eq."+filter.City)
+	}
+	if filter.Name != "" {
+		query.Set("name", "ilike.*"+filter.Name+"*")
+	}
+	if filter.IDs != nil {
+		query.Set("id", "in.("+strings.Join(filter.IDs, ",")+")")
+	}
+	restaurants := []Restaurant{}
+	err := s.do(ctx, http.MethodGet, "restaurants", query, nil, &restaurants)
+	return restaurants, err
+}
+
+func (s *supabaseStore) GetRestaurant(ctx context.Context, id string) (*Restaurant, error) {
+	var restaurants []Restaurant
+	if err := s.do(ctx, http.MethodGet, "restaurants", eq("id", id), nil, &restaurants); err != nil {
+		return nil, err
+	}
+	return one(restaurants)
+}
+
+// CreateRestaurant inserts the restaurant and then its owner membership. PostgREST
+// cannot span both in one transaction, so a failed membership insert removes the
+// restaurant again rather than leaving it without a manager.
+func (s *supabaseStore) CreateRestaurant(ctx context.Context, restaurant RestaurantCreate) (*Restaurant, error) {
+	var restaurants []Restaurant
+	if err := s.do(ctx, http.MethodPost, "restaurants", nil, restaurant, &restaurants); err != nil {
+		return nil, err
+	}
+	created, err := one(restaurants)
+	if err != nil || created.OwnerID == "" {
+		return created, err
+	}
+
+	owner := MemberCreate{RestaurantID: created.ID, UserID: created.OwnerID, Role: roleManager}
+	if _, err := s.AddMember(ctx, owner); err != nil {
+		s.DeleteRestaurant(ctx, created.ID)
+		return nil, err
+	}
+	return created, nil
+}
+
+func (s *supabaseStore) UpdateRestaurant(ctx context.Context, id string, update RestaurantUpdate) (*Restaurant, error) {
+	var restaurants []Restaurant
+	if err := s.do(ctx, http.MethodPatch, "restaurants", eq("id", id), update, &restaurants); err != nil {
+		return nil, err
+	}
+	return one(restaurants)
+}
+
+func (s *supabaseStore) DeleteRestaurant(ctx context.Context, id string) error {
+	var restaurants []Restaurant
+	if err := s.do(ctx, http.MethodDelete, "restaurants", eq("id", id), nil, &restaurants); err != nil {
+		return err
+	}
+	_, err := one(restaurants)
+	return err
+}
+
+func (s *supabaseStore) ListTables(ctx context.Context, restaurantID string) ([]Table, error) {
+	tables := []Table{}
+	err := s.do(ctx, http.MethodGet, "tables", eq("restaurant_id", restaurantID), nil, &tables)
+	return tables, err
+}
+
+func (s *supabaseStore) GetTable(ctx context.Context, restaurantID, tableID string) (*Table, error) {
+	var tables []Table
+	if err := s.do(ctx, http.MethodGet, "tables", eq("restaurant_id", restaurantID, "id", tableID), nil, &tables); err != nil {
+		return nil, err
+	}
+	return one(tables)
+}
+
+func (s *supabaseStore) CreateTable(ctx context.Context, table TableCreate) (*Table, error) {
+	var tables []Table
+	if err := s.do(ctx, http.MethodPost, "tables", nil, table, &tables); err != nil {
+		return nil, err
+	}
+	return one(tables)
+}
+
+func (s *supabaseStore) UpdateTable(ctx context.Context, restaurantID, tableID string, update TableUpdate) (*Table, error) {
+	var tables []Table
+	if err := s.do(ctx, http.MethodPatch, "tables", eq("restaurant_id", restaurantID, "id", tableID), update, &tables); err != nil {
+		return nil, err
+	}
+	return one(tables)
+}
+
+func (s *supabaseStore) DeleteTable(ctx context.Context, restaurantID, tableID string) error {
+	var tables []Table
+	if err := s.do(ctx, http.MethodDelete, "tables", eq("restaurant_id", restaurantID, "id", tableID), nil, &tables); err != nil {
+		return err
+	}
+	_, err := one(tables)
+	return err
+}
+
+func (s *supabaseStore) ListTableGroups(ctx context.Context, restaurantID string) ([]TableGroup, error) {
+	groups := []TableGroup{}
+	err := s.do(ctx, http.MethodGet, "table_groups", eq("restaurant_id", restaurantID), nil, &groups)
+	return groups, err
+}
+
+func (s *supabaseStore) CreateTableGroup(ctx context.Context, group TableGroupCreate) (*TableGroup, error) {
+	var groups []TableGroup
+	if err := s.do(ctx, http.MethodPost, "table_groups", nil, group, &groups); err != nil {
+		return nil, err
+	}
+	return one(groups)
+}
+
+func (s *supabaseStore) DeleteTableGroup(ctx context.Context, restaurantID, groupID string) error {
+	var groups []TableGroup
+	if err := s.do(ctx, http.MethodDelete, "table_groups", eq("restaurant_id", restaurantID, "id", groupID), nil, &groups); err != nil {
+		return err
+	}
+	_, err := one(groups)
+	return err
+}
+
+func (s *supabaseStore) ListWaitlist(ctx context.Context, restaurantID string) ([]WaitlistEntry, error) {
+	entries := []WaitlistEntry{}
+	query := eq("restaurant_id", restaurantID)
+	query.Set("order", "created_at.asc,id.asc")
+	err := s.do(ctx, http.MethodGet, "waitlist", query, nil, &entries)
+	return entries, err
+}
+
+func (s *supabaseStore) CreateWaitlistEntry(ctx context.Context, entry WaitlistEntryCreate) (*WaitlistEntry, error) {
+	var entries []WaitlistEntry
+	if err := s.do(ctx, http.MethodPost, "waitlist", nil, entry, &entries); err != nil {
+		return nil, err
+	}
+	return one(entries)
+}
+
+func (s *supabaseStore) GetWaitlistEntry(ctx context.Context, restaurantID, entryID string) (*WaitlistEntry, error) {
+	var entries []WaitlistEntry
+	if err := s.do(ctx, http.MethodGet, "waitlist", eq("restaurant_id", restaurantID, "id", entryID), nil, &entries); err != nil {
+		return nil, err
+	}
+	return one(entries)
+}
+
+// TransitionWaitlistEntry updates the entry only while it is in transition.From;
+// when no row matches, a second read tells a moved entry from a missing one.
+func (s *supabaseStore) TransitionWaitlistEntry(ctx context.Context, transition WaitlistTransition) (*WaitlistEntry, error) {
+	body := map[string]string{"status": transition.To}
+	if transition.TableID != "" {
+		body["table_id"] = transition.TableID
+	}
+	if transition.ReservationID != "" {
+		body["reservation_id"] = transition.ReservationID
+	}
+	var entries []WaitlistEntry
+	query := eq("restaurant_id", transition.RestaurantID, "id", transition.EntryID, "status", transition.From)
+	if err := s.do(ctx, http.MethodPatch, "waitlist", query, body, &entries); err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		return &entries[0], nil
+	}
+	current, err := s.GetWaitlistEntry(ctx, transition.RestaurantID, transition.EntryID)
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: waitlist entry %s is %s", ErrConflict, current.ID, current.Status)
+}
+
+// SeatWaitlistEntry calls the seat_waitlist_entry function from
+// supabase/reservations.sql, which books the reservation and moves the entry in
+// one transaction.
+func (s *supabaseStore) SeatWaitlistEntry(ctx context.Context, transition WaitlistTransition, reservation ReservationCreate) (*WaitlistEntry, *Reservation, error) {
+	body := map[string]interface{}{
+		"p_restaurant_id": transition.RestaurantID,
+		"p_entry_id":      transition.EntryID,
+		"p_from":          transition.From,
+		"p_reservation":   reservation,
+	}
+	var reservations []Reservation
+	if err := s.do(ctx, http.MethodPost, "rpc/seat_waitlist_entry", nil, body, &reservations); err != nil {
+		return nil, nil, err
+	}
+	seated, err := one(reservations)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry, err := s.GetWaitlistEntry(ctx, transition.RestaurantID, transition.EntryID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return entry, seated, nil
+}
+
+func (s *supabaseStore) DeleteWaitlistEntry(ctx context.Context, restaurantID, entryID string) error {
+	var entries []WaitlistEntry
+	if err := s.do(ctx, http.MethodDelete, "waitlist", eq("restaurant_id", restaurantID, "id", entryID), nil, &entries); err != nil {
+		return err
+	}
+	_, err := one(entries)
+	return err
+}
+
+func (s *supabaseStore) ListWaitlistOffers(ctx context.Context, restaurantID string) ([]WaitlistOffer, error) {
+	offers := []WaitlistOffer{}
+	query := eq("restaurant_id", restaurantID)
+	query.Set("order", "created_at.asc,id.asc")
+	err := s.do(ctx, http.MethodGet, "waitlist_offers", query, nil, &offers)
+	return offers, err
+}
+
+func (s *supabaseStore) GetWaitlistOffer(ctx context.Context, restaurantID, offerID string) (*WaitlistOffer, error) {
+	var offers []WaitlistOffer
+	if err := s.do(ctx, http.MethodGet, "waitlist_offers", eq("restaurant_id", restaurantID, "id", offerID), nil, &offers); err != nil {
+		return nil, err
+	}
+	return one(offers)
+}
+
+func (s *supabaseStore) GetWaitlistOfferByReservation(ctx context.Context, restaurantID, reservationID string) (*WaitlistOffer, error) {
+	var offers []WaitlistOffer
+	if err := s.do(ctx, http.MethodGet, "waitlist_offers", eq("restaurant_id", restaurantID, "reservation_id", reservationID), nil, &offers); err != nil {
+		return nil, err
+	}
+	return one(offers)
+}
+
+func (s *supabaseStore) CreateWaitlistOffer(ctx context.Context, offer WaitlistOfferCreate) (*WaitlistOffer, error) {
+	var offers []WaitlistOffer
+	if err := s.do(ctx, http.MethodPost, "waitlist_offers", nil, offer, &offers); err != nil {
+		return nil, err
+	}
+	return one(offers)
+}
+
+// ResolveWaitlistOffer updates the offer only while it is pending; when no row
+// matches, a second read tells a settled offer from a missing one.
+func (s *supabaseStore) ResolveWaitlistOffer(ctx context.Context, restaurantID, offerID, status string) (*WaitlistOffer, error) {
+	var offers []WaitlistOffer
+	query := eq("restaurant_id", restaurantID, "id", offerID, "status", offerPending)
+	if err := s.do(ctx, http.MethodPatch, "waitlist_offers", query, map[string]string{"status": status}, &offers); err != nil {
+		return nil, err
+	}
+	if len(offers) > 0 {
+		return &offers[0], nil
+	}
+	current, err := s.GetWaitlistOffer(ctx, restaurantID, offerID)
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: offer %s is %s", ErrConflict, current.ID, current.Status)
+}
+
+func (s *supabaseStore) ListReservations(ctx context.Context, restaurantID string, filter ReservationFilter) ([]Reservation, error) {
+	query := eq("restaurant_id", restaurantID)
+	if filter.Date != "" {
+		query.Set("date", "eq."+filter.Date)
+	}
+	if filter.UserID != "" {
+		query.Set("user_id", "eq."+filter.UserID)
+	}
+	reservations := []Reservation{}
+	err := s.do(ctx, http.MethodGet, "reservations", query, nil, &reservations)
+	return reservations, err
+}
+
+func (s *supabaseStore) GetReservation(ctx context.Context, restaurantID, reservationID string) (*Reservation, error) {
+	var reservations []Reservation
+	if err := s.do(ctx, http.MethodGet, "reservations", eq("restaurant_id", restaurantID, "id", reservationID), nil, &reservations); err != nil {
+		return nil, err
+	}
+	return one(reservations)
+}
+
+func (s *supabaseStore) CreateReservation(ctx context.Context, reservation ReservationCreate) (*Reservation, error) {
+	var reservations []Reservation
+	if err := s.do(ctx, http.MethodPost, "reservations", nil, reservation, &reservations); err != nil {
+		return nil, err
+	}
+	return one(reservations)
+}
+
+func (s *supabaseStore) UpdateReservation(ctx context.Context, restaurantID, reservationID string, update ReservationUpdate) (*Reservation, error) {
+	// An empty group id clears the column, so it is sent as null.
+	body := struct {
+		ReservationUpdate
+		GroupID json.RawMessage `json:"group_id,omitempty"`
+	}{ReservationUpdate: update}
+	if update.GroupID != nil {
+		body.GroupID, _ = json.Marshal(nullable(*update.GroupID))
+	}
+	var reservations []Reservation
+	if err := s.do(ctx, http.MethodPatch, "reservations", eq("restaurant_id", restaurantID, "id", reservationID), body, &reservations); err != nil {
+		return nil, err
+	}
+	return one(reservations)
+}
+
+// TransitionReservation calls the transition_reservation function from
+// supabase/reservations.sql, which changes the status and records the event in
+// one transaction.
+func (s *supabaseStore) TransitionReservation(ctx context.Context, transition ReservationTransition) (*Reservation, error) {
+	body := map[string]string{
+		"p_restaurant_id":  transition.RestaurantID,
+		"p_reservation_id": transition.ReservationID,
+		"p_from":           transition.From,
+		"p_to":             transition.To,
+		"p_actor_id":       transition.ActorID,
+	}
+	var reservations []Reservation
+	if err := s.do(ctx, http.MethodPost, "rpc/transition_reservation", nil, body, &reservations); err != nil {
+		return nil, err
+	}
+	return one(reservations)
+}
+
+func (s *supabaseStore) ListReservationEvents(ctx context.Context, restaurantID, reservationID string) ([]ReservationEvent, error) {
+	query := eq("restaurant_id", restaurantID, "reservation_id", reservationID)
+	query.Set("order", "created_at.asc,id.asc")
+	events := []ReservationEvent{}
+	err := s.do(ctx, http.MethodGet, "reservation_events", query, nil, &events)
+	return events, err
+}
+
+func (s *supabaseStore) RecordNotification(ctx context.Context, notification Notification) (*Notification, error) {
+	body := map[string]any{
+		"restaurant_id":  notification.RestaurantID,
+		"reservation_id": notification.ReservationID,
+		"kind":           notification.Kind,
+		"channel":        notification.Channel,
+		"recipient":      notification.Recipient,
+		"status":         notification.Status,
+		"error":          notification.Error,
+	}
+	var notifications []Notification
+	if err := s.do(ctx, http.MethodPost, "notifications", nil, body, &notifications); err != nil {
+		return nil, err
+	}
+	return one(notifications)
+}
+
+func (s *supabaseStore) ListNotifications(ctx context.Context, restaurantID string) ([]Notification, error) {
+	query := eq("restaurant_id", restaurantID)
+	query.Set("order", "created_at.asc,id.asc")
+	notifications := []Notification{}
+	err := s.do(ctx, http.MethodGet, "notifications", query, nil, &notifications)
+	return notifications, err
+}
+
+// ClaimJob relies on the primary key of job_claims: PostgREST answers a second
+// claim of key with 409.
+func (s *supabaseStore) ClaimJob(ctx context.Context, restaurantID, key string) error {
+	return s.do(ctx, http.MethodPost, "job_claims", nil, map[string]string{"key": key, "restaurant_id": restaurantID}, nil)
+}
+
+func (s *supabaseStore) ReleaseJob(ctx context.Context, restaurantID, key string) error {
+	return s.do(ctx, http.MethodDelete, "job_claims", eq("restaurant_id", restaurantID, "key", key), nil, nil)
+}
+
+func (s *supabaseStore) ListMembers(ctx context.Context, restaurantID string) ([]Member, error) {
+	members := []Member{}
+	err := s.do(ctx, http.MethodGet, "restaurant_members", eq("restaurant_id", restaurantID), nil, &members)
+	return members, err
+}
+
+func (s *supabaseStore) ListMemberships(ctx context.Context, userID string) ([]Member, error) {
+	memberships := []Member{}
+	err := s.do(ctx, http.MethodGet, "restaurant_members", eq("user_id", userID), nil, &memberships)
+	return memberships, err
+}
+
+func (s *supabaseStore) GetMember(ctx context.Context, restaurantID, userID string) (*Member, error) {
+	var members []Member
+	if err := s.do(ctx, http.MethodGet, "restaurant_members", eq("restaurant_id", restaurantID, "user_id", userID), nil, &members); err != nil {
+		return nil, err
+	}
+	return one(members)
+}
+
+func (s *supabaseStore) AddMember(ctx context.Context, member MemberCreate) (*Member, error) {
+	var members []Member
+	if err := s.do(ctx, http.MethodPost, "restaurant_members", nil, member, &members); err != nil {
+		return nil, err
+	}
+	return one(members)
+}
+
+func (s *supabaseStore) RemoveMember(ctx context.Context, restaurantID, userID string) error {
+	var members []Member
+	if err := s.do(ctx, http.MethodDelete, "restaurant_members", eq("restaurant_id", restaurantID, "user_id", userID), nil, &members); err != nil {
+		return err
+	}
+	_, err := one(members)
+	return err
+}
+
+func (s *supabaseStore) GetProfile(ctx context.Context, userID string) (*Profile, error) {
+	var profiles []Profile
+	if err := s.do(ctx, http.MethodGet, "profiles", eq("user_id", userID), nil, &profiles); err != nil {
+		return nil, err
+	}
+	return one(profiles)
+}
+
+func (s *supabaseStore) GetProfileByUsername(ctx context.Context, username string) (*Profile, error) {
+	var profiles []Profile
+	if err := s.do(ctx, http.MethodGet, "profiles", eq("username", username), nil, &profiles); err != nil {
+		return nil, err
+	}
+	return one(profiles)
+}
+
+func (s *supabaseStore) CreateProfile(ctx context.Context, profile Profile) (*Profile, error) {
+	var profiles []Profile
+	profile.CreatedAt = now()
+	if err := s.do(ctx, http.MethodPost, "profiles", nil, profile, &profiles); err != nil {
+		return nil, err
+	}
+	return one(profiles)
+}
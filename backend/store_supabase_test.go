@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePostgREST starts a test server answering every request with status/body
+// and records the last request it saw.
+func fakePostgREST(t *testing.T, status int, body string) (*supabaseStore, *http.Request) {
+	last := &http.Request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*last = *r.Clone(r.Context())
+		payload, _ := io.ReadAll(r.Body)
+		last.Body = io.NopCloser(bytes.NewReader(payload))
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return newSupabaseStore(server.URL, "test-anon-key"), last
+}
+
+func TestSupabaseStore_ListReservationsFilters(t *testing.T) {
+	store, last := fakePostgREST(t, http.StatusOK, `[{"id":"rsv-1","restaurant_id":"res-1","date":"2025-05-01","time":"19:00","guests":4}]`)
+
+	reservations, err := store.ListReservations(context.Background(), "res-1", ReservationFilter{Date: "2025-05-01"})
+
+	assert.NoError(t, err)
+	assert.Len(t, reservations, 1)
+	assert.Equal(t, "/rest/v1/reservations", last.URL.Path)
+	assert.Equal(t, "eq.res-1", last.URL.Query().Get("restaurant_id"))
+	assert.Equal(t, "eq.2025-05-01", last.URL.Query().Get("date"))
+	assert.Equal(t, "test-anon-key", last.Header.Get("apikey"))
+	assert.Equal(t, "Bearer test-anon-key", last.Header.Get("Authorization"))
+}
+
+func TestSupabaseStore_ListRestaurantsFilters(t *testing.T) {
+	store, last := fakePostgREST(t, http.StatusOK, `[]`)
+
+	_, err := store.ListRestaurants(context.Background(), RestaurantFilter{City: "Gainesville", Name: "pizza"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "eq.Gainesville", last.URL.Query().Get("location"))
+	assert.Equal(t, "ilike.*pizza*", last.URL.Query().Get("name"))
+}
+
+func TestSupabaseStore_ListRestaurantsByIDs(t *testing.T) {
+	store, last := fakePostgREST(t, http.StatusOK, `[]`)
+
+	_, err := store.ListRestaurants(context.Background(), RestaurantFilter{IDs: []string{"res-1", "res-2"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "in.(res-1,res-2)", last.URL.Query().Get("id"))
+}
+
+func TestSupabaseStore_CreateRestaurantAddsOwner(t *testing.T) {
+	store, last := fakePostgREST(t, http.StatusCreated, `[{"id":"res-1","restaurant_id":"res-1","user_id":"owner-1","role":"manager","owner_id":"owner-1"}]`)
+
+	restaurant, err := store.CreateRestaurant(context.Background(), RestaurantCreate{Name: "Diner", Location: "Here", OwnerID: "owner-1"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "owner-1", restaurant.OwnerID)
+	assert.Equal(t, "/rest/v1/restaurant_members", last.URL.Path)
+	var sent MemberCreate
+	assert.NoError(t, json.NewDecoder(last.Body).Decode(&sent))
+	assert.Equal(t, MemberCreate{RestaurantID: "res-1", UserID: "owner-1", Role: roleManager}, sent)
+}
+
+func TestSupabaseStore_CreateTableReturnsRow(t *testing.T) {
+	store, last := fakePostgREST(t, http.StatusCreated, `[{"id":"tbl-1","restaurant_id":"res-1","number":3}]`)
+
+	table, err := store.CreateTable(context.Background(), TableCreate{RestaurantID: "res-1", Number: 3, MinCapacity: 1, MaxCapacity: 2, Status: "available"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "tbl-1", table.ID)
+	assert.Equal(t, http.MethodPost, last.Method)
+	assert.Equal(t, "return=representation", last.Header.Get("Prefer"))
+	var sent TableCreate
+	assert.NoError(t, json.NewDecoder(last.Body).Decode(&sent))
+	assert.Equal(t, 3, sent.Number)
+}
+
+func TestSupabaseStore_UpdateMissingRowIsNotFound(t *testing.T) {
+	store, last := fakePostgREST(t, http.StatusOK, `[]`)
+
+	_, err := store.UpdateReservation(context.Background(), "res-1", "missing", ReservationUpdate{Status: "cancelled"})
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, http.MethodPatch, last.Method)
+	var sent map[string]interface{}
+	assert.NoError(t, json.NewDecoder(last.Body).Decode(&sent))
+	assert.Equal(t, map[string]interface{}{"status": "cancelled"}, sent)
+}
+
+func TestSupabaseStore_UpstreamErrorKeepsStatus(t *testing.T) {
+	store, _ := fakePostgREST(t, http.StatusConflict, `{"message":"duplicate key value violates unique constraint"}`)
+
+	_, err := store.CreateRestaurant(context.Background(), RestaurantCreate{Name: "Dup", Location: "Here"})
+
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, http.StatusConflict, storeStatus(err))
+}
+
+func TestSupabaseStore_AuthModes(t *testing.T) {
+	userCtx := withAccessToken(context.Background(), "user-jwt")
+	jobCtx := withServiceRole(context.Background())
+
+	cases := []struct {
+		name, mode, serviceKey string
+		ctx                    context.Context
+		apiKey, bearer         string
+	}{
+		{"anon ignores the caller", supabaseAuthAnon, "", userCtx, "test-anon-key", "test-anon-key"},
+		{"forward sends the caller's token", supabaseAuthForward, "", userCtx, "test-anon-key", "user-jwt"},
+		{"forward without a caller stays anonymous", supabaseAuthForward, "", context.Background(), "test-anon-key", "test-anon-key"},
+		{"forward elevates jobs with a service key", supabaseAuthForward, "service-key", jobCtx, "service-key", "service-key"},
+		{"jobs without a service key stay anonymous", supabaseAuthForward, "", jobCtx, "test-anon-key", "test-anon-key"},
+		{"service always uses the service key", supabaseAuthService, "service-key", userCtx, "service-key", "service-key"},
+	}
+	for _, tc := range cases {
+		store, last := fakePostgREST(t, http.StatusOK, `[]`)
+		store.authMode = tc.mode
+		store.serviceKey = tc.serviceKey
+
+		_, err := store.ListTables(tc.ctx, "res-1")
+
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.apiKey, last.Header.Get("apikey"), tc.name)
+		assert.Equal(t, "Bearer "+tc.bearer, last.Header.Get("Authorization"), tc.name)
+	}
+}
+
+// Set TEST_SUPABASE_DB_URL to the database of a scratch Supabase project, such as
+// the one `supabase start` runs, with the scripts in supabase/ applied.
+func TestSupabaseSQL_OverlapGuardIgnoresCallerPolicies(t *testing.T) {
+	dsn := os.Getenv("TEST_SUPABASE_DB_URL")
+	if dsn == "" {
+		t.Skip("TEST_SUPABASE_DB_URL not set")
+	}
+	ctx := context.Background()
+	db, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	restaurantID, tableID := uuid.NewString(), uuid.NewString()
+	ann, bob := uuid.NewString(), uuid.NewString()
+	for _, stmt := range []string{
+		`INSERT INTO restaurants (id, name, location) VALUES ($1, 'Diner', 'X')`,
+		`INSERT INTO tables (id, restaurant_id, number, min_capacity, max_capacity, status) VALUES ($2, $1, 1, 1, 4, 'available')`,
+		`INSERT INTO reservations (id, restaurant_id, user_id, table_id, date, time, starts_at, guests, status, duration_minutes)
+		 VALUES (gen_random_uuid()::text, $1, $3, $2, '2025-06-01', '19:00', '2025-06-01 19:00Z', 2, 'confirmed', 90)`,
+	} {
+		_, err := tx.ExecContext(ctx, stmt, restaurantID, tableID, ann)
+		require.NoError(t, err)
+	}
+
+	// Bob is a customer: his policies show him neither Ann's booking nor a
+	// writable table row, yet the guard still sees the clash
+	_, err = tx.ExecContext(ctx, `SET LOCAL ROLE authenticated`)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, `SELECT set_config('request.jwt.claims', json_build_object('sub', $1::text, 'role', 'authenticated')::text, true)`, bob)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, `INSERT INTO reservations (id, restaurant_id, user_id, table_id, date, time, starts_at, guests, status, duration_minutes)
+		VALUES (gen_random_uuid()::text, $1, $2, $3, '2025-06-01', '19:30', '2025-06-01 19:30Z', 2, 'pending', 90)`, restaurantID, bob, tableID)
+	var pgErr *pgconn.PgError
+	require.ErrorAs(t, err, &pgErr)
+	assert.Equal(t, "23P01", pgErr.Code, "exclusion_violation")
+}
+
+// rlsPostgREST starts a fake PostgREST for restaurant res-1 with one table,
+// booked by another guest at 19:00 on 2025-06-01. Like Row Level Security, it
+// shows that booking only to requests made with the service key.
+func rlsPostgREST(t *testing.T) *supabaseStore {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/v1/restaurants":
+			w.Write([]byte(`[{"id":"res-1","name":"Diner","location":"X"}]`))
+		case "/rest/v1/tables":
+			w.Write([]byte(`[{"id":"t1","restaurant_id":"res-1","number":1,"min_capacity":1,"max_capacity":4,"status":"available"}]`))
+		case "/rest/v1/reservations":
+			if r.Header.Get("Authorization") != "Bearer service-key" || r.URL.Query().Get("date") != "eq.2025-06-01" {
+				w.Write([]byte(`[]`))
+				return
+			}
+			w.Write([]byte(`[{"id":"rsv-1","restaurant_id":"res-1","user_id":"ann","table_id":"t1","date":"2025-06-01","time":"19:00",
+				"starts_at":"2025-06-01T19:00:00Z","guests":2,"status":"confirmed","duration_minutes":90}]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	store := newSupabaseStore(server.URL, "test-anon-key")
+	store.authMode = supabaseAuthForward
+	store.serviceKey = "service-key"
+	return store
+}
+
+func TestSupabaseStore_SeatsCountOtherGuestsBookings(t *testing.T) {
+	store := rlsPostgREST(t)
+	cfg := defaultSettings()
+	start := time.Date(2025, 6, 1, 19, 30, 0, 0, time.UTC)
+
+	// A customer booking sees only their own reservations
+	customer := withAccessToken(context.Background(), "customer-jwt")
+	tables, groups, err := freeSeats(customer, store, cfg, "res-1", start, 2, "")
+	require.NoError(t, err)
+	assert.Empty(t, tables)
+	assert.Empty(t, groups)
+
+	// An anonymous availability check sees none
+	router := setupRouter()
+	router.GET("/restaurants/:id/availability", getAvailability(store, cfg))
+	rr := serve(router, "", http.MethodGet, "/restaurants/res-1/availability?date=2025-06-01&time=19:30&party_size=2", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var availability TableAvailability
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &availability))
+	assert.False(t, availability.Available)
+}
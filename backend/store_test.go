@@ -0,0 +1,514 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testStoreContract runs the behaviour every Store implementation must share
+// against a fresh store returned by newStore.
+func testStoreContract(t *testing.T, newStore func(t *testing.T) Store) {
+	ctx := context.Background()
+
+	t.Run("restaurant CRUD and filters", func(t *testing.T) {
+		store := newStore(t)
+		created, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Pizza Palace", Location: "Gainesville"})
+		require.NoError(t, err)
+		assert.NotEmpty(t, created.ID)
+		assert.NotEmpty(t, created.CreatedAt)
+		_, err = store.CreateRestaurant(ctx, RestaurantCreate{Name: "Taco Town", Location: "Orlando"})
+		require.NoError(t, err)
+
+		byCity, err := store.ListRestaurants(ctx, RestaurantFilter{City: "Gainesville"})
+		require.NoError(t, err)
+		assert.Len(t, byCity, 1)
+		byName, err := store.ListRestaurants(ctx, RestaurantFilter{Name: "taco"})
+		require.NoError(t, err)
+		require.Len(t, byName, 1)
+		assert.Equal(t, "Taco Town", byName[0].Name)
+
+		updated, err := store.UpdateRestaurant(ctx, created.ID, RestaurantUpdate{Phone: "555-0100"})
+		require.NoError(t, err)
+		assert.Equal(t, "555-0100", updated.Phone)
+		assert.Equal(t, "Pizza Palace", updated.Name, "partial update must keep other fields")
+
+		require.NoError(t, store.DeleteRestaurant(ctx, created.ID))
+		_, err = store.GetRestaurant(ctx, created.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorIs(t, store.DeleteRestaurant(ctx, created.ID), ErrNotFound)
+	})
+
+	t.Run("restaurant schedules round trip", func(t *testing.T) {
+		store := newStore(t)
+		created, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Bistro", Location: "Paris", Timezone: "Europe/Paris", OpeningHours: bistro.OpeningHours})
+		require.NoError(t, err)
+		fetched, err := store.GetRestaurant(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Europe/Paris", fetched.Timezone)
+		assert.Equal(t, bistro.OpeningHours, fetched.OpeningHours)
+		assert.Empty(t, fetched.SpecialAvailability)
+
+		updated, err := store.UpdateRestaurant(ctx, created.ID, RestaurantUpdate{SpecialAvailability: &bistro.SpecialAvailability})
+		require.NoError(t, err)
+		assert.Equal(t, bistro.SpecialAvailability, updated.SpecialAvailability)
+		assert.Equal(t, bistro.OpeningHours, updated.OpeningHours, "partial update must keep the schedule")
+
+		updated, err = store.UpdateRestaurant(ctx, created.ID, RestaurantUpdate{OpeningHours: &OpeningHours{}})
+		require.NoError(t, err)
+		assert.Empty(t, updated.OpeningHours, "empty hours clear the schedule")
+	})
+
+	t.Run("table numbers are unique per restaurant", func(t *testing.T) {
+		store := newStore(t)
+		first, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "A", Location: "X"})
+		second, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "B", Location: "X"})
+
+		_, err := store.CreateTable(ctx, TableCreate{RestaurantID: first.ID, Number: 1, MinCapacity: 1, MaxCapacity: 2, Status: "available"})
+		require.NoError(t, err)
+		_, err = store.CreateTable(ctx, TableCreate{RestaurantID: first.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: "available"})
+		assert.ErrorIs(t, err, ErrConflict)
+		_, err = store.CreateTable(ctx, TableCreate{RestaurantID: second.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: "available"})
+		assert.NoError(t, err)
+
+		other, err := store.CreateTable(ctx, TableCreate{RestaurantID: first.ID, Number: 2, MinCapacity: 1, MaxCapacity: 4, Status: "available"})
+		require.NoError(t, err)
+		_, err = store.UpdateTable(ctx, first.ID, other.ID, TableUpdate{Number: 1})
+		assert.ErrorIs(t, err, ErrConflict)
+		updated, err := store.UpdateTable(ctx, first.ID, other.ID, TableUpdate{Status: "occupied"})
+		require.NoError(t, err)
+		assert.Equal(t, "occupied", updated.Status)
+		assert.Equal(t, 4, updated.MaxCapacity)
+	})
+
+	t.Run("child rows require an existing restaurant", func(t *testing.T) {
+		store := newStore(t)
+		_, err := store.CreateTable(ctx, TableCreate{RestaurantID: "missing", Number: 1, MinCapacity: 1, MaxCapacity: 2, Status: "available"})
+		assert.ErrorIs(t, err, ErrConflict)
+		_, err = store.CreateWaitlistEntry(ctx, WaitlistEntryCreate{RestaurantID: "missing", Name: "Ann", PartySize: 2})
+		assert.ErrorIs(t, err, ErrConflict)
+		_, err = store.CreateReservation(ctx, ReservationCreate{RestaurantID: "missing", Date: "2025-05-01", Time: "19:00", Guests: 2})
+		assert.ErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("deleting a restaurant cascades", func(t *testing.T) {
+		store := newStore(t)
+		restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "A", Location: "X", OwnerID: "owner-1"})
+		_, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 2, Status: "available"})
+		require.NoError(t, err)
+		_, err = store.CreateWaitlistEntry(ctx, WaitlistEntryCreate{RestaurantID: restaurant.ID, Name: "Ann", PartySize: 2})
+		require.NoError(t, err)
+		_, err = store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, Date: "2025-05-01", Time: "19:00", Guests: 2, Status: "pending"})
+		require.NoError(t, err)
+
+		require.NoError(t, store.DeleteRestaurant(ctx, restaurant.ID))
+		tables, _ := store.ListTables(ctx, restaurant.ID)
+		entries, _ := store.ListWaitlist(ctx, restaurant.ID)
+		reservations, _ := store.ListReservations(ctx, restaurant.ID, ReservationFilter{})
+		members, _ := store.ListMembers(ctx, restaurant.ID)
+		assert.Empty(t, members)
+		assert.Empty(t, tables)
+		assert.Empty(t, entries)
+		assert.Empty(t, reservations)
+	})
+
+	t.Run("waitlist offers resolve once", func(t *testing.T) {
+		store := newStore(t)
+		restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "A", Location: "X"})
+		entry, err := store.CreateWaitlistEntry(ctx, WaitlistEntryCreate{RestaurantID: restaurant.ID, UserID: "user-1", Name: "Ann", PartySize: 2})
+		require.NoError(t, err)
+		held, err := store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, UserID: "user-1", Date: "2025-05-01", Time: "19:00", Guests: 2, Status: statusPending})
+		require.NoError(t, err)
+		_, err = store.CreateWaitlistOffer(ctx, WaitlistOfferCreate{RestaurantID: restaurant.ID, EntryID: "missing", ReservationID: held.ID})
+		assert.ErrorIs(t, err, ErrConflict)
+
+		expires := time.Date(2025, 5, 1, 19, 10, 0, 0, time.UTC)
+		offer, err := store.CreateWaitlistOffer(ctx, WaitlistOfferCreate{RestaurantID: restaurant.ID, EntryID: entry.ID, UserID: "user-1", ReservationID: held.ID, ExpiresAt: expires})
+		require.NoError(t, err)
+		assert.Equal(t, offerPending, offer.Status)
+		fetched, err := store.GetWaitlistOffer(ctx, restaurant.ID, offer.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", fetched.UserID)
+		assert.True(t, expires.Equal(fetched.ExpiresAt))
+
+		declined, err := store.ResolveWaitlistOffer(ctx, restaurant.ID, offer.ID, offerDeclined)
+		require.NoError(t, err)
+		assert.Equal(t, offerDeclined, declined.Status)
+		_, err = store.ResolveWaitlistOffer(ctx, restaurant.ID, offer.ID, offerAccepted)
+		assert.ErrorIs(t, err, ErrConflict, "only pending offers move")
+		_, err = store.ResolveWaitlistOffer(ctx, restaurant.ID, "missing", offerAccepted)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		entries, _ := store.ListWaitlist(ctx, restaurant.ID)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "user-1", entries[0].UserID)
+		require.NoError(t, store.DeleteWaitlistEntry(ctx, restaurant.ID, entry.ID))
+		offers, err := store.ListWaitlistOffers(ctx, restaurant.ID)
+		require.NoError(t, err)
+		assert.Empty(t, offers, "offers go with their entry")
+	})
+
+	t.Run("waitlist entries move by status and seat in one write", func(t *testing.T) {
+		store := newStore(t)
+		restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "A", Location: "X"})
+		table, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: tableAvailable})
+		require.NoError(t, err)
+		ann, _ := store.CreateWaitlistEntry(ctx, WaitlistEntryCreate{RestaurantID: restaurant.ID, Name: "Ann", PartySize: 2})
+		bob, _ := store.CreateWaitlistEntry(ctx, WaitlistEntryCreate{RestaurantID: restaurant.ID, Name: "Bob", PartySize: 2})
+		assert.Equal(t, waitlistWaiting, ann.Status)
+
+		notified, err := store.TransitionWaitlistEntry(ctx, WaitlistTransition{RestaurantID: restaurant.ID, EntryID: ann.ID, From: waitlistWaiting, To: waitlistNotified})
+		require.NoError(t, err)
+		assert.Equal(t, waitlistNotified, notified.Status)
+		_, err = store.TransitionWaitlistEntry(ctx, WaitlistTransition{RestaurantID: restaurant.ID, EntryID: ann.ID, From: waitlistWaiting, To: waitlistCancelled})
+		assert.ErrorIs(t, err, ErrConflict, "entries move from the status they are in")
+		_, err = store.TransitionWaitlistEntry(ctx, WaitlistTransition{RestaurantID: restaurant.ID, EntryID: "missing", From: waitlistWaiting, To: waitlistCancelled})
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		booking := ReservationCreate{
+			RestaurantID:    restaurant.ID,
+			TableID:         table.ID,
+			Date:            "2025-05-01",
+			Time:            "19:00",
+			StartsAt:        time.Date(2025, 5, 1, 19, 0, 0, 0, time.UTC),
+			Guests:          2,
+			Status:          statusSeated,
+			DurationMinutes: 90,
+		}
+		seated, reservation, err := store.SeatWaitlistEntry(ctx, WaitlistTransition{RestaurantID: restaurant.ID, EntryID: ann.ID, From: waitlistNotified, To: waitlistSeated, TableID: table.ID}, booking)
+		require.NoError(t, err)
+		assert.Equal(t, waitlistSeated, seated.Status)
+		assert.Equal(t, table.ID, seated.TableID)
+		assert.Equal(t, reservation.ID, seated.ReservationID)
+		assert.Equal(t, statusSeated, reservation.Status)
+		occupied, err := store.GetTable(ctx, restaurant.ID, table.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tableOccupied, occupied.Status, "the same write occupies the table")
+
+		_, _, err = store.SeatWaitlistEntry(ctx, WaitlistTransition{RestaurantID: restaurant.ID, EntryID: bob.ID, From: waitlistWaiting, To: waitlistSeated, TableID: table.ID}, booking)
+		assert.ErrorIs(t, err, ErrConflict, "the table is taken")
+		fetched, err := store.GetWaitlistEntry(ctx, restaurant.ID, bob.ID)
+		require.NoError(t, err)
+		assert.Equal(t, waitlistWaiting, fetched.Status)
+		booking.Time, booking.StartsAt = "21:00", booking.StartsAt.Add(2*time.Hour)
+		_, _, err = store.SeatWaitlistEntry(ctx, WaitlistTransition{RestaurantID: restaurant.ID, EntryID: bob.ID, From: waitlistNotified, To: waitlistSeated, TableID: table.ID}, booking)
+		assert.ErrorIs(t, err, ErrConflict, "bob is not notified")
+		reservations, err := store.ListReservations(ctx, restaurant.ID, ReservationFilter{})
+		require.NoError(t, err)
+		assert.Len(t, reservations, 1, "a failed seating books nothing")
+
+		require.NoError(t, store.DeleteTable(ctx, restaurant.ID, table.ID))
+		fetched, err = store.GetWaitlistEntry(ctx, restaurant.ID, ann.ID)
+		require.NoError(t, err)
+		assert.Empty(t, fetched.TableID)
+		assert.Equal(t, reservation.ID, fetched.ReservationID)
+	})
+
+	t.Run("owners become managers and members are unique", func(t *testing.T) {
+		store := newStore(t)
+		owned, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "A", Location: "X", OwnerID: "owner-1"})
+		require.NoError(t, err)
+		assert.Equal(t, "owner-1", owned.OwnerID)
+		unowned, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "B", Location: "X"})
+
+		owner, err := store.GetMember(ctx, owned.ID, "owner-1")
+		require.NoError(t, err)
+		assert.Equal(t, roleManager, owner.Role)
+		fetched, err := store.GetRestaurant(ctx, owned.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "owner-1", fetched.OwnerID)
+
+		_, err = store.AddMember(ctx, MemberCreate{RestaurantID: owned.ID, UserID: "staff-1", Role: roleStaff})
+		require.NoError(t, err)
+		_, err = store.AddMember(ctx, MemberCreate{RestaurantID: unowned.ID, UserID: "staff-1", Role: roleStaff})
+		require.NoError(t, err)
+		_, err = store.AddMember(ctx, MemberCreate{RestaurantID: owned.ID, UserID: "staff-1", Role: roleManager})
+		assert.ErrorIs(t, err, ErrConflict)
+		_, err = store.AddMember(ctx, MemberCreate{RestaurantID: "missing", UserID: "staff-1", Role: roleStaff})
+		assert.ErrorIs(t, err, ErrConflict)
+
+		members, err := store.ListMembers(ctx, owned.ID)
+		require.NoError(t, err)
+		assert.Len(t, members, 2)
+		memberships, err := store.ListMemberships(ctx, "staff-1")
+		require.NoError(t, err)
+		assert.Len(t, memberships, 2)
+
+		mine, err := store.ListRestaurants(ctx, RestaurantFilter{IDs: []string{owned.ID}})
+		require.NoError(t, err)
+		require.Len(t, mine, 1)
+		assert.Equal(t, owned.ID, mine[0].ID)
+		none, err := store.ListRestaurants(ctx, RestaurantFilter{IDs: []string{}})
+		require.NoError(t, err)
+		assert.Empty(t, none)
+
+		require.NoError(t, store.RemoveMember(ctx, owned.ID, "staff-1"))
+		assert.ErrorIs(t, store.RemoveMember(ctx, owned.ID, "staff-1"), ErrNotFound)
+		_, err = store.GetMember(ctx, owned.ID, "staff-1")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("profiles have unique users and usernames", func(t *testing.T) {
+		store := newStore(t)
+		profile := Profile{UserID: "user-1", Username: "ann", Email: "ann@example.com", PhoneNumber: "+14155550123", FirstName: "Ann", LastName: "Lee"}
+		created, err := store.CreateProfile(ctx, profile)
+		require.NoError(t, err)
+		assert.NotEmpty(t, created.CreatedAt)
+
+		byID, err := store.GetProfile(ctx, "user-1")
+		require.NoError(t, err)
+		assert.Equal(t, "ann", byID.Username)
+		byName, err := store.GetProfileByUsername(ctx, "ann")
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", byName.UserID)
+		_, err = store.GetProfile(ctx, "user-2")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		taken := profile
+		taken.UserID = "user-2"
+		_, err = store.CreateProfile(ctx, taken)
+		assert.ErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("reservations filter by date and update in place", func(t *testing.T) {
+		store := newStore(t)
+		restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "A", Location: "X"})
+		first, err := store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, Date: "2025-05-01", Time: "19:00", Guests: 2, Status: "pending"})
+		require.NoError(t, err)
+		_, err = store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, Date: "2025-05-02", Time: "19:00", Guests: 4, Status: "pending"})
+		require.NoError(t, err)
+
+		onFirst, err := store.ListReservations(ctx, restaurant.ID, ReservationFilter{Date: "2025-05-01"})
+		require.NoError(t, err)
+		require.Len(t, onFirst, 1)
+		assert.Equal(t, first.ID, onFirst[0].ID)
+
+		cancelled, err := store.UpdateReservation(ctx, restaurant.ID, first.ID, ReservationUpdate{Status: "cancelled"})
+		require.NoError(t, err)
+		assert.Equal(t, "cancelled", cancelled.Status)
+		assert.Equal(t, 2, cancelled.Guests)
+		_, err = store.UpdateReservation(ctx, restaurant.ID, "missing", ReservationUpdate{Status: "cancelled"})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("reservations keep their local time across time zone changes", func(t *testing.T) {
+		store := newStore(t)
+		restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "A", Location: "X", Timezone: "America/New_York"})
+		startsAt := time.Date(2025, 5, 1, 23, 0, 0, 0, time.UTC)
+		created, err := store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, Date: "2025-05-01", Time: "19:00", StartsAt: startsAt, Guests: 2, Status: "pending"})
+		require.NoError(t, err)
+		assert.True(t, startsAt.Equal(created.StartsAt))
+
+		moved := startsAt.Add(time.Hour)
+		updated, err := store.UpdateReservation(ctx, restaurant.ID, created.ID, ReservationUpdate{Time: "20:00", StartsAt: &moved})
+		require.NoError(t, err)
+		assert.True(t, moved.Equal(updated.StartsAt))
+
+		_, err = store.UpdateRestaurant(ctx, restaurant.ID, RestaurantUpdate{Timezone: "Europe/Paris"})
+		require.NoError(t, err)
+		fetched, err := store.GetReservation(ctx, restaurant.ID, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "20:00", fetched.Time)
+		assert.True(t, time.Date(2025, 5, 1, 18, 0, 0, 0, time.UTC).Equal(fetched.StartsAt), "20:00 in Paris, got %s", fetched.StartsAt)
+		assert.Equal(t, time.UTC, fetched.StartsAt.Location(), "instants are stored in UTC")
+	})
+
+	t.Run("status transitions compare and record events", func(t *testing.T) {
+		store := newStore(t)
+		restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "A", Location: "X"})
+		created, err := store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, Date: "2025-05-01", Time: "19:00", Guests: 2, Status: "pending"})
+		require.NoError(t, err)
+		move := func(from, to string) (*Reservation, error) {
+			return store.TransitionReservation(ctx, ReservationTransition{RestaurantID: restaurant.ID, ReservationID: created.ID, From: from, To: to, ActorID: "staff-1"})
+		}
+
+		confirmed, err := move("pending", "confirmed")
+		require.NoError(t, err)
+		assert.Equal(t, "confirmed", confirmed.Status)
+		assert.Equal(t, 2, confirmed.Guests)
+		_, err = move("pending", "cancelled")
+		assert.ErrorIs(t, err, ErrConflict, "the reservation is no longer pending")
+		_, err = move("confirmed", "seated")
+		require.NoError(t, err)
+		_, err = store.TransitionReservation(ctx, ReservationTransition{RestaurantID: restaurant.ID, ReservationID: "missing", From: "pending", To: "confirmed"})
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		events, err := store.ListReservationEvents(ctx, restaurant.ID, created.ID)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, []string{"pending", "confirmed"}, []string{events[0].FromStatus, events[1].FromStatus})
+		assert.Equal(t, []string{"confirmed", "seated"}, []string{events[0].ToStatus, events[1].ToStatus})
+		assert.Equal(t, "staff-1", events[1].ActorID)
+	})
+
+	t.Run("notifications are logged against a reservation", func(t *testing.T) {
+		store := newStore(t)
+		restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "A", Location: "X"})
+		created, err := store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, Date: "2025-05-01", Time: "19:00", Guests: 2, Status: "confirmed", PhoneNumber: "+15550100"})
+		require.NoError(t, err)
+		loaded, err := store.GetReservation(ctx, restaurant.ID, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "+15550100", loaded.PhoneNumber)
+
+		sent, err := store.RecordNotification(ctx, Notification{RestaurantID: restaurant.ID, ReservationID: created.ID, Kind: "confirmation", Channel: "sms", Recipient: "+15550100", Status: "sent"})
+		require.NoError(t, err)
+		assert.NotEmpty(t, sent.ID)
+		_, err = store.RecordNotification(ctx, Notification{RestaurantID: restaurant.ID, ReservationID: created.ID, Kind: "confirmation", Channel: "email", Status: "failed", Error: "bounced"})
+		require.NoError(t, err)
+		_, err = store.RecordNotification(ctx, Notification{RestaurantID: restaurant.ID, ReservationID: "missing", Kind: "confirmation", Channel: "sms", Status: "sent"})
+		assert.Error(t, err)
+
+		logged, err := store.ListNotifications(ctx, restaurant.ID)
+		require.NoError(t, err)
+		require.Len(t, logged, 2)
+		assert.Equal(t, "+15550100", logged[0].Recipient)
+		assert.Equal(t, "bounced", logged[1].Error)
+	})
+
+	t.Run("jobs are claimed once until released", func(t *testing.T) {
+		store := newStore(t)
+		restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "A", Location: "X"})
+
+		require.NoError(t, store.ClaimJob(ctx, restaurant.ID, "reminder:r1:120"))
+		assert.ErrorIs(t, store.ClaimJob(ctx, restaurant.ID, "reminder:r1:120"), ErrConflict)
+		assert.NoError(t, store.ClaimJob(ctx, restaurant.ID, "reminder:r1:1440"))
+		assert.ErrorIs(t, store.ClaimJob(ctx, "missing", "reminder:r2:120"), ErrConflict)
+
+		// A released claim can be made again
+		require.NoError(t, store.ReleaseJob(ctx, restaurant.ID, "reminder:r1:120"))
+		assert.NoError(t, store.ClaimJob(ctx, restaurant.ID, "reminder:r1:120"))
+	})
+
+	t.Run("reservations keep their table until it is deleted", func(t *testing.T) {
+		store := newStore(t)
+		restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "A", Location: "X"})
+		small, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 2, Status: "available"})
+		require.NoError(t, err)
+		large, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 2, MinCapacity: 1, MaxCapacity: 6, Status: "available"})
+		require.NoError(t, err)
+		created, err := store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, TableID: small.ID, Date: "2025-05-01", Time: "19:00", Guests: 2, Status: "pending"})
+		require.NoError(t, err)
+		assert.Equal(t, small.ID, created.TableID)
+
+		moved, err := store.UpdateReservation(ctx, restaurant.ID, created.ID, ReservationUpdate{TableID: large.ID, Guests: 5})
+		require.NoError(t, err)
+		assert.Equal(t, large.ID, moved.TableID)
+
+		require.NoError(t, store.DeleteTable(ctx, restaurant.ID, large.ID))
+		unassigned, err := store.GetReservation(ctx, restaurant.ID, created.ID)
+		require.NoError(t, err)
+		assert.Empty(t, unassigned.TableID)
+	})
+
+	t.Run("reservations cannot overlap on a table", func(t *testing.T) {
+		store := newStore(t)
+		restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "A", Location: "X"})
+		table, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: "available"})
+		require.NoError(t, err)
+		book := func(date, clock, status string) (*Reservation, error) {
+			return store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, TableID: table.ID, Date: date, Time: clock, Guests: 2, Status: status, DurationMinutes: 90})
+		}
+
+		first, err := book("2025-05-01", "19:00", "confirmed")
+		require.NoError(t, err)
+		_, err = book("2025-05-01", "20:00", "pending")
+		assert.ErrorIs(t, err, ErrConflict)
+		_, err = book("2025-05-01", "17:31", "pending")
+		assert.ErrorIs(t, err, ErrConflict)
+		_, err = book("2025-05-01", "19:00", "cancelled")
+		assert.NoError(t, err)
+		later, err := book("2025-05-01", "20:30", "pending")
+		require.NoError(t, err)
+		_, err = book("2025-04-30", "23:00", "pending")
+		require.NoError(t, err)
+		_, err = book("2025-05-01", "00:00", "pending")
+		assert.ErrorIs(t, err, ErrConflict)
+
+		_, err = store.UpdateReservation(ctx, restaurant.ID, later.ID, ReservationUpdate{Time: "19:30"})
+		assert.ErrorIs(t, err, ErrConflict)
+		unchanged, err := store.GetReservation(ctx, restaurant.ID, later.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "20:30", unchanged.Time)
+		_, err = store.UpdateReservation(ctx, restaurant.ID, first.ID, ReservationUpdate{Time: "18:30"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("table groups hold all their tables", func(t *testing.T) {
+		store := newStore(t)
+		restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "A", Location: "X"})
+		other, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "B", Location: "X"})
+		var tables []*Table
+		for number := 1; number <= 3; number++ {
+			table, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: number, MinCapacity: 1, MaxCapacity: 4, Status: "available"})
+			require.NoError(t, err)
+			tables = append(tables, table)
+		}
+		foreign, err := store.CreateTable(ctx, TableCreate{RestaurantID: other.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: "available"})
+		require.NoError(t, err)
+
+		group, err := store.CreateTableGroup(ctx, TableGroupCreate{RestaurantID: restaurant.ID, Name: "Window", TableIDs: []string{tables[1].ID, tables[0].ID}, MinCapacity: 5, MaxCapacity: 8})
+		require.NoError(t, err)
+		_, err = store.CreateTableGroup(ctx, TableGroupCreate{RestaurantID: restaurant.ID, TableIDs: []string{tables[2].ID, foreign.ID}, MinCapacity: 5, MaxCapacity: 8})
+		assert.ErrorIs(t, err, ErrConflict)
+		groups, err := store.ListTableGroups(ctx, restaurant.ID)
+		require.NoError(t, err)
+		require.Len(t, groups, 1)
+		assert.Equal(t, []string{tables[1].ID, tables[0].ID}, groups[0].TableIDs, "member order is kept")
+
+		onGroup, err := store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, TableID: tables[1].ID, GroupID: group.ID, Date: "2025-05-01", Time: "19:00", Guests: 6, Status: "confirmed", DurationMinutes: 90})
+		require.NoError(t, err)
+		assert.Equal(t, group.ID, onGroup.GroupID)
+		_, err = store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, TableID: tables[0].ID, Date: "2025-05-01", Time: "20:00", Guests: 2, Status: "pending", DurationMinutes: 90})
+		assert.ErrorIs(t, err, ErrConflict, "a member table is held by the group booking")
+		single, err := store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, TableID: tables[2].ID, Date: "2025-05-01", Time: "19:00", Guests: 2, Status: "pending", DurationMinutes: 90})
+		require.NoError(t, err)
+
+		leave := ""
+		moved, err := store.UpdateReservation(ctx, restaurant.ID, onGroup.ID, ReservationUpdate{GroupID: &leave})
+		require.NoError(t, err)
+		assert.Empty(t, moved.GroupID)
+		_, err = store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, TableID: tables[0].ID, Date: "2025-05-01", Time: "20:00", Guests: 2, Status: "pending", DurationMinutes: 90})
+		assert.NoError(t, err, "off the group only the lead table stays held")
+		_, err = store.UpdateReservation(ctx, restaurant.ID, single.ID, ReservationUpdate{GroupID: &group.ID, TableID: tables[1].ID})
+		assert.ErrorIs(t, err, ErrConflict)
+
+		require.NoError(t, store.DeleteTable(ctx, restaurant.ID, tables[0].ID))
+		groups, err = store.ListTableGroups(ctx, restaurant.ID)
+		require.NoError(t, err)
+		assert.Empty(t, groups, "deleting a member table deletes the group")
+		assert.ErrorIs(t, store.DeleteTableGroup(ctx, restaurant.ID, group.ID), ErrNotFound)
+	})
+
+	t.Run("concurrent bookings of a table admit one", func(t *testing.T) {
+		store := newStore(t)
+		restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "A", Location: "X"})
+		table, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: "available"})
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		var booked atomic.Int32
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, TableID: table.ID, Date: "2025-05-01", Time: "19:00", Guests: 2, Status: "pending", DurationMinutes: 90})
+				if err == nil {
+					booked.Add(1)
+				} else {
+					assert.ErrorIs(t, err, ErrConflict)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), booked.Load())
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStoreContract(t, func(t *testing.T) Store { return newMemoryStore() })
+}
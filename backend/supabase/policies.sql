@@ -0,0 +1,123 @@
+-- Row Level Security policies for the Supabase project. They mirror the checks in
+-- policy.go so that SUPABASE_AUTH_MODE=forward enforces the same rules in the
+-- database. Apply with the SQL editor or `psql -f policies.sql`; the script can be
+-- re-run after changes.
+--
+-- Scripts run in this order:
+--   1. schema.sql, which creates restaurant_members, profiles and
+--      restaurants.owner_id used below;
+--   2. policies.sql;
+--   3. reservations.sql, whose policies call is_restaurant_member.
+
+-- is_restaurant_member reports whether the caller holds one of roles in rid.
+-- Admins (app_metadata.role = 'admin') pass for every restaurant. SECURITY DEFINER
+-- lets the check read restaurant_members without recursing into its own policies.
+create or replace function is_restaurant_member(rid text, roles text[])
+returns boolean
+language sql
+stable
+security definer
+set search_path = public
+as $$
+  select coalesce(auth.jwt() -> 'app_metadata' ->> 'role', '') = 'admin'
+      or exists (
+        select 1 from restaurant_members m
+        where m.restaurant_id::text = rid
+          and m.user_id::text = auth.uid()::text
+          and m.role = any (roles)
+      );
+$$;
+
+alter table restaurants enable row level security;
+alter table restaurant_members enable row level security;
+alter table tables enable row level security;
+alter table waitlist enable row level security;
+alter table reservations enable row level security;
+alter table profiles enable row level security;
+
+-- Restaurants: public reads, managers and admins create their own, members manage.
+drop policy if exists restaurants_select on restaurants;
+create policy restaurants_select on restaurants for select using (true);
+drop policy if exists restaurants_insert on restaurants;
+create policy restaurants_insert on restaurants for insert to authenticated
+  with check (
+    owner_id::text = auth.uid()::text
+    and coalesce(auth.jwt() -> 'app_metadata' ->> 'role', '') in ('manager', 'admin')
+  );
+drop policy if exists restaurants_update on restaurants;
+create policy restaurants_update on restaurants for update to authenticated
+  using (is_restaurant_member(id::text, array['manager']));
+drop policy if exists restaurants_delete on restaurants;
+create policy restaurants_delete on restaurants for delete to authenticated
+  using (is_restaurant_member(id::text, array['manager']));
+
+-- Members: users see their own memberships, staff see their colleagues and
+-- managers invite or remove. The owner may add themselves right after creating.
+drop policy if exists members_select on restaurant_members;
+create policy members_select on restaurant_members for select to authenticated
+  using (user_id::text = auth.uid()::text or is_restaurant_member(restaurant_id::text, array['staff', 'manager']));
+drop policy if exists members_insert on restaurant_members;
+create policy members_insert on restaurant_members for insert to authenticated
+  with check (
+    is_restaurant_member(restaurant_id::text, array['manager'])
+    or exists (
+      select 1 from restaurants r
+      where r.id::text = restaurant_id::text and r.owner_id::text = auth.uid()::text
+    )
+  );
+drop policy if exists members_delete on restaurant_members;
+create policy members_delete on restaurant_members for delete to authenticated
+  using (is_restaurant_member(restaurant_id::text, array['manager']));
+
+-- Tables: public reads, staff and managers write.
+drop policy if exists tables_select on tables;
+create policy tables_select on tables for select using (true);
+drop policy if exists tables_write on tables;
+create policy tables_write on tables for all to authenticated
+  using (is_restaurant_member(restaurant_id::text, array['staff', 'manager']))
+  with check (is_restaurant_member(restaurant_id::text, array['staff', 'manager']));
+
+-- Waitlist: any signed-in user may join, staff read and manage the queue.
+drop policy if exists waitlist_insert on waitlist;
+create policy waitlist_insert on waitlist for insert to authenticated with check (true);
+drop policy if exists waitlist_staff on waitlist;
+create policy waitlist_staff on waitlist for all to authenticated
+  using (is_restaurant_member(restaurant_id::text, array['staff', 'manager']))
+  with check (is_restaurant_member(restaurant_id::text, array['staff', 'manager']));
+
+-- Reservations: customers see and change their own, staff see the restaurant's.
+drop policy if exists reservations_own on reservations;
+create policy reservations_own on reservations for all to authenticated
+  using (user_id::text = auth.uid()::text)
+  with check (user_id::text = auth.uid()::text);
+drop policy if exists reservations_staff on reservations;
+create policy reservations_staff on reservations for all to authenticated
+  using (is_restaurant_member(restaurant_id::text, array['staff', 'manager']))
+  with check (is_restaurant_member(restaurant_id::text, array['staff', 'manager']));
+
+-- is_new_signup reports whether uid is an auth user with email who signed up in
+-- the last hour. Registration has no session yet, so without the service role
+-- key it writes the profile as anon; this keeps that to the account just made.
+create or replace function is_new_signup(uid text, address text)
+returns boolean
+language sql
+stable
+security definer
+set search_path = public
+as $$
+  select exists (
+    select 1 from auth.users u
+    where u.id::text = uid
+      and lower(u.email) = lower(address)
+      and u.created_at > now() - interval '1 hour'
+  );
+$$;
+
+-- Profiles: users read their own. Registration writes them with the service
+-- role, or as anon for a user who has just signed up.
+drop policy if exists profiles_select on profiles;
+create policy profiles_select on profiles for select to authenticated
+  using (user_id::text = auth.uid()::text);
+drop policy if exists profiles_insert on profiles;
+create policy profiles_insert on profiles for insert to anon, authenticated
+  with check (is_new_signup(user_id::text, email));
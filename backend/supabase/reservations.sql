@@ -0,0 +1,337 @@
+-- Restaurant schedules, reservation and waitlist columns, table groups,
+-- waitlist offers and seating, and the double-booking guard for the Supabase project. The SQL stores get the same
+-- schema from migrations.go and run the same check in Go; PostgREST has no
+-- transactions, so for Supabase the check lives in the database. Apply with the
+-- SQL editor or `psql -f reservations.sql` after schema.sql and policies.sql;
+-- the script can be re-run after changes.
+
+-- Opening hours are a JSON weekly schedule plus date-specific exceptions. The
+-- old free-text hours are kept as opening_hours_note.
+do $$
+begin
+  if exists (select 1 from information_schema.columns
+             where table_name = 'restaurants' and column_name = 'opening_hours' and data_type = 'text') then
+    alter table restaurants rename column opening_hours to opening_hours_note;
+  end if;
+end $$;
+alter table restaurants add column if not exists opening_hours jsonb;
+alter table restaurants add column if not exists special_availability jsonb;
+alter table restaurants add column if not exists timezone text not null default '';
+
+alter table reservations add column if not exists table_id text references tables(id) on delete set null;
+alter table reservations add column if not exists duration_minutes integer not null default 0;
+alter table reservations add column if not exists phone_number text;
+create index if not exists reservations_table_date on reservations (table_id, date);
+
+-- starts_at is the instant a reservation's date and time name on the
+-- restaurant's clock. Rows booked before it existed are anchored here.
+alter table reservations add column if not exists starts_at timestamptz;
+update reservations r
+set starts_at = (r.date::text || ' ' || r.time::text)::timestamp
+                at time zone coalesce(nullif(s.timezone, ''), 'UTC')
+from restaurants s
+where s.id = r.restaurant_id and r.starts_at is null;
+
+-- Changing a restaurant's time zone re-anchors its reservations so they keep
+-- their date and time on its clock. Every seating moves by the same offset, so
+-- the overlap guard below does not watch starts_at.
+create or replace function restaurants_rezone_reservations()
+returns trigger
+language plpgsql
+as $$
+begin
+  update reservations
+  set starts_at = (date::text || ' ' || time::text)::timestamp
+                  at time zone coalesce(nullif(new.timezone, ''), 'UTC')
+  where restaurant_id = new.id;
+  return new;
+end;
+$$;
+
+drop trigger if exists restaurants_rezone_reservations on restaurants;
+create trigger restaurants_rezone_reservations
+  after update of timezone on restaurants
+  for each row when (old.timezone is distinct from new.timezone)
+  execute function restaurants_rezone_reservations();
+
+-- The backend works out waitlist places and waits on every read.
+alter table waitlist drop column if exists party_ahead;
+alter table waitlist drop column if exists estimated_wait_time;
+alter table waitlist add column if not exists user_id text;
+
+-- Entries stay on the waitlist once the party leaves the queue: seated,
+-- cancelled or no_show. A seated entry records its table and reservation.
+alter table waitlist add column if not exists status text not null default 'waiting';
+alter table waitlist add column if not exists table_id text references tables(id) on delete set null;
+alter table waitlist add column if not exists reservation_id text references reservations(id) on delete set null;
+
+-- Accepting an offer marks the entry seated on the server's behalf, so guests
+-- need no write access to their entry.
+drop policy if exists waitlist_own on waitlist;
+
+-- A table group combines tables to seat one larger party. The first table leads
+-- the group and is recorded as the table of reservations made on it.
+create table if not exists table_groups (
+  id            text primary key default gen_random_uuid()::text,
+  restaurant_id text not null references restaurants(id) on delete cascade,
+  name          text not null default '',
+  table_ids     text[] not null,
+  min_capacity  integer not null,
+  max_capacity  integer not null
+);
+create index if not exists table_groups_restaurant on table_groups (restaurant_id);
+alter table reservations add column if not exists group_id text references table_groups(id) on delete set null;
+
+-- Like tables, groups are public to read and written by staff and managers.
+-- is_restaurant_member comes from policies.sql.
+alter table table_groups enable row level security;
+drop policy if exists table_groups_select on table_groups;
+create policy table_groups_select on table_groups for select using (true);
+drop policy if exists table_groups_write on table_groups;
+create policy table_groups_write on table_groups for all to authenticated
+  using (is_restaurant_member(restaurant_id::text, array['staff', 'manager']))
+  with check (is_restaurant_member(restaurant_id::text, array['staff', 'manager']));
+
+-- Deleting a table deletes the groups it belongs to.
+create or replace function tables_drop_groups()
+returns trigger
+language plpgsql
+as $$
+begin
+  delete from table_groups where old.id = any(table_ids);
+  return old;
+end;
+$$;
+
+drop trigger if exists tables_drop_groups on tables;
+create trigger tables_drop_groups
+  before delete on tables
+  for each row execute function tables_drop_groups();
+
+-- reservation_tables returns the tables a reservation holds: every table of its
+-- group, else its own table.
+create or replace function reservation_tables(p_table_id text, p_group_id text)
+returns text[]
+language sql
+stable
+as $$
+  select coalesce(
+    (select g.table_ids from table_groups g where g.id = p_group_id),
+    case when p_table_id is null then '{}'::text[] else array[p_table_id] end
+  );
+$$;
+
+-- reservations_prevent_overlap rejects a reservation one of whose tables is
+-- already held for an overlapping seating, comparing instants so seatings in
+-- different UTC offsets line up. Locking the tables' rows first, in id
+-- order, queues concurrent bookings of the same tables, so the check and the
+-- write are atomic. SECURITY DEFINER lets a customer's booking see and lock
+-- what their policies hide: other guests' reservations and the tables only staff
+-- may write. The error code is exclusion_violation, which PostgREST answers
+-- with 409.
+create or replace function reservations_prevent_overlap()
+returns trigger
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+  held      text[];
+  new_start timestamptz;
+  new_end   timestamptz;
+  clash     record;
+begin
+  if new.status in ('cancelled', 'completed', 'no_show') then
+    return new;
+  end if;
+  held := reservation_tables(new.table_id, new.group_id);
+  if cardinality(held) = 0 then
+    return new;
+  end if;
+
+  perform 1 from tables where id = any(held) order by id for update;
+
+  new_start := coalesce(new.starts_at, (new.date::text || ' ' || new.time::text)::timestamp at time zone 'UTC');
+  new_end := new_start + make_interval(mins => new.duration_minutes);
+
+  select r.table_id, r.date, r.time into clash
+  from reservations r
+  where r.restaurant_id = new.restaurant_id
+    and r.id <> new.id
+    and r.status not in ('cancelled', 'completed', 'no_show')
+    and reservation_tables(r.table_id, r.group_id) && held
+    and r.starts_at < new_end
+    and r.starts_at
+        + make_interval(mins => coalesce(nullif(r.duration_minutes, 0), new.duration_minutes)) > new_start
+  limit 1;
+
+  if found then
+    raise exception 'table % is booked at % %', clash.table_id, clash.date, clash.time
+      using errcode = 'exclusion_violation';
+  end if;
+  return new;
+end;
+$$;
+
+drop trigger if exists reservations_prevent_overlap on reservations;
+create trigger reservations_prevent_overlap
+  before insert or update of table_id, group_id, date, time, duration_minutes, status on reservations
+  for each row execute function reservations_prevent_overlap();
+
+-- A table that comes free is offered to a waitlisted party and held by a
+-- pending reservation until expires_at.
+create table if not exists waitlist_offers (
+  id             text primary key default gen_random_uuid()::text,
+  restaurant_id  text not null references restaurants(id) on delete cascade,
+  entry_id       text not null references waitlist(id) on delete cascade,
+  user_id        text,
+  reservation_id text not null references reservations(id) on delete cascade,
+  status         text not null default 'pending',
+  expires_at     timestamptz not null,
+  created_at     timestamptz not null default now()
+);
+create index if not exists waitlist_offers_restaurant on waitlist_offers (restaurant_id, created_at);
+create index if not exists waitlist_offers_reservation on waitlist_offers (reservation_id);
+
+-- Staff manage offers; the waitlisted guest reads and answers their own.
+alter table waitlist_offers enable row level security;
+drop policy if exists waitlist_offers_own on waitlist_offers;
+create policy waitlist_offers_own on waitlist_offers for select to authenticated
+  using (user_id::text = auth.uid()::text);
+drop policy if exists waitlist_offers_answer on waitlist_offers;
+create policy waitlist_offers_answer on waitlist_offers for update to authenticated
+  using (user_id::text = auth.uid()::text and status = 'pending')
+  with check (user_id::text = auth.uid()::text);
+drop policy if exists waitlist_offers_staff on waitlist_offers;
+create policy waitlist_offers_staff on waitlist_offers for all to authenticated
+  using (is_restaurant_member(restaurant_id::text, array['staff', 'manager']))
+  with check (is_restaurant_member(restaurant_id::text, array['staff', 'manager']));
+
+-- Status changes are recorded in reservation_events with who made them.
+create table if not exists reservation_events (
+  id             text primary key default gen_random_uuid()::text,
+  reservation_id text not null references reservations(id) on delete cascade,
+  restaurant_id  text not null references restaurants(id) on delete cascade,
+  from_status    text not null,
+  to_status      text not null,
+  actor_id       text not null default '',
+  created_at     timestamptz not null default now()
+);
+create index if not exists reservation_events_reservation on reservation_events (reservation_id, created_at);
+
+-- Events are visible and written wherever their reservation is, so the
+-- reservation policies in policies.sql decide.
+alter table reservation_events enable row level security;
+drop policy if exists reservation_events_access on reservation_events;
+create policy reservation_events_access on reservation_events for all to authenticated
+  using (exists (select 1 from reservations r where r.id = reservation_id))
+  with check (exists (select 1 from reservations r where r.id = reservation_id));
+
+-- transition_reservation moves a reservation from p_from to p_to and records the
+-- event in one transaction. It returns no row for a missing reservation and
+-- fails with HTTP 409 (PT409) when the status is no longer p_from. The backend
+-- checks which moves are allowed.
+create or replace function transition_reservation(p_restaurant_id text, p_reservation_id text, p_from text, p_to text, p_actor_id text)
+returns setof reservations
+language plpgsql
+as $$
+declare
+  current_status text;
+begin
+  update reservations set status = p_to
+  where restaurant_id::text = p_restaurant_id
+    and id::text = p_reservation_id
+    and coalesce(status, '') = p_from;
+
+  if not found then
+    select coalesce(status, '') into current_status
+    from reservations
+    where restaurant_id::text = p_restaurant_id and id::text = p_reservation_id;
+    if found then
+      raise exception 'reservation % is %', p_reservation_id, current_status
+        using errcode = 'PT409';
+    end if;
+    return;
+  end if;
+
+  insert into reservation_events (reservation_id, restaurant_id, from_status, to_status, actor_id)
+  values (p_reservation_id, p_restaurant_id, p_from, p_to, p_actor_id);
+
+  return query
+    select * from reservations
+    where restaurant_id::text = p_restaurant_id and id::text = p_reservation_id;
+end;
+$$;
+
+-- seat_waitlist_entry books p_reservation, a seated reservation, marks the
+-- waitlist entry seated at its table and the table, or every table of its
+-- group, occupied in one transaction. The overlap guard
+-- answers a taken table with 409, as does an entry no longer in p_from (PT409);
+-- a missing entry is reported with 404 (PT404).
+create or replace function seat_waitlist_entry(p_restaurant_id text, p_entry_id text, p_from text, p_reservation jsonb)
+returns setof reservations
+language plpgsql
+as $$
+declare
+  seated         reservations;
+  current_status text;
+begin
+  insert into reservations (restaurant_id, user_id, table_id, group_id, date, time, starts_at, guests, status, duration_minutes, phone_number)
+  select restaurant_id, user_id, nullif(table_id, ''), nullif(group_id, ''), date, time, starts_at, guests, status, duration_minutes, nullif(phone_number, '')
+  from jsonb_populate_record(null::reservations, p_reservation)
+  returning * into seated;
+
+  update waitlist set status = 'seated', table_id = seated.table_id, reservation_id = seated.id
+  where restaurant_id::text = p_restaurant_id
+    and id::text = p_entry_id
+    and status = p_from;
+
+  if not found then
+    select status into current_status
+    from waitlist
+    where restaurant_id::text = p_restaurant_id and id::text = p_entry_id;
+    if found then
+      raise exception 'waitlist entry % is %', p_entry_id, current_status
+        using errcode = 'PT409';
+    end if;
+    raise exception 'waitlist entry % does not exist', p_entry_id
+      using errcode = 'PT404';
+  end if;
+
+  update tables set status = 'occupied'
+  where restaurant_id::text = p_restaurant_id
+    and id::text = any(reservation_tables(seated.table_id, seated.group_id));
+
+  return next seated;
+end;
+$$;
+
+-- Every message sent to a guest about a reservation, and whether it went out.
+-- The backend writes them with the service role; staff read their restaurant's.
+create table if not exists notifications (
+  id             text primary key default gen_random_uuid()::text,
+  restaurant_id  text not null references restaurants(id) on delete cascade,
+  reservation_id text not null references reservations(id) on delete cascade,
+  kind           text not null,
+  channel        text not null,
+  recipient      text not null default '',
+  status         text not null,
+  error          text not null default '',
+  created_at     timestamptz not null default now()
+);
+create index if not exists notifications_restaurant on notifications (restaurant_id, created_at);
+
+alter table notifications enable row level security;
+drop policy if exists notifications_staff on notifications;
+create policy notifications_staff on notifications for select to authenticated
+  using (is_restaurant_member(restaurant_id::text, array['staff', 'manager']));
+
+-- Background jobs the backend has done, such as reminders sent, keyed so a job
+-- is claimed once across restarts and server instances. Only the service role
+-- writes them, so guest notifications need SUPABASE_SERVICE_ROLE_KEY.
+create table if not exists job_claims (
+  key           text primary key,
+  restaurant_id text not null references restaurants(id) on delete cascade,
+  claimed_at    timestamptz not null default now()
+);
+alter table job_claims enable row level security;
@@ -0,0 +1,29 @@
+-- Ownership, membership and profile tables for the Supabase project. The SQL
+-- stores get the same schema from migrations.go. Apply with the SQL editor or
+-- `psql -f schema.sql` before policies.sql; the script can be re-run after
+-- changes.
+
+-- A restaurant's owner is the manager who created it. Restaurants created
+-- before owners existed have none.
+alter table restaurants add column if not exists owner_id text;
+
+-- Members are the managers and staff of a restaurant, one role per user.
+create table if not exists restaurant_members (
+  restaurant_id text not null references restaurants(id) on delete cascade,
+  user_id       text not null,
+  role          text not null check (role in ('manager', 'staff')),
+  created_at    timestamptz not null default now(),
+  unique (restaurant_id, user_id)
+);
+create index if not exists restaurant_members_user on restaurant_members (user_id);
+
+-- Profiles hold what a user gave at registration, keyed by their auth user id.
+create table if not exists profiles (
+  user_id      text primary key,
+  username     text not null unique,
+  email        text not null,
+  phone_number text not null,
+  first_name   text not null,
+  last_name    text not null,
+  created_at   timestamptz not null default now()
+);
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TableGroup is a set of tables that can be pushed together to seat one larger
+// party. The first table leads the group: reservations on the group record it as
+// their table.
+type TableGroup struct {
+	ID           string   `json:"id"`
+	RestaurantID string   `json:"restaurant_id"`
+	Name         string   `json:"name"`
+	TableIDs     []string `json:"table_ids"`
+	MinCapacity  int      `json:"min_capacity"`
+	MaxCapacity  int      `json:"max_capacity"`
+}
+
+// TableGroupCreate struct for creation requests. Capacities default from the
+// member tables when left out.
+type TableGroupCreate struct {
+	RestaurantID string   `json:"restaurant_id"`
+	Name         string   `json:"name"`
+	TableIDs     []string `json:"table_ids" binding:"required,min=2,dive,required"`
+	MinCapacity  int      `json:"min_capacity" binding:"omitempty,min=1"`
+	MaxCapacity  int      `json:"max_capacity" binding:"omitempty,min=1"`
+}
+
+// Get Table Groups for a Specific Restaurant Handler
+func getTableGroups(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groups, err := store.ListTableGroups(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch table groups"})
+			return
+		}
+
+		c.JSON(http.StatusOK, groups)
+	}
+}
+
+// Create Table Group Handler. Without explicit capacities a group seats up to
+// the sum of its tables and is kept for parties too large for any one of them.
+func createTableGroup(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var newGroup TableGroupCreate
+
+		if err := c.ShouldBindJSON(&newGroup); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+			return
+		}
+		newGroup.RestaurantID = c.Param("id")
+
+		tables, err := store.ListTables(c.Request.Context(), newGroup.RestaurantID)
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch tables"})
+			return
+		}
+
+		fields := map[string]string{}
+		seen := map[string]bool{}
+		largest, total := 0, 0
+		for _, id := range newGroup.TableIDs {
+			table := findTable(tables, id)
+			if table == nil || seen[id] {
+				fields["table_ids"] = "must list distinct tables of this restaurant"
+				break
+			}
+			seen[id] = true
+			total += table.MaxCapacity
+			largest = max(largest, table.MaxCapacity)
+		}
+		if newGroup.MaxCapacity == 0 {
+			newGroup.MaxCapacity = total
+		}
+		if newGroup.MinCapacity == 0 {
+			newGroup.MinCapacity = min(largest+1, newGroup.MaxCapacity)
+		}
+		if newGroup.MinCapacity > newGroup.MaxCapacity {
+			fields["min_capacity"] = "must not exceed max_capacity"
+		}
+		if len(fields) > 0 {
+			validationFailed(c, fields)
+			return
+		}
+
+		created, err := store.CreateTableGroup(c.Request.Context(), newGroup)
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to create table group"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Table group created successfully", "group": created})
+	}
+}
+
+// Delete Table Group Handler. Reservations on the group keep its lead table.
+func deleteTableGroup(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := store.DeleteTableGroup(c.Request.Context(), c.Param("id"), c.Param("group_id"))
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Table group not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to delete table group"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Table group deleted successfully"})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_TableGroups(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", Location: "X", OwnerID: "manager-1"})
+	_, err := store.AddMember(ctx, MemberCreate{RestaurantID: restaurant.ID, UserID: "staff-1", Role: roleStaff})
+	require.NoError(t, err)
+	var ids []string
+	for number, capacity := range []int{4, 4, 2} {
+		table, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: number + 1, MinCapacity: 1, MaxCapacity: capacity, Status: "available"})
+		require.NoError(t, err)
+		ids = append(ids, table.ID)
+	}
+	router := newRouter(store, defaultSettings(), nil, nil, testVerifier())
+	base := "/restaurants/" + restaurant.ID
+	staff := testToken(t, "staff-1", roleStaff)
+
+	t.Run("only staff create groups", func(t *testing.T) {
+		body := TableGroupCreate{Name: "Booth", TableIDs: []string{ids[0], ids[1]}}
+		rr := serve(router, testToken(t, "user-1", roleCustomer), http.MethodPost, base+"/table-groups", body)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("groups must combine distinct tables of the restaurant", func(t *testing.T) {
+		for _, tableIDs := range [][]string{{ids[0], ids[0]}, {ids[0], "missing"}} {
+			rr := serve(router, staff, http.MethodPost, base+"/table-groups", TableGroupCreate{TableIDs: tableIDs})
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Contains(t, rr.Body.String(), `"table_ids"`)
+		}
+		rr := serve(router, staff, http.MethodPost, base+"/table-groups", TableGroupCreate{TableIDs: []string{ids[0]}})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		rr = serve(router, staff, http.MethodPost, base+"/table-groups", TableGroupCreate{TableIDs: []string{ids[0], ids[1]}, MinCapacity: 6, MaxCapacity: 5})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"min_capacity"`)
+	})
+
+	var group TableGroup
+	t.Run("capacities default from the tables", func(t *testing.T) {
+		rr := serve(router, staff, http.MethodPost, base+"/table-groups", TableGroupCreate{Name: "Booth", TableIDs: []string{ids[0], ids[1]}})
+		require.Equal(t, http.StatusCreated, rr.Code)
+		var created struct {
+			Group TableGroup `json:"group"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+		group = created.Group
+		assert.Equal(t, 5, group.MinCapacity)
+		assert.Equal(t, 8, group.MaxCapacity)
+
+		rr = serve(router, "", http.MethodGet, base+"/table-groups", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), group.ID)
+	})
+
+	t.Run("large parties are booked on a free group", func(t *testing.T) {
+		rr := serve(router, "", http.MethodGet, base+"/availability?date=2025-05-01&time=19:00&party_size=7", nil)
+		var slot TableAvailability
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &slot))
+		assert.True(t, slot.Available)
+		assert.Empty(t, slot.Tables)
+		require.Len(t, slot.Groups, 1)
+
+		reservation := ReservationCreate{Date: "2025-05-01", Time: "19:00", Guests: 7}
+		rr = serve(router, testToken(t, "user-1", roleCustomer), http.MethodPost, base+"/reservations", reservation)
+		require.Equal(t, http.StatusCreated, rr.Code)
+		var created struct {
+			Reservation Reservation `json:"reservation"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+		assert.Equal(t, group.ID, created.Reservation.GroupID)
+		assert.Equal(t, ids[0], created.Reservation.TableID, "the lead table is recorded")
+
+		reservation.Guests = 3
+		rr = serve(router, testToken(t, "user-2", roleCustomer), http.MethodPost, base+"/reservations", reservation)
+		assert.Equal(t, http.StatusConflict, rr.Code, "both tables of the group are taken")
+	})
+
+	t.Run("deleting a group keeps its bookings on the lead table", func(t *testing.T) {
+		rr := serve(router, staff, http.MethodDelete, base+"/table-groups/"+group.ID, nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		rr = serve(router, staff, http.MethodDelete, base+"/table-groups/"+group.ID, nil)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+
+		booked, err := store.ListReservations(ctx, restaurant.ID, ReservationFilter{Date: "2025-05-01"})
+		require.NoError(t, err)
+		require.Len(t, booked, 1)
+		assert.Empty(t, booked[0].GroupID)
+		assert.Equal(t, ids[0], booked[0].TableID)
+	})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// Table statuses shown on the floor plan. The server keeps them in step with the
+// reservations; staff may still set one by hand, and it stands until the
+// situation at the table changes.
+const (
+	tableAvailable = "available"
+	tableReserved  = "reserved"
+	tableOccupied  = "occupied"
+)
+
+// tableStatuses derives the status of every table of f at the instant at:
+// occupied while a party is seated at it, reserved from ReservedLead before
+// a confirmed seating until the seating ends, and available otherwise.
+func tableStatuses(f floor, reservations []Reservation, at time.Time, cfg settings) map[string]string {
+	statuses := map[string]string{}
+	for _, t := range f.Tables {
+		statuses[t.ID] = tableAvailable
+	}
+	for _, r := range reservations {
+		status := ""
+		switch r.Status {
+		case statusSeated:
+			status = tableOccupied
+		case statusConfirmed:
+			start, end, err := seatingWindow(r, cfg.DiningDuration)
+			if err == nil && !at.Before(start.Add(-cfg.ReservedLead)) && at.Before(end) {
+				status = tableReserved
+			}
+		}
+		if status == "" {
+			continue
+		}
+		for _, id := range heldTables(r, f.Groups) {
+			if current, ok := statuses[id]; ok && current != tableOccupied {
+				statuses[id] = status
+			}
+		}
+	}
+	return statuses
+}
+
+// syncTableStatus writes the derived status at now to the restaurant's tables
+// that need it: the tables held by touched, whose reservations just changed, or
+// without touched every table whose derived status differs from the one at
+// since. Tables left alone keep whatever staff last set on them.
+func syncTableStatus(ctx context.Context, store Store, cfg settings, restaurantID string, now, since time.Time, touched ...Reservation) error {
+	restaurant, err := store.GetRestaurant(ctx, restaurantID)
+	if err != nil {
+		return err
+	}
+	f, err := loadFloor(ctx, store, restaurantID)
+	if err != nil {
+		return err
+	}
+	reservations, err := reservationsAround(ctx, store, restaurantID, now.In(restaurant.location()))
+	if err != nil {
+		return err
+	}
+	statuses := tableStatuses(f, reservations, now, cfg)
+
+	var candidates []string
+	if len(touched) > 0 {
+		for _, r := range touched {
+			candidates = append(candidates, heldTables(r, f.Groups)...)
+		}
+	} else {
+		before := tableStatuses(f, reservations, since, cfg)
+		for id, status := range statuses {
+			if before[id] != status {
+				candidates = append(candidates, id)
+			}
+		}
+	}
+
+	for _, t := range f.Tables {
+		status := statuses[t.ID]
+		if !slices.Contains(candidates, t.ID) || t.Status == status {
+			continue
+		}
+		if _, err := store.UpdateTable(ctx, restaurantID, t.ID, TableUpdate{Status: status}); err != nil {
+			return fmt.Errorf("setting table %s %s: %w", t.ID, status, err)
+		}
+	}
+	return nil
+}
+
+// showsOnTable reports whether r sets the status of its tables at now. A group
+// booking shows on all of its tables or none, so its lead table decides.
+func showsOnTable(r Reservation, now time.Time, cfg settings) bool {
+	if r.TableID == "" {
+		return false
+	}
+	lead := floor{Tables: []Table{{ID: r.TableID}}}
+	return tableStatuses(lead, []Reservation{r}, now, cfg)[r.TableID] != tableAvailable
+}
+
+// syncReservationTables brings the tables of a changed reservation up to date.
+// Pass the reservation as it was and as it is; nothing is written unless one of
+// them shows on its tables now. The write is the server's, not the caller's:
+// customers change their bookings but may not set table statuses. Failures are
+// logged: the reservation change itself has already been saved.
+func syncReservationTables(ctx context.Context, store Store, cfg settings, touched ...Reservation) {
+	ctx = withServiceRole(ctx)
+	now := time.Now()
+	if !slices.ContainsFunc(touched, func(r Reservation) bool { return showsOnTable(r, now, cfg) }) {
+		return
+	}
+	restaurantID := touched[0].RestaurantID
+	if err := syncTableStatus(ctx, store, cfg, restaurantID, now, now, touched...); err != nil {
+		log.Printf("Updating table status for restaurant %s failed: %v", restaurantID, err)
+	}
+}
+
+// sweepTableStatus updates table statuses every interval until ctx is done, so
+// tables turn reserved as confirmed seatings draw near and free up when a
+// seating runs out without the party being seated.
+func sweepTableStatus(ctx context.Context, store Store, cfg settings, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := time.Now().Add(-interval)
+	for {
+		now := time.Now()
+		restaurants, err := store.ListRestaurants(ctx, RestaurantFilter{})
+		if err != nil {
+			log.Printf("Listing restaurants for table status failed: %v", err)
+		}
+		for _, r := range restaurants {
+			if err := syncTableStatus(ctx, store, cfg, r.ID, now, last); err != nil {
+				log.Printf("Updating table status for restaurant %s failed: %v", r.ID, err)
+			}
+		}
+		if err == nil {
+			last = now
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
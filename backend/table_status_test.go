@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableStatuses(t *testing.T) {
+	f := floor{
+		Tables: []Table{{ID: "t1"}, {ID: "t2"}, {ID: "t3"}, {ID: "t4"}},
+		Groups: []TableGroup{{ID: "g1", TableIDs: []string{"t3", "t4"}}},
+	}
+	reservations := []Reservation{
+		{TableID: "t1", Date: "2025-05-01", Time: "19:00", Status: statusConfirmed},
+		{TableID: "t2", Date: "2025-05-01", Time: "17:00", Status: statusSeated},
+		{TableID: "t3", GroupID: "g1", Date: "2025-05-01", Time: "20:00", Status: statusConfirmed},
+		{TableID: "t1", Date: "2025-05-01", Time: "18:00", Status: statusCancelled},
+	}
+	cfg := defaultSettings()
+	at := func(clock string) map[string]string {
+		return tableStatuses(f, reservations, mustSlot(t, "2025-05-01", clock), cfg)
+	}
+
+	early := at("18:00")
+	assert.Equal(t, tableAvailable, early["t1"], "cancelled bookings and far-off seatings do not show")
+	assert.Equal(t, tableOccupied, early["t2"], "a seated party occupies its table whatever the time")
+
+	soon := at("18:30")
+	assert.Equal(t, tableReserved, soon["t1"])
+	assert.Equal(t, tableAvailable, soon["t3"])
+
+	group := at("19:45")
+	assert.Equal(t, tableReserved, group["t3"])
+	assert.Equal(t, tableReserved, group["t4"], "a group booking reserves every table of the group")
+
+	over := at("20:30")
+	assert.Equal(t, tableAvailable, over["t1"], "an unseated seating frees the table once it has run out")
+}
+
+func TestSyncTableStatus(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", Location: "X"})
+	first, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: tableAvailable})
+	require.NoError(t, err)
+	second, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 2, MinCapacity: 1, MaxCapacity: 4, Status: tableOccupied})
+	require.NoError(t, err)
+	_, err = store.CreateReservation(ctx, ReservationCreate{RestaurantID: restaurant.ID, TableID: first.ID, Date: "2025-05-01", Time: "19:00", Guests: 2, Status: statusConfirmed, DurationMinutes: 90})
+	require.NoError(t, err)
+	cfg := defaultSettings()
+	status := func(id string) string {
+		table, err := store.GetTable(ctx, restaurant.ID, id)
+		require.NoError(t, err)
+		return table.Status
+	}
+
+	require.NoError(t, syncTableStatus(ctx, store, cfg, restaurant.ID, mustSlot(t, "2025-05-01", "18:00"), mustSlot(t, "2025-05-01", "17:59")))
+	assert.Equal(t, tableAvailable, status(first.ID))
+	assert.Equal(t, tableOccupied, status(second.ID), "a status set by staff stands while nothing changes")
+
+	require.NoError(t, syncTableStatus(ctx, store, cfg, restaurant.ID, mustSlot(t, "2025-05-01", "18:30"), mustSlot(t, "2025-05-01", "18:29")))
+	assert.Equal(t, tableReserved, status(first.ID))
+
+	_, err = store.UpdateTable(ctx, restaurant.ID, first.ID, TableUpdate{Status: tableAvailable})
+	require.NoError(t, err)
+	require.NoError(t, syncTableStatus(ctx, store, cfg, restaurant.ID, mustSlot(t, "2025-05-01", "19:00"), mustSlot(t, "2025-05-01", "18:59")))
+	assert.Equal(t, tableAvailable, status(first.ID), "the override holds until the table's situation changes")
+
+	require.NoError(t, syncTableStatus(ctx, store, cfg, restaurant.ID, mustSlot(t, "2025-05-01", "20:30"), mustSlot(t, "2025-05-01", "20:29")))
+	assert.Equal(t, tableAvailable, status(first.ID))
+	assert.Equal(t, tableOccupied, status(second.ID))
+}
+
+func TestRouter_SeatingUpdatesTableStatus(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	restaurant, _ := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", Location: "X"})
+	_, err := store.AddMember(ctx, MemberCreate{RestaurantID: restaurant.ID, UserID: "staff-1", Role: roleStaff})
+	require.NoError(t, err)
+	table, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: tableAvailable})
+	require.NoError(t, err)
+	router := newRouter(store, defaultSettings(), nil, nil, testVerifier())
+	staff := testToken(t, "staff-1", roleStaff)
+	base := "/restaurants/" + restaurant.ID
+	tableStatus := func() string {
+		rr := serve(router, "", http.MethodGet, base+"/tables/"+table.ID, nil)
+		var tables []Table
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tables))
+		require.Len(t, tables, 1)
+		return tables[0].Status
+	}
+
+	// Book the walk-in for right now so it shows on the floor at once
+	now := time.Now().UTC()
+	body := ReservationCreate{Date: now.Format(dateLayout), Time: now.Format(timeLayout), Guests: 2, Status: statusConfirmed}
+	rr := serve(router, staff, http.MethodPost, base+"/reservations", body)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var created struct {
+		Reservation Reservation `json:"reservation"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	path := base + "/reservations/" + created.Reservation.ID
+	assert.Equal(t, tableReserved, tableStatus())
+
+	rr = serve(router, staff, http.MethodPost, path+"/seat", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, tableOccupied, tableStatus())
+
+	rr = serve(router, staff, http.MethodPut, base+"/tables/"+table.ID, TableUpdate{Status: tableReserved})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, tableReserved, tableStatus(), "staff may still override the status")
+
+	rr = serve(router, staff, http.MethodPost, path+"/complete", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, tableAvailable, tableStatus(), "closing the check frees the table")
+}
+
+func TestSyncReservationTables_WritesAsServer(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Minute)
+	booked := Reservation{
+		ID: "rsv-1", RestaurantID: "res-1", UserID: "carol", TableID: "t1",
+		Date: now.Format(dateLayout), Time: now.Format(timeLayout), StartsAt: now,
+		Guests: 2, Status: statusConfirmed, DurationMinutes: 90,
+	}
+	var patched []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/v1/restaurants":
+			w.Write([]byte(`[{"id":"res-1","name":"Diner","location":"X"}]`))
+		case "/rest/v1/tables":
+			if r.Method == http.MethodPatch {
+				patched = append(patched, r.Header.Get("Authorization"))
+			}
+			w.Write([]byte(`[{"id":"t1","restaurant_id":"res-1","number":1,"min_capacity":1,"max_capacity":4,"status":"available"}]`))
+		case "/rest/v1/reservations":
+			json.NewEncoder(w).Encode([]Reservation{booked})
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer server.Close()
+	store := newSupabaseStore(server.URL, "test-anon-key")
+	store.authMode = supabaseAuthForward
+	store.serviceKey = "service-key"
+
+	// Carol's own token may not write tables, so her booking reserves the table as the server
+	syncReservationTables(withAccessToken(context.Background(), "customer-jwt"), store, defaultSettings(), booked)
+
+	assert.Equal(t, []string{"Bearer service-key"}, patched)
+}
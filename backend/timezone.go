@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reservations are stored as UTC instants in StartsAt. Date and Time give the
+// same moment on the restaurant's clock, which is what guests book against and
+// what the API accepts and renders.
+
+// errSkippedTime reports a local time that a daylight saving change skips.
+var errSkippedTime = errors.New("time does not exist in the time zone on this date")
+
+// location returns the restaurant's time zone, UTC when none is set.
+func (r *Restaurant) location() *time.Location {
+	if r.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// wallClock returns the wall-clock reading of t as a UTC time, the form
+// opening hours are compared in.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// localSlot returns the instant a date and time name on a clock in loc. A time
+// skipped by a daylight saving change fails with errSkippedTime; the instant
+// returned with it is the one time.Date normalises to. Of a time that occurs
+// twice, the first is taken.
+func localSlot(date, clock string, loc *time.Location) (time.Time, error) {
+	wall, err := parseSlot(date, clock)
+	if err != nil {
+		return wall, err
+	}
+	start := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), 0, 0, loc)
+	if !wallClock(start).Equal(wall) {
+		return start, errSkippedTime
+	}
+	return start, nil
+}
+
+// anchorReservation returns the instant r starts at when its date and time are
+// read on a clock in loc. ok is false when they do not parse.
+func anchorReservation(r Reservation, loc *time.Location) (start time.Time, ok bool) {
+	start, err := localSlot(r.Date, r.Time, loc)
+	if err != nil && !errors.Is(err, errSkippedTime) {
+		return start, false
+	}
+	return start.UTC(), true
+}
+
+// loadRestaurant fetches the restaurant named in the route. On failure the
+// response has been written and ok is false.
+func loadRestaurant(c *gin.Context, store Store, restaurantID string) (restaurant *Restaurant, ok bool) {
+	restaurant, err := store.GetRestaurant(c.Request.Context(), restaurantID)
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Restaurant not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch restaurant"})
+		return nil, false
+	}
+	return restaurant, true
+}
+
+// inLocation renders the instants of reservations in loc.
+func inLocation(reservations []Reservation, loc *time.Location) []Reservation {
+	for i := range reservations {
+		if !reservations[i].StartsAt.IsZero() {
+			reservations[i].StartsAt = reservations[i].StartsAt.In(loc)
+		}
+	}
+	return reservations
+}
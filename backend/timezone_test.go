@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalSlot(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	start, err := localSlot("2025-05-01", "19:00", newYork)
+	require.NoError(t, err)
+	assert.True(t, time.Date(2025, 5, 1, 23, 0, 0, 0, time.UTC).Equal(start))
+
+	_, err = localSlot("2025-03-09", "02:30", newYork)
+	assert.ErrorIs(t, err, errSkippedTime, "clocks jump from 02:00 to 03:00")
+
+	start, err = localSlot("2025-11-02", "01:30", newYork)
+	require.NoError(t, err)
+	assert.True(t, time.Date(2025, 11, 2, 5, 30, 0, 0, time.UTC).Equal(start), "a repeated time is read as the first one")
+
+	assert.Equal(t, time.UTC, (&Restaurant{Timezone: "Mars/Olympus"}).location())
+}
+
+func TestRouter_RestaurantTimezone(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	router := newRouter(store, defaultSettings(), nil, nil, testVerifier())
+	restaurant, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", Location: "NYC", OwnerID: "manager-1", Timezone: "America/New_York"})
+	require.NoError(t, err)
+	_, err = store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: tableAvailable})
+	require.NoError(t, err)
+	base := "/restaurants/" + restaurant.ID
+	customer := testToken(t, "user-1", roleCustomer)
+
+	rr := serve(router, customer, http.MethodPost, base+"/reservations", ReservationCreate{Date: "2025-05-01", Time: "19:00", Guests: 2})
+	require.Equal(t, http.StatusCreated, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"starts_at":"2025-05-01T19:00:00-04:00"`, "rendered on the restaurant's clock")
+	stored, err := store.ListReservations(ctx, restaurant.ID, ReservationFilter{})
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.True(t, time.Date(2025, 5, 1, 23, 0, 0, 0, time.UTC).Equal(stored[0].StartsAt))
+
+	rr = serve(router, customer, http.MethodPost, base+"/reservations", ReservationCreate{Date: "2025-03-09", Time: "02:30", Guests: 2})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "does not exist on this date")
+
+	// A booking at 19:00 in New York runs past midnight UTC; it still holds the table
+	rr = serve(router, "", http.MethodGet, base+"/availability?date=2025-05-01&time=20:00&party_size=2", nil)
+	var slot TableAvailability
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &slot))
+	assert.False(t, slot.Available)
+	assert.Equal(t, "America/New_York", slot.Timezone)
+
+	rr = serve(router, "", http.MethodGet, base+"/availability?date=2025-03-09&party_size=2", nil)
+	var day DayAvailability
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &day))
+	for _, s := range day.Slots {
+		assert.NotEqual(t, "02:30", s.Time, "skipped times are not offered")
+	}
+	require.NotEmpty(t, day.Slots)
+	assert.Equal(t, "-05:00", day.Slots[0].StartsAt.Format("-07:00"))
+}
+
+func TestRouter_RescheduleReanchorsReservation(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	router := newRouter(store, defaultSettings(), nil, nil, testVerifier())
+	restaurant, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", Location: "NYC", Timezone: "America/New_York"})
+	require.NoError(t, err)
+	_, err = store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: tableAvailable})
+	require.NoError(t, err)
+	customer := testToken(t, "user-1", roleCustomer)
+	rr := serve(router, customer, http.MethodPost, "/restaurants/"+restaurant.ID+"/reservations", ReservationCreate{Date: "2025-05-01", Time: "19:00", Guests: 2})
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var created struct {
+		Reservation Reservation `json:"reservation"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	path := "/restaurants/" + restaurant.ID + "/reservations/" + created.Reservation.ID
+	startsAt := func() time.Time {
+		r, err := store.GetReservation(ctx, restaurant.ID, created.Reservation.ID)
+		require.NoError(t, err)
+		return r.StartsAt
+	}
+
+	rr = serve(router, customer, http.MethodPut, path, ReservationUpdate{Time: "18:00"})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, time.Date(2025, 5, 1, 22, 0, 0, 0, time.UTC).Equal(startsAt()))
+
+	// A reservation that no longer holds a table moves with its date and time too
+	rr = serve(router, customer, http.MethodPut, path, ReservationUpdate{Status: statusCancelled})
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr = serve(router, customer, http.MethodPut, path, ReservationUpdate{Date: "2025-12-01"})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, time.Date(2025, 12, 1, 23, 0, 0, 0, time.UTC).Equal(startsAt()), "18:00 in New York is 23:00 UTC in winter")
+}
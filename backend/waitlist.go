@@ -0,0 +1,342 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Waitlist entry statuses. Waiting and notified parties make up the queue;
+// seated, cancelled and no-show entries have left it and are final.
+const (
+	waitlistWaiting   = "waiting"
+	waitlistNotified  = "notified"
+	waitlistSeated    = "seated"
+	waitlistCancelled = "cancelled"
+	waitlistNoShow    = "no_show"
+)
+
+var waitlistStatuses = []string{waitlistWaiting, waitlistNotified, waitlistSeated, waitlistCancelled, waitlistNoShow}
+
+// waitlistTransitions lists the statuses each queued status may move to.
+var waitlistTransitions = map[string][]string{
+	waitlistWaiting:  {waitlistNotified, waitlistSeated, waitlistCancelled, waitlistNoShow},
+	waitlistNotified: {waitlistWaiting, waitlistSeated, waitlistCancelled, waitlistNoShow},
+}
+
+// canMoveEntry reports whether a waitlist entry may move from one status to
+// another. Entries saved without a status count as waiting.
+func canMoveEntry(from, to string) bool {
+	if from == "" {
+		from = waitlistWaiting
+	}
+	return slices.Contains(waitlistTransitions[from], to)
+}
+
+// WaitlistTransition asks the store to move a waitlist entry from one status to
+// another, recording TableID and ReservationID on it when set. The store fails
+// with ErrConflict when the entry is no longer in From.
+type WaitlistTransition struct {
+	RestaurantID  string
+	EntryID       string
+	From          string
+	To            string
+	TableID       string
+	ReservationID string
+}
+
+// WaitlistEntryUpdate is the body of a waitlist entry update. Seating a party
+// needs the table, or the table group, to seat them at.
+type WaitlistEntryUpdate struct {
+	Status  string `json:"status"`
+	TableID string `json:"table_id"`
+	GroupID string `json:"group_id"`
+	// TableIDAlias is the table as the web client names it.
+	TableIDAlias string `json:"tableId"`
+}
+
+// inQueue returns the entries still waiting for a table.
+func inQueue(entries []WaitlistEntry) []WaitlistEntry {
+	return without(entries, func(e WaitlistEntry) bool {
+		return e.Status != "" && e.Status != waitlistWaiting && e.Status != waitlistNotified
+	})
+}
+
+// waitUnknown is the estimated wait of a party no table or group will seat
+// within waitHorizon.
+const waitUnknown = -1
+
+// waitHorizon is how far ahead estimateWaits looks for a table. It stays within
+// the days reservationsAround fetches.
+const waitHorizon = 12 * time.Hour
+
+// queueOrder sorts entries first come, first served.
+func queueOrder(entries []WaitlistEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+}
+
+// estimateWaits puts entries in queue order and works out each party's place
+// and wait at now. Parties are seated in turn, every interval, at the first
+// table or group that fits them and is free for a full seating while the
+// restaurant is open, around reservations and the parties seated before them.
+// Seated parties that have stayed past their seating are expected to leave
+// within the next interval.
+func estimateWaits(restaurant *Restaurant, f floor, reservations []Reservation, entries []WaitlistEntry, now time.Time, cfg settings) []WaitlistEntry {
+	queueOrder(entries)
+	loc := restaurant.location()
+
+	booked := make([]Reservation, 0, len(reservations)+len(entries))
+	for _, r := range reservations {
+		if start, end, err := seatingWindow(r, cfg.DiningDuration); err == nil && r.Status == statusSeated && !end.After(now) {
+			r.DurationMinutes = int(now.Add(cfg.SlotInterval).Sub(start) / time.Minute)
+		}
+		booked = append(booked, r)
+	}
+
+	for i := range entries {
+		entries[i].PartyAhead = i
+		entries[i].EstimatedWaitTime = waitUnknown
+		for at := now; at.Before(now.Add(waitHorizon)); at = at.Add(cfg.SlotInterval) {
+			if !restaurant.seatingOpen(wallClock(at.In(loc)), cfg.DiningDuration) {
+				continue
+			}
+			tables, groups := freeSeating(f, booked, at, cfg.DiningDuration, entries[i].PartySize)
+			seat := Reservation{StartsAt: at, DurationMinutes: int(cfg.DiningDuration / time.Minute), Status: statusConfirmed}
+			switch {
+			case len(tables) > 0:
+				seat.TableID = tables[0].ID
+			case len(groups) > 0:
+				seat.TableID, seat.GroupID = groups[0].TableIDs[0], groups[0].ID
+			default:
+				continue
+			}
+			booked = append(booked, seat)
+			entries[i].EstimatedWaitTime = int(at.Sub(now) / time.Minute)
+			break
+		}
+	}
+	return entries
+}
+
+// loadWaitlistEntry fetches the waitlist entry named in the route. On failure
+// the response has been written and ok is false.
+func loadWaitlistEntry(c *gin.Context, store Store) (entry *WaitlistEntry, ok bool) {
+	entry, err := store.GetWaitlistEntry(c.Request.Context(), c.Param("id"), c.Param("entry_id"))
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch waitlist entry"})
+		return nil, false
+	}
+	return entry, true
+}
+
+// illegalEntryMove answers 409 for a status change waitlistTransitions forbids.
+func illegalEntryMove(c *gin.Context, from, to string) {
+	if from == "" {
+		from = waitlistWaiting
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   "Cannot move a " + from + " waitlist entry to " + to,
+		"status":  from,
+		"allowed": waitlistTransitions[from],
+	})
+}
+
+// entryChanged answers 409 when the entry moved on since the caller loaded it.
+func entryChanged(c *gin.Context) {
+	c.JSON(http.StatusConflict, gin.H{"error": "Waitlist entry has changed, reload it and try again"})
+}
+
+// Update Waitlist Entry Handler. Moves the entry to the status in the body;
+// seating goes through seatParty. A party moved on while a table was offered to
+// them gives it up, and it is offered to the next party.
+func updateWaitlistEntry(store Store, cfg settings) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var update WaitlistEntryUpdate
+		if err := c.ShouldBindJSON(&update); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+			return
+		}
+		if !slices.Contains(waitlistStatuses, update.Status) {
+			validationFailed(c, map[string]string{"status": "must be one of " + strings.Join(waitlistStatuses, ", ")})
+			return
+		}
+		if update.Status == waitlistSeated {
+			seatParty(c, store, cfg, update)
+			return
+		}
+
+		entry, ok := loadWaitlistEntry(c, store)
+		if !ok {
+			return
+		}
+		restaurant, ok := loadRestaurant(c, store, entry.RestaurantID)
+		if !ok {
+			return
+		}
+		if !canMoveEntry(entry.Status, update.Status) {
+			illegalEntryMove(c, entry.Status, update.Status)
+			return
+		}
+
+		ctx := c.Request.Context()
+		moved, err := store.TransitionWaitlistEntry(ctx, WaitlistTransition{
+			RestaurantID: entry.RestaurantID,
+			EntryID:      entry.ID,
+			From:         entry.Status,
+			To:           update.Status,
+		})
+		if errors.Is(err, ErrConflict) {
+			entryChanged(c)
+			return
+		}
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to update waitlist entry"})
+			return
+		}
+		principal, _ := currentPrincipal(c)
+		promoteFreed(ctx, store, cfg, withdrawOffers(ctx, store, entry, principal.UserID)...)
+
+		moved.CreatedAt = moved.CreatedAt.In(restaurant.location())
+		c.JSON(http.StatusOK, gin.H{"message": "Waitlist entry updated", "entry": moved})
+	}
+}
+
+// Seat Waitlist Entry Handler. Seats the party at the table or group in the
+// body right away.
+func seatWaitlistEntry(store Store, cfg settings) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var update WaitlistEntryUpdate
+		if err := c.ShouldBindJSON(&update); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+			return
+		}
+		seatParty(c, store, cfg, update)
+	}
+}
+
+// seatParty seats the waitlist entry in the route at the table or group in
+// update. The party gets a seated reservation starting now, and the same write
+// marks the entry seated and the table occupied, so the party leaves the queue. A table held for the party by an offer is released first so
+// they can be seated at it.
+func seatParty(c *gin.Context, store Store, cfg settings, update WaitlistEntryUpdate) {
+	if update.TableID == "" {
+		update.TableID = update.TableIDAlias
+	}
+	if update.TableID == "" && update.GroupID == "" {
+		validationFailed(c, map[string]string{"table_id": "is required to seat a party"})
+		return
+	}
+	entry, ok := loadWaitlistEntry(c, store)
+	if !ok {
+		return
+	}
+	if !canMoveEntry(entry.Status, waitlistSeated) {
+		illegalEntryMove(c, entry.Status, waitlistSeated)
+		return
+	}
+	restaurant, ok := loadRestaurant(c, store, entry.RestaurantID)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	principal, _ := currentPrincipal(c)
+	released := withdrawOffers(ctx, store, entry, principal.UserID)
+	if len(released) > 0 {
+		if entry, ok = loadWaitlistEntry(c, store); !ok {
+			return
+		}
+	}
+
+	seated, reservation, ok := bookSeat(c, store, cfg, restaurant, entry, seat{TableID: update.TableID, GroupID: update.GroupID})
+	promoteFreed(ctx, store, cfg, released...)
+	if !ok {
+		return
+	}
+
+	loc := restaurant.location()
+	seated.CreatedAt = seated.CreatedAt.In(loc)
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Party seated",
+		"entry":       seated,
+		"reservation": inLocation([]Reservation{*reservation}, loc)[0],
+	})
+}
+
+// bookSeat books entry's party a seated reservation at requested from now and
+// marks the entry seated. On failure the response has been written and ok is
+// false.
+func bookSeat(c *gin.Context, store Store, cfg settings, restaurant *Restaurant, entry *WaitlistEntry, requested seat) (seated *WaitlistEntry, reservation *Reservation, ok bool) {
+	loc := restaurant.location()
+	start := time.Now().Truncate(time.Minute).In(loc)
+	booking := ReservationCreate{
+		RestaurantID:    entry.RestaurantID,
+		UserID:          entry.UserID,
+		Date:            start.Format(dateLayout),
+		Time:            start.Format(timeLayout),
+		StartsAt:        start.UTC(),
+		Guests:          entry.PartySize,
+		Status:          statusSeated,
+		DurationMinutes: int(cfg.DiningDuration / time.Minute),
+		PhoneNumber:     entry.PhoneNumber,
+	}
+	candidate := Reservation{
+		RestaurantID:    booking.RestaurantID,
+		TableID:         requested.TableID,
+		GroupID:         requested.GroupID,
+		Date:            booking.Date,
+		Time:            booking.Time,
+		StartsAt:        booking.StartsAt,
+		Guests:          booking.Guests,
+		Status:          booking.Status,
+		DurationMinutes: booking.DurationMinutes,
+	}
+
+	unlock := bookingLocks.lock(entry.RestaurantID)
+	defer unlock()
+
+	ctx := c.Request.Context()
+	tables, groups, err := freeSeats(ctx, store, cfg, entry.RestaurantID, start, entry.PartySize, "")
+	if err != nil {
+		c.JSON(storeStatus(err), gin.H{"error": "Failed to find a table"})
+		return nil, nil, false
+	}
+	chosen, ok := seatFor(tables, groups, requested, seat{})
+	if !ok {
+		noTable(c, store, cfg, loc, candidate)
+		return nil, nil, false
+	}
+	booking.TableID, booking.GroupID = chosen.TableID, chosen.GroupID
+	candidate.TableID, candidate.GroupID = chosen.TableID, chosen.GroupID
+
+	seated, reservation, err = store.SeatWaitlistEntry(ctx, WaitlistTransition{
+		RestaurantID: entry.RestaurantID,
+		EntryID:      entry.ID,
+		From:         entry.Status,
+		To:           waitlistSeated,
+		TableID:      chosen.TableID,
+	}, booking)
+	if err != nil {
+		if current, getErr := store.GetWaitlistEntry(ctx, entry.RestaurantID, entry.ID); getErr == nil && current.Status != entry.Status {
+			entryChanged(c)
+			return nil, nil, false
+		}
+		slotTaken(c, store, cfg, loc, candidate, "Failed to seat party", err)
+		return nil, nil, false
+	}
+	return seated, reservation, true
+}
@@ -0,0 +1,535 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Waitlist offer statuses. An offer stays pending until the party accepts or
+// declines it, its hold runs out or staff move the party on; the other statuses
+// are final.
+const (
+	offerPending   = "pending"
+	offerAccepted  = "accepted"
+	offerDeclined  = "declined"
+	offerExpired   = "expired"
+	offerWithdrawn = "withdrawn"
+)
+
+// WaitlistOffer offers a waitlisted party a table that has come free. The table
+// is held by a pending reservation until ExpiresAt: accepting confirms it,
+// declining or letting the hold run out cancels it and the table goes to the
+// next party that fits.
+type WaitlistOffer struct {
+	ID            string    `json:"id"`
+	RestaurantID  string    `json:"restaurant_id"`
+	EntryID       string    `json:"entry_id"`
+	UserID        string    `json:"user_id,omitempty"` // the waitlisted guest, who may answer the offer
+	ReservationID string    `json:"reservation_id"`
+	Status        string    `json:"status"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// WaitlistOfferCreate records a new pending offer.
+type WaitlistOfferCreate struct {
+	RestaurantID  string    `json:"restaurant_id"`
+	EntryID       string    `json:"entry_id"`
+	UserID        string    `json:"user_id,omitempty"`
+	ReservationID string    `json:"reservation_id"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// waitingParties returns the restaurant's parties that may be offered a table
+// at now, in queue order: those waiting without a pending offer. A party that
+// declined an offer or let it lapse keeps its place but is passed over until
+// cfg.OfferHold after that offer ran out, so the table goes round the queue
+// before it comes back to them.
+func waitingParties(ctx context.Context, store Store, cfg settings, restaurantID string, now time.Time) ([]WaitlistEntry, error) {
+	entries, err := store.ListWaitlist(ctx, restaurantID)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	offers, err := store.ListWaitlistOffers(ctx, restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	passed := map[string]bool{}
+	for _, o := range offers {
+		switch o.Status {
+		case offerPending:
+			passed[o.EntryID] = true
+		case offerDeclined, offerExpired:
+			if now.Before(o.ExpiresAt.Add(cfg.OfferHold)) {
+				passed[o.EntryID] = true
+			}
+		}
+	}
+	entries = without(entries, func(e WaitlistEntry) bool {
+		return passed[e.ID] || (e.Status != "" && e.Status != waitlistWaiting)
+	})
+	queueOrder(entries)
+	return entries, nil
+}
+
+// offerTables offers the tables free at now to the restaurant's waiting
+// parties, in queue order, while it is open. Each party fitting a free table or
+// group gets one offer holding it for cfg.OfferHold.
+func offerTables(ctx context.Context, store Store, cfg settings, restaurantID string, now time.Time) error {
+	restaurant, err := store.GetRestaurant(ctx, restaurantID)
+	if err != nil {
+		return err
+	}
+	start := now.Truncate(time.Minute).In(restaurant.location())
+	if !restaurant.seatingOpen(wallClock(start), cfg.DiningDuration) {
+		return nil
+	}
+	parties, err := waitingParties(ctx, store, cfg, restaurantID, now)
+	if err != nil {
+		return err
+	}
+
+	unlock := bookingLocks.lock(restaurantID)
+	defer unlock()
+
+	for _, e := range parties {
+		tables, groups, err := freeSeats(ctx, store, cfg, restaurantID, start, e.PartySize, "")
+		if err != nil {
+			return err
+		}
+		chosen, ok := seatFor(tables, groups, seat{}, seat{})
+		if !ok {
+			continue
+		}
+		if _, err := holdSeat(ctx, store, cfg, e, chosen, start, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// promoteWaitlist offers the seating released, a reservation just cancelled or
+// missed, leaves behind to the first waiting party in queue order whose size
+// fits its table or group and that it is free for a whole seating. What is left
+// of a seating already under way is offered from now. It reports whether an
+// offer was made.
+func promoteWaitlist(ctx context.Context, store Store, cfg settings, released Reservation, now time.Time) (bool, error) {
+	start, end, err := seatingWindow(released, cfg.DiningDuration)
+	if err != nil || !end.After(now) {
+		return false, err
+	}
+	if start.Before(now) {
+		start = now.Truncate(time.Minute)
+	}
+	restaurant, err := store.GetRestaurant(ctx, released.RestaurantID)
+	if err != nil {
+		return false, err
+	}
+	start = start.In(restaurant.location())
+	if !restaurant.seatingOpen(wallClock(start), cfg.DiningDuration) {
+		return false, nil
+	}
+	parties, err := waitingParties(ctx, store, cfg, released.RestaurantID, now)
+	if err != nil {
+		return false, err
+	}
+
+	unlock := bookingLocks.lock(released.RestaurantID)
+	defer unlock()
+
+	freed := seat{TableID: released.TableID, GroupID: released.GroupID}
+	for _, e := range parties {
+		tables, groups, err := freeSeats(ctx, store, cfg, released.RestaurantID, start, e.PartySize, "")
+		if err != nil {
+			return false, err
+		}
+		chosen, ok := seatFor(tables, groups, freed, seat{})
+		if !ok {
+			continue
+		}
+		if held, err := holdSeat(ctx, store, cfg, e, chosen, start, now); held || err != nil {
+			return held, err
+		}
+	}
+	return false, nil
+}
+
+// holdSeat marks e notified, holds s for its party from start with a pending
+// reservation and offers it to them until cfg.OfferHold after now. held is
+// false when staff moved the party on or the seat was booked in the meantime.
+// The caller holds the restaurant's booking lock.
+func holdSeat(ctx context.Context, store Store, cfg settings, e WaitlistEntry, s seat, start, now time.Time) (held bool, err error) {
+	_, err = store.TransitionWaitlistEntry(ctx, WaitlistTransition{RestaurantID: e.RestaurantID, EntryID: e.ID, From: e.Status, To: waitlistNotified})
+	if errors.Is(err, ErrConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	reservation, err := store.CreateReservation(ctx, ReservationCreate{
+		RestaurantID:    e.RestaurantID,
+		UserID:          e.UserID,
+		TableID:         s.TableID,
+		GroupID:         s.GroupID,
+		Date:            start.Format(dateLayout),
+		Time:            start.Format(timeLayout),
+		StartsAt:        start.UTC(),
+		Guests:          e.PartySize,
+		Status:          statusPending,
+		DurationMinutes: int(cfg.DiningDuration / time.Minute),
+		PhoneNumber:     e.PhoneNumber,
+	})
+	if err != nil {
+		requeueEntry(ctx, store, e.RestaurantID, e.ID)
+		if errors.Is(err, ErrConflict) {
+			return false, nil // booked from another instance in the meantime
+		}
+		return false, err
+	}
+	_, err = store.CreateWaitlistOffer(ctx, WaitlistOfferCreate{
+		RestaurantID:  e.RestaurantID,
+		EntryID:       e.ID,
+		UserID:        e.UserID,
+		ReservationID: reservation.ID,
+		ExpiresAt:     now.Add(cfg.OfferHold),
+	})
+	if err != nil {
+		releaseOffer(ctx, store, e.RestaurantID, reservation.ID, serverActor)
+		requeueEntry(ctx, store, e.RestaurantID, e.ID)
+		return false, err
+	}
+	return true, nil
+}
+
+// releaseOffer cancels the reservation holding an offered table and returns it.
+// One already moved on by staff is left alone and nil is returned.
+func releaseOffer(ctx context.Context, store Store, restaurantID, reservationID, actorID string) *Reservation {
+	released, err := store.TransitionReservation(ctx, ReservationTransition{
+		RestaurantID:  restaurantID,
+		ReservationID: reservationID,
+		From:          statusPending,
+		To:            statusCancelled,
+		ActorID:       actorID,
+	})
+	if err != nil && !errors.Is(err, ErrConflict) && !errors.Is(err, ErrNotFound) {
+		log.Printf("Releasing the table held for reservation %s failed: %v", reservationID, err)
+	}
+	return released
+}
+
+// requeueEntry puts a notified party back to waiting, keeping its place in the
+// queue. One already moved on by staff is left alone.
+func requeueEntry(ctx context.Context, store Store, restaurantID, entryID string) {
+	_, err := store.TransitionWaitlistEntry(ctx, WaitlistTransition{
+		RestaurantID: restaurantID,
+		EntryID:      entryID,
+		From:         waitlistNotified,
+		To:           waitlistWaiting,
+	})
+	if err != nil && !errors.Is(err, ErrConflict) && !errors.Is(err, ErrNotFound) {
+		log.Printf("Returning waitlist entry %s to the queue failed: %v", entryID, err)
+	}
+}
+
+// closeOffer moves a pending offer to a final status other than accepted,
+// releases its table and puts the party back in the queue, on the server's
+// behalf since guests may not change their entry. It returns the released
+// reservation, if any, so its seating can be offered on, and fails with
+// ErrConflict when the offer is no longer pending.
+func closeOffer(ctx context.Context, store Store, offer *WaitlistOffer, status, actorID string) (closed *WaitlistOffer, released *Reservation, err error) {
+	closed, err = store.ResolveWaitlistOffer(ctx, offer.RestaurantID, offer.ID, status)
+	if err != nil {
+		return nil, nil, err
+	}
+	released = releaseOffer(ctx, store, offer.RestaurantID, offer.ReservationID, actorID)
+	requeueEntry(withServiceRole(ctx), store, offer.RestaurantID, offer.EntryID)
+	return closed, released, nil
+}
+
+// withdrawOffers closes the pending offers made to entry once staff move the
+// party on and returns the reservations released; failures are logged.
+func withdrawOffers(ctx context.Context, store Store, entry *WaitlistEntry, actorID string) []Reservation {
+	offers, err := store.ListWaitlistOffers(ctx, entry.RestaurantID)
+	if err != nil {
+		log.Printf("Listing waitlist offers for restaurant %s failed: %v", entry.RestaurantID, err)
+		return nil
+	}
+	var released []Reservation
+	for i := range offers {
+		if offers[i].EntryID != entry.ID || offers[i].Status != offerPending {
+			continue
+		}
+		_, r, err := closeOffer(ctx, store, &offers[i], offerWithdrawn, actorID)
+		if err != nil && !errors.Is(err, ErrConflict) {
+			log.Printf("Withdrawing waitlist offer %s failed: %v", offers[i].ID, err)
+		}
+		if r != nil {
+			released = append(released, *r)
+		}
+	}
+	return released
+}
+
+// promoteFreed offers the seatings of the released reservations to the next
+// waiting parties. It runs on the server's behalf, not the caller's; failures
+// are logged.
+func promoteFreed(ctx context.Context, store Store, cfg settings, released ...Reservation) {
+	ctx = withServiceRole(ctx)
+	for _, r := range released {
+		if _, err := promoteWaitlist(ctx, store, cfg, r, time.Now()); err != nil {
+			log.Printf("Offering the seating of reservation %s to the waitlist failed: %v", r.ID, err)
+		}
+	}
+}
+
+// expireOffers closes the restaurant's pending offers whose hold has run out at
+// now and offers each released seating to the next party.
+func expireOffers(ctx context.Context, store Store, cfg settings, restaurantID string, now time.Time) error {
+	offers, err := store.ListWaitlistOffers(ctx, restaurantID)
+	if err != nil {
+		return err
+	}
+	for i := range offers {
+		if offers[i].Status != offerPending || now.Before(offers[i].ExpiresAt) {
+			continue
+		}
+		_, released, err := closeOffer(ctx, store, &offers[i], offerExpired, serverActor)
+		if err != nil && !errors.Is(err, ErrConflict) {
+			return err
+		}
+		if released == nil {
+			continue
+		}
+		if _, err := promoteWaitlist(ctx, store, cfg, *released, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sweepWaitlistOffers expires lapsed offers and offers free tables to waiting
+// parties every interval until ctx is done.
+func sweepWaitlistOffers(ctx context.Context, store Store, cfg settings, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		restaurants, err := store.ListRestaurants(ctx, RestaurantFilter{})
+		if err != nil {
+			log.Printf("Listing restaurants for waitlist offers failed: %v", err)
+		}
+		for _, r := range restaurants {
+			if err := expireOffers(ctx, store, cfg, r.ID, now); err != nil {
+				log.Printf("Expiring waitlist offers for restaurant %s failed: %v", r.ID, err)
+			}
+			if err := offerTables(ctx, store, cfg, r.ID, now); err != nil {
+				log.Printf("Offering tables for restaurant %s failed: %v", r.ID, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// loadOffer fetches the offer named in the route and checks that the caller is
+// the waitlisted guest or staff of the restaurant. On failure the response has
+// been written and ok is false.
+func loadOffer(c *gin.Context, store Store) (offer *WaitlistOffer, ok bool) {
+	offer, err := store.GetWaitlistOffer(c.Request.Context(), c.Param("id"), c.Param("offer_id"))
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch offer"})
+		return nil, false
+	}
+
+	principal, _ := currentPrincipal(c)
+	if offer.UserID != "" && offer.UserID == principal.UserID {
+		return offer, true
+	}
+	staff, err := isRestaurantStaff(c.Request.Context(), store, principal, offer.RestaurantID)
+	if err != nil {
+		c.JSON(storeStatus(err), gin.H{"error": "Failed to check permissions"})
+		return nil, false
+	}
+	if !staff {
+		forbidden(c)
+		return nil, false
+	}
+	return offer, true
+}
+
+// offerClosed answers 409 for an offer that can no longer be answered.
+func offerClosed(c *gin.Context, status string) {
+	c.JSON(http.StatusConflict, gin.H{"error": "Offer is " + status + " and can no longer be answered", "status": status})
+}
+
+// offerInLocation renders the times of offer on the clock in loc.
+func offerInLocation(offer *WaitlistOffer, loc *time.Location) *WaitlistOffer {
+	offer.ExpiresAt = offer.ExpiresAt.In(loc)
+	offer.CreatedAt = offer.CreatedAt.In(loc)
+	return offer
+}
+
+// Get Waitlist Offers Handler. Lists the offers made to the restaurant's
+// waiting parties, oldest first.
+func getWaitlistOffers(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, store, c.Param("id"))
+		if !ok {
+			return
+		}
+		offers, err := store.ListWaitlistOffers(c.Request.Context(), restaurant.ID)
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to fetch offers"})
+			return
+		}
+
+		for i := range offers {
+			offerInLocation(&offers[i], restaurant.location())
+		}
+		c.JSON(http.StatusOK, offers)
+	}
+}
+
+// Accept Waitlist Offer Handler. Confirms the reservation holding the offered
+// table and takes the party off the waitlist. The reservation is confirmed
+// before the offer is accepted: a hold staff have already released withdraws
+// the offer and returns the party to the queue, and an offer closed in the
+// meantime gives the confirmed table back.
+func acceptWaitlistOffer(store Store, cfg settings) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		offer, ok := loadOffer(c, store)
+		if !ok {
+			return
+		}
+		restaurant, ok := loadRestaurant(c, store, offer.RestaurantID)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+		if offer.Status != offerPending {
+			offerClosed(c, offer.Status)
+			return
+		}
+		if !time.Now().Before(offer.ExpiresAt) {
+			if _, released, err := closeOffer(ctx, store, offer, offerExpired, serverActor); err == nil && released != nil {
+				promoteFreed(ctx, store, cfg, *released)
+			}
+			offerClosed(c, offerExpired)
+			return
+		}
+
+		principal, _ := currentPrincipal(c)
+		reservation, err := store.TransitionReservation(ctx, ReservationTransition{
+			RestaurantID:  offer.RestaurantID,
+			ReservationID: offer.ReservationID,
+			From:          statusPending,
+			To:            statusConfirmed,
+			ActorID:       principal.UserID,
+		})
+		if errors.Is(err, ErrConflict) || errors.Is(err, ErrNotFound) {
+			if _, _, err := closeOffer(ctx, store, offer, offerWithdrawn, serverActor); err != nil && !errors.Is(err, ErrConflict) {
+				log.Printf("Withdrawing waitlist offer %s failed: %v", offer.ID, err)
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "The table held for this offer is no longer available"})
+			return
+		}
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to confirm the reservation for this offer"})
+			return
+		}
+
+		accepted, err := store.ResolveWaitlistOffer(ctx, offer.RestaurantID, offer.ID, offerAccepted)
+		if err != nil {
+			released, releaseErr := store.TransitionReservation(ctx, ReservationTransition{
+				RestaurantID:  offer.RestaurantID,
+				ReservationID: offer.ReservationID,
+				From:          statusConfirmed,
+				To:            statusCancelled,
+				ActorID:       serverActor,
+			})
+			if releaseErr != nil {
+				log.Printf("Releasing the table confirmed for closed offer %s failed: %v", offer.ID, releaseErr)
+			} else {
+				promoteFreed(ctx, store, cfg, *released)
+			}
+			if errors.Is(err, ErrConflict) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Offer has changed, reload it and try again"})
+				return
+			}
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to accept offer"})
+			return
+		}
+		// The party's table is booked, so they leave the queue; the guest may
+		// not change waitlist entries themselves
+		_, err = store.TransitionWaitlistEntry(withServiceRole(ctx), WaitlistTransition{
+			RestaurantID:  offer.RestaurantID,
+			EntryID:       offer.EntryID,
+			From:          waitlistNotified,
+			To:            waitlistSeated,
+			TableID:       reservation.TableID,
+			ReservationID: reservation.ID,
+		})
+		if err != nil && !errors.Is(err, ErrConflict) && !errors.Is(err, ErrNotFound) {
+			log.Printf("Taking waitlist entry %s off the queue failed: %v", offer.EntryID, err)
+		}
+		syncReservationTables(ctx, store, cfg, *reservation)
+
+		loc := restaurant.location()
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Offer accepted",
+			"offer":       offerInLocation(accepted, loc),
+			"reservation": inLocation([]Reservation{*reservation}, loc)[0],
+		})
+	}
+}
+
+// Decline Waitlist Offer Handler. Releases the offered table and offers it to
+// the next party that fits; the declining party keeps their place and is
+// offered a table again once it has gone round the queue.
+func declineWaitlistOffer(store Store, cfg settings) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		offer, ok := loadOffer(c, store)
+		if !ok {
+			return
+		}
+		restaurant, ok := loadRestaurant(c, store, offer.RestaurantID)
+		if !ok {
+			return
+		}
+		if offer.Status != offerPending {
+			offerClosed(c, offer.Status)
+			return
+		}
+
+		principal, _ := currentPrincipal(c)
+		ctx := c.Request.Context()
+		declined, released, err := closeOffer(ctx, store, offer, offerDeclined, principal.UserID)
+		if errors.Is(err, ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Offer has changed, reload it and try again"})
+			return
+		}
+		if err != nil {
+			c.JSON(storeStatus(err), gin.H{"error": "Failed to decline offer"})
+			return
+		}
+		if released != nil {
+			promoteFreed(ctx, store, cfg, *released)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Offer declined", "offer": offerInLocation(declined, restaurant.location())})
+	}
+}
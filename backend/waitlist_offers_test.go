@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// offerFixture is a restaurant with one four-top and two couples waiting, Ann
+// ahead of Bob.
+func offerFixture(t *testing.T) (*memoryStore, *Restaurant) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	restaurant, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", Location: "X", OwnerID: "manager-1"})
+	require.NoError(t, err)
+	_, err = store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 4, Status: tableAvailable})
+	require.NoError(t, err)
+	_, err = store.CreateWaitlistEntry(ctx, WaitlistEntryCreate{RestaurantID: restaurant.ID, UserID: "ann", Name: "Ann", PartySize: 2})
+	require.NoError(t, err)
+	_, err = store.CreateWaitlistEntry(ctx, WaitlistEntryCreate{RestaurantID: restaurant.ID, UserID: "bob", Name: "Bob", PartySize: 2})
+	require.NoError(t, err)
+	return store, restaurant
+}
+
+// pendingOffer returns the restaurant's pending offer, failing unless there is exactly one.
+func pendingOffer(t *testing.T, store Store, restaurantID string) WaitlistOffer {
+	offers, err := store.ListWaitlistOffers(context.Background(), restaurantID)
+	require.NoError(t, err)
+	var pending []WaitlistOffer
+	for _, o := range offers {
+		if o.Status == offerPending {
+			pending = append(pending, o)
+		}
+	}
+	require.Len(t, pending, 1)
+	return pending[0]
+}
+
+func TestOfferTables(t *testing.T) {
+	ctx := context.Background()
+	store, restaurant := offerFixture(t)
+	cfg := defaultSettings()
+	now := time.Date(2025, 5, 1, 18, 0, 30, 0, time.UTC)
+
+	require.NoError(t, offerTables(ctx, store, cfg, restaurant.ID, now))
+	first := pendingOffer(t, store, restaurant.ID)
+	assert.Equal(t, "ann", first.UserID, "the head of the queue is offered the table first")
+	assert.True(t, now.Add(cfg.OfferHold).Equal(first.ExpiresAt))
+	held, err := store.GetReservation(ctx, restaurant.ID, first.ReservationID)
+	require.NoError(t, err)
+	assert.Equal(t, statusPending, held.Status)
+	assert.Equal(t, "18:00", held.Time)
+	assert.NotEmpty(t, held.TableID)
+	entry, err := store.GetWaitlistEntry(ctx, restaurant.ID, first.EntryID)
+	require.NoError(t, err)
+	assert.Equal(t, waitlistNotified, entry.Status)
+
+	require.NoError(t, offerTables(ctx, store, cfg, restaurant.ID, now.Add(time.Minute)))
+	assert.Equal(t, first.ID, pendingOffer(t, store, restaurant.ID).ID, "the held table is not offered twice")
+
+	require.NoError(t, expireOffers(ctx, store, cfg, restaurant.ID, now.Add(cfg.OfferHold-time.Second)))
+	assert.Equal(t, first.ID, pendingOffer(t, store, restaurant.ID).ID)
+
+	later := now.Add(cfg.OfferHold)
+	require.NoError(t, expireOffers(ctx, store, cfg, restaurant.ID, later))
+	released, err := store.GetReservation(ctx, restaurant.ID, first.ReservationID)
+	require.NoError(t, err)
+	assert.Equal(t, statusCancelled, released.Status, "a lapsed offer releases its table")
+	entry, err = store.GetWaitlistEntry(ctx, restaurant.ID, first.EntryID)
+	require.NoError(t, err)
+	assert.Equal(t, waitlistWaiting, entry.Status, "the party goes back to waiting")
+
+	require.NoError(t, offerTables(ctx, store, cfg, restaurant.ID, later))
+	second := pendingOffer(t, store, restaurant.ID)
+	assert.Equal(t, "bob", second.UserID, "the table passes to the next party")
+}
+
+func TestOfferTables_LapsedPartyGetsNextTable(t *testing.T) {
+	ctx := context.Background()
+	store, restaurant := offerFixture(t)
+	cfg := defaultSettings()
+	now := time.Date(2025, 5, 1, 18, 0, 0, 0, time.UTC)
+
+	require.NoError(t, offerTables(ctx, store, cfg, restaurant.ID, now))
+	lapsed := pendingOffer(t, store, restaurant.ID)
+	require.Equal(t, "ann", lapsed.UserID)
+	later := now.Add(cfg.OfferHold)
+	require.NoError(t, expireOffers(ctx, store, cfg, restaurant.ID, later))
+	assert.Equal(t, "bob", pendingOffer(t, store, restaurant.ID).UserID)
+
+	// A second table frees up; Ann is passed over while the first goes round
+	tables, _ := store.ListTables(ctx, restaurant.ID)
+	_, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 2, MinCapacity: 1, MaxCapacity: 4, Status: tableAvailable})
+	require.NoError(t, err)
+	require.NoError(t, offerTables(ctx, store, cfg, restaurant.ID, later.Add(time.Minute)))
+	assert.Equal(t, "bob", pendingOffer(t, store, restaurant.ID).UserID)
+
+	require.NoError(t, offerTables(ctx, store, cfg, restaurant.ID, later.Add(cfg.OfferHold)))
+	offers, err := store.ListWaitlistOffers(ctx, restaurant.ID)
+	require.NoError(t, err)
+	require.Len(t, offers, 3)
+	next := offers[2]
+	assert.Equal(t, "ann", next.UserID, "the party that let an offer lapse is offered the next table")
+	assert.Equal(t, offerPending, next.Status)
+	held, err := store.GetReservation(ctx, restaurant.ID, next.ReservationID)
+	require.NoError(t, err)
+	assert.NotEqual(t, tables[0].ID, held.TableID)
+}
+
+func TestOfferTables_SkipsPartiesNoTableFits(t *testing.T) {
+	ctx := context.Background()
+	store, restaurant := offerFixture(t)
+	entries, _ := store.ListWaitlist(ctx, restaurant.ID)
+	require.NoError(t, store.DeleteWaitlistEntry(ctx, restaurant.ID, entries[0].ID))
+	_, err := store.CreateWaitlistEntry(ctx, WaitlistEntryCreate{RestaurantID: restaurant.ID, UserID: "cat", Name: "Cat", PartySize: 8})
+	require.NoError(t, err)
+
+	require.NoError(t, offerTables(ctx, store, defaultSettings(), restaurant.ID, time.Date(2025, 5, 1, 18, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "bob", pendingOffer(t, store, restaurant.ID).UserID)
+}
+
+func TestRouter_WaitlistOffers(t *testing.T) {
+	ctx := context.Background()
+	store, restaurant := offerFixture(t)
+	cfg := defaultSettings()
+	router := newRouter(store, cfg, nil, nil, testVerifier())
+	base := "/restaurants/" + restaurant.ID + "/waitlist/offers/"
+	ann := testToken(t, "ann", roleCustomer)
+	bob := testToken(t, "bob", roleCustomer)
+
+	require.NoError(t, offerTables(ctx, store, cfg, restaurant.ID, time.Now()))
+	offer := pendingOffer(t, store, restaurant.ID)
+
+	rr := serve(router, bob, http.MethodPost, base+offer.ID+"/accept", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "an offer is answered by its party")
+
+	rr = serve(router, ann, http.MethodPost, base+offer.ID+"/decline", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr = serve(router, ann, http.MethodPost, base+offer.ID+"/accept", nil)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	next := pendingOffer(t, store, restaurant.ID)
+	assert.Equal(t, "bob", next.UserID, "declining passes the table on at once")
+	rr = serve(router, bob, http.MethodPost, base+next.ID+"/accept", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var accepted struct {
+		Offer       WaitlistOffer `json:"offer"`
+		Reservation Reservation   `json:"reservation"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &accepted))
+	assert.Equal(t, offerAccepted, accepted.Offer.Status)
+	assert.Equal(t, statusConfirmed, accepted.Reservation.Status)
+	assert.Equal(t, "bob", accepted.Reservation.UserID)
+
+	entries, err := store.ListWaitlist(ctx, restaurant.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, waitlistWaiting, entries[0].Status, "Ann keeps her place after declining")
+	assert.Equal(t, waitlistSeated, entries[1].Status, "Bob leaves the queue with his table booked")
+	assert.Equal(t, accepted.Reservation.ID, entries[1].ReservationID)
+
+	rr = serve(router, testToken(t, "manager-1", roleManager), http.MethodGet, "/restaurants/"+restaurant.ID+"/waitlist/offers", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var offers []WaitlistOffer
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &offers))
+	require.Len(t, offers, 2)
+	assert.Equal(t, offerDeclined, offers[0].Status)
+	assert.Equal(t, offerAccepted, offers[1].Status)
+}
+
+func TestRouter_AcceptExpiredOffer(t *testing.T) {
+	ctx := context.Background()
+	store, restaurant := offerFixture(t)
+	cfg := defaultSettings()
+	router := newRouter(store, cfg, nil, nil, testVerifier())
+
+	require.NoError(t, offerTables(ctx, store, cfg, restaurant.ID, time.Now().Add(-cfg.OfferHold)))
+	offer := pendingOffer(t, store, restaurant.ID)
+
+	rr := serve(router, testToken(t, "ann", roleCustomer), http.MethodPost, "/restaurants/"+restaurant.ID+"/waitlist/offers/"+offer.ID+"/accept", nil)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, rr.Body.String(), "expired")
+	assert.Equal(t, "bob", pendingOffer(t, store, restaurant.ID).UserID)
+}
+
+func TestRouter_AcceptOfferWhoseHoldWasReleased(t *testing.T) {
+	ctx := context.Background()
+	store, restaurant := offerFixture(t)
+	cfg := defaultSettings()
+	router := newRouter(store, cfg, nil, nil, testVerifier())
+	require.NoError(t, offerTables(ctx, store, cfg, restaurant.ID, time.Now()))
+	offer := pendingOffer(t, store, restaurant.ID)
+
+	// Staff cancel the held booking before Ann answers
+	_, err := store.TransitionReservation(ctx, ReservationTransition{RestaurantID: restaurant.ID, ReservationID: offer.ReservationID, From: statusPending, To: statusCancelled, ActorID: "manager-1"})
+	require.NoError(t, err)
+
+	rr := serve(router, testToken(t, "ann", roleCustomer), http.MethodPost, "/restaurants/"+restaurant.ID+"/waitlist/offers/"+offer.ID+"/accept", nil)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	closed, err := store.GetWaitlistOffer(ctx, restaurant.ID, offer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, offerWithdrawn, closed.Status, "an offer without its table is not accepted")
+	entry, err := store.GetWaitlistEntry(ctx, restaurant.ID, offer.EntryID)
+	require.NoError(t, err)
+	assert.Equal(t, waitlistWaiting, entry.Status, "Ann is back in the queue")
+	reservations, err := store.ListReservations(ctx, restaurant.ID, ReservationFilter{})
+	require.NoError(t, err)
+	for _, r := range reservations {
+		assert.NotEqual(t, statusConfirmed, r.Status)
+	}
+}
+
+func TestRouter_CancellationPromotesWaitlist(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	cfg := defaultSettings()
+	router := newRouter(store, cfg, nil, nil, testVerifier())
+	restaurant, err := store.CreateRestaurant(ctx, RestaurantCreate{Name: "Diner", Location: "X", OwnerID: "manager-1"})
+	require.NoError(t, err)
+	_, err = store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 1, MinCapacity: 1, MaxCapacity: 2, Status: tableAvailable})
+	require.NoError(t, err)
+	six, err := store.CreateTable(ctx, TableCreate{RestaurantID: restaurant.ID, Number: 2, MinCapacity: 3, MaxCapacity: 6, Status: tableAvailable})
+	require.NoError(t, err)
+
+	// The two-top is taken all evening, so only the cancelled six-top comes free
+	start := time.Now().Add(2 * time.Hour).Truncate(time.Minute).UTC()
+	booked := func(table *Table, guests int, userID string) *Reservation {
+		r, err := store.CreateReservation(ctx, ReservationCreate{
+			RestaurantID: restaurant.ID, UserID: userID, TableID: table.ID,
+			Date: start.Format(dateLayout), Time: start.Format(timeLayout), StartsAt: start,
+			Guests: guests, Status: statusConfirmed, DurationMinutes: 600,
+		})
+		require.NoError(t, err)
+		return r
+	}
+	tables, _ := store.ListTables(ctx, restaurant.ID)
+	booked(&tables[0], 2, "someone")
+	cancelled := booked(six, 5, "user-1")
+	for _, party := range []struct {
+		user string
+		size int
+	}{{"ann", 2}, {"cat", 8}, {"dan", 4}, {"eve", 5}} {
+		_, err := store.CreateWaitlistEntry(ctx, WaitlistEntryCreate{RestaurantID: restaurant.ID, UserID: party.user, Name: party.user, PartySize: party.size})
+		require.NoError(t, err)
+	}
+
+	rr := serve(router, testToken(t, "user-1", roleCustomer), http.MethodPost, "/restaurants/"+restaurant.ID+"/reservations/"+cancelled.ID+"/cancel", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	offer := pendingOffer(t, store, restaurant.ID)
+	assert.Equal(t, "dan", offer.UserID, "the first party in line that fits the freed table")
+	held, err := store.GetReservation(ctx, restaurant.ID, offer.ReservationID)
+	require.NoError(t, err)
+	assert.Equal(t, statusPending, held.Status)
+	assert.Equal(t, six.ID, held.TableID)
+	assert.True(t, start.Equal(held.StartsAt), "the freed slot is offered, not the next free one")
+	assert.Equal(t, 4, held.Guests)
+
+	rr = serve(router, testToken(t, "dan", roleCustomer), http.MethodPost, "/restaurants/"+restaurant.ID+"/waitlist/offers/"+offer.ID+"/decline", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	next := pendingOffer(t, store, restaurant.ID)
+	assert.Equal(t, "eve", next.UserID, "a declined slot passes down the line")
+	nextHeld, err := store.GetReservation(ctx, restaurant.ID, next.ReservationID)
+	require.NoError(t, err)
+	assert.True(t, start.Equal(nextHeld.StartsAt))
+}
+
+func TestPromoteWaitlist_SeatingOver(t *testing.T) {
+	ctx := context.Background()
+	store, restaurant := offerFixture(t)
+	tables, _ := store.ListTables(ctx, restaurant.ID)
+	past := Reservation{RestaurantID: restaurant.ID, TableID: tables[0].ID, Date: "2025-05-01", Time: "18:00", Guests: 2, Status: statusCancelled}
+
+	offered, err := promoteWaitlist(ctx, store, defaultSettings(), past, mustSlot(t, "2025-05-01", "19:30"))
+	require.NoError(t, err)
+	assert.False(t, offered, "nothing is left of a seating that has ended")
+
+	offered, err = promoteWaitlist(ctx, store, defaultSettings(), past, mustSlot(t, "2025-05-01", "19:00"))
+	require.NoError(t, err)
+	assert.True(t, offered)
+	held, err := store.GetReservation(ctx, restaurant.ID, pendingOffer(t, store, restaurant.ID).ReservationID)
+	require.NoError(t, err)
+	assert.Equal(t, "19:00", held.Time, "the rest of a seating under way is offered from now")
+}
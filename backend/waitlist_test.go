@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateWaits(t *testing.T) {
+	f := floor{
+		Tables: []Table{
+			{ID: "t1", Number: 1, MinCapacity: 1, MaxCapacity: 2},
+			{ID: "t2", Number: 2, MinCapacity: 2, MaxCapacity: 4},
+			{ID: "t3", Number: 3, MinCapacity: 2, MaxCapacity: 4},
+		},
+		Groups: []TableGroup{{ID: "g1", TableIDs: []string{"t2", "t3"}, MinCapacity: 5, MaxCapacity: 8}},
+	}
+	reservations := []Reservation{
+		{ID: "r1", TableID: "t1", Date: "2025-05-01", Time: "17:00", Guests: 2, Status: statusSeated},    // overstayed
+		{ID: "r2", TableID: "t2", Date: "2025-05-01", Time: "18:00", Guests: 4, Status: statusSeated},    // leaves at 19:30
+		{ID: "r3", TableID: "t3", Date: "2025-05-01", Time: "19:00", Guests: 4, Status: statusConfirmed}, // booked soon
+	}
+	at := func(minutes int) time.Time {
+		return mustSlot(t, "2025-05-01", "18:30").Add(time.Duration(minutes) * time.Minute)
+	}
+	entries := []WaitlistEntry{
+		{ID: "e3", Name: "Large", PartySize: 6, CreatedAt: at(-10)},
+		{ID: "e1", Name: "Couple", PartySize: 2, CreatedAt: at(-30)},
+		{ID: "e2", Name: "Four", PartySize: 4, CreatedAt: at(-20)},
+		{ID: "e4", Name: "Huge", PartySize: 12, CreatedAt: at(-5)},
+	}
+	cfg := defaultSettings()
+
+	got := estimateWaits(&Restaurant{}, f, reservations, entries, at(0), cfg)
+
+	names := []string{}
+	for _, e := range got {
+		names = append(names, e.Name)
+	}
+	assert.Equal(t, []string{"Couple", "Four", "Large", "Huge"}, names, "first come, first served")
+	for i, e := range got {
+		assert.Equal(t, i, e.PartyAhead)
+	}
+	assert.Equal(t, 30, got[0].EstimatedWaitTime, "the overstayed party is expected to leave within an interval")
+	assert.Equal(t, 60, got[1].EstimatedWaitTime, "t3 is booked at 19:00, so the party waits for t2")
+	assert.Equal(t, 150, got[2].EstimatedWaitTime, "the group frees once both tables turn over")
+	assert.Equal(t, waitUnknown, got[3].EstimatedWaitTime, "nothing seats twelve")
+}
+
+func TestEstimateWaits_OpeningHours(t *testing.T) {
+	f := floor{Tables: []Table{{ID: "t1", Number: 1, MinCapacity: 1, MaxCapacity: 4}}}
+	restaurant := &Restaurant{OpeningHours: OpeningHours{"thursday": {{Open: "18:00", Close: "23:00"}}}}
+	entries := []WaitlistEntry{{ID: "e1", PartySize: 2}}
+
+	got := estimateWaits(restaurant, f, nil, entries, mustSlot(t, "2025-05-01", "17:00"), defaultSettings())
+
+	assert.Equal(t, 60, got[0].EstimatedWaitTime, "nobody is seated before opening")
+}
+
+func TestRouter_UpdateWaitlistEntry(t *testing.T) {
+	ctx := context.Background()
+	store, restaurant := offerFixture(t)
+	router := newRouter(store, defaultSettings(), nil, nil, testVerifier())
+	manager := testToken(t, "manager-1", roleManager)
+	base := "/restaurants/" + restaurant.ID + "/waitlist/"
+	entries, _ := store.ListWaitlist(ctx, restaurant.ID)
+	ann, bob := entries[0], entries[1]
+
+	rr := serve(router, testToken(t, "ann", roleCustomer), http.MethodPut, base+ann.ID, WaitlistEntryUpdate{Status: waitlistCancelled})
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = serve(router, manager, http.MethodPut, base+ann.ID, WaitlistEntryUpdate{Status: "gone"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = serve(router, manager, http.MethodPut, base+ann.ID, WaitlistEntryUpdate{Status: waitlistCancelled})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"status":"cancelled"`)
+	rr = serve(router, manager, http.MethodPut, base+ann.ID, WaitlistEntryUpdate{Status: waitlistWaiting})
+	assert.Equal(t, http.StatusConflict, rr.Code, "cancelled entries are final")
+
+	rr = serve(router, manager, http.MethodGet, "/restaurants/"+restaurant.ID+"/waitlist", nil)
+	var queue []WaitlistEntry
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &queue))
+	require.Len(t, queue, 1, "cancelled parties leave the queue")
+	assert.Equal(t, bob.ID, queue[0].ID)
+	assert.Equal(t, 0, queue[0].PartyAhead)
+}
+
+func TestRouter_CustomerReadsOwnWaitlistPlace(t *testing.T) {
+	store, restaurant := offerFixture(t)
+	router := newRouter(store, defaultSettings(), nil, nil, testVerifier())
+	path := "/restaurants/" + restaurant.ID + "/waitlist"
+
+	rr := serve(router, testToken(t, "bob", roleCustomer), http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var queue []WaitlistEntry
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &queue))
+	require.Len(t, queue, 1, "only their own entry")
+	assert.Equal(t, "Bob", queue[0].Name)
+	assert.Equal(t, 1, queue[0].PartyAhead, "placed behind Ann")
+	assert.Equal(t, 90, queue[0].EstimatedWaitTime)
+
+	rr = serve(router, testToken(t, "carol", roleCustomer), http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[]`, rr.Body.String())
+}
+
+func TestRouter_SeatWaitlistEntry(t *testing.T) {
+	ctx := context.Background()
+	store, restaurant := offerFixture(t)
+	cfg := defaultSettings()
+	router := newRouter(store, cfg, nil, nil, testVerifier())
+	manager := testToken(t, "manager-1", roleManager)
+	base := "/restaurants/" + restaurant.ID + "/waitlist/"
+	tables, _ := store.ListTables(ctx, restaurant.ID)
+	table := tables[0]
+
+	// Ann is offered the only table, then shows up and is seated at it
+	require.NoError(t, offerTables(ctx, store, cfg, restaurant.ID, time.Now()))
+	offer := pendingOffer(t, store, restaurant.ID)
+
+	rr := serve(router, manager, http.MethodPut, base+offer.EntryID, WaitlistEntryUpdate{Status: waitlistSeated})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "seating needs a table")
+
+	rr = serve(router, manager, http.MethodPut, base+offer.EntryID, WaitlistEntryUpdate{Status: waitlistSeated, TableID: table.ID})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var seated struct {
+		Entry       WaitlistEntry `json:"entry"`
+		Reservation Reservation   `json:"reservation"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &seated))
+	assert.Equal(t, waitlistSeated, seated.Entry.Status)
+	assert.Equal(t, table.ID, seated.Entry.TableID)
+	assert.Equal(t, statusSeated, seated.Reservation.Status)
+	assert.Equal(t, "ann", seated.Reservation.UserID)
+
+	withdrawn, err := store.GetWaitlistOffer(ctx, restaurant.ID, offer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, offerWithdrawn, withdrawn.Status, "the offer's hold makes way for the seating")
+	held, err := store.GetReservation(ctx, restaurant.ID, offer.ReservationID)
+	require.NoError(t, err)
+	assert.Equal(t, statusCancelled, held.Status)
+	occupied, err := store.GetTable(ctx, restaurant.ID, table.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tableOccupied, occupied.Status)
+
+	entries, _ := store.ListWaitlist(ctx, restaurant.ID)
+	bob := entries[1]
+	rr = serve(router, manager, http.MethodPost, base+bob.ID+"/seat", WaitlistEntryUpdate{TableID: table.ID})
+	assert.Equal(t, http.StatusConflict, rr.Code, "the table is taken")
+	assert.Contains(t, rr.Body.String(), "conflict")
+	rr = serve(router, manager, http.MethodPost, base+seated.Entry.ID+"/seat", WaitlistEntryUpdate{TableID: table.ID})
+	assert.Equal(t, http.StatusConflict, rr.Code, "a party is seated once")
+}
+
+func TestRouter_SeatWaitlistEntry_WebClientPayload(t *testing.T) {
+	ctx := context.Background()
+	store, restaurant := offerFixture(t)
+	router := newRouter(store, defaultSettings(), nil, nil, testVerifier())
+	manager := testToken(t, "manager-1", roleManager)
+	tables, _ := store.ListTables(ctx, restaurant.ID)
+	entries, _ := store.ListWaitlist(ctx, restaurant.ID)
+
+	// WaitlistService.updateWaitlistEntry sends the table as tableId
+	rr := serve(router, manager, http.MethodPut, "/restaurants/"+restaurant.ID+"/waitlist/"+entries[0].ID, map[string]string{"status": waitlistSeated, "tableId": tables[0].ID})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	seated, err := store.GetWaitlistEntry(ctx, restaurant.ID, entries[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, waitlistSeated, seated.Status)
+	assert.Equal(t, tables[0].ID, seated.TableID)
+	occupied, err := store.GetTable(ctx, restaurant.ID, tables[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, tableOccupied, occupied.Status)
+}
@@ -12,6 +12,6 @@ export interface ReservationCreate {
     tableId: string;
     reservationTime: string; // ISO date string
     numberOfGuests: number;
-    status: 'pending' | 'confirmed' | 'cancelled' | 'completed';
+    status: 'pending' | 'confirmed' | 'seated' | 'completed' | 'cancelled' | 'no_show';
     phoneNumber: string;
 }
\ No newline at end of file